@@ -0,0 +1,262 @@
+package audit
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	boltFileMode    = 0o600
+	boltDirMode     = 0o750
+	boltOpenTimeout = 5 * time.Second
+
+	// exportPageSize is the number of entries scanned in a single read transaction by Export.
+	exportPageSize = 500
+)
+
+//nolint:gochecknoglobals // bucket name
+var entriesBucket = []byte("entries")
+
+// errStop stops the iteration over entries.
+var errStop = errors.New("stop")
+
+// Log persists moderated messages in an embedded bbolt database, ordered by the time of recording.
+type Log struct {
+	retention time.Duration
+
+	db *bolt.DB // nil if disabled
+}
+
+func New(config Config) (*Log, error) {
+	if config.Path == "" {
+		return &Log{retention: config.Retention, db: nil}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), boltDirMode); err != nil {
+		return nil, fmt.Errorf("%w: failed to create directory: %w", ErrInitFailed, err)
+	}
+
+	db, err := bolt.Open(config.Path, boltFileMode, &bolt.Options{Timeout: boltOpenTimeout}) //nolint:exhaustruct // defaults
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to open database: %w", ErrInitFailed, err)
+	}
+
+	if updErr := db.Update(func(tx *bolt.Tx) error {
+		_, bErr := tx.CreateBucketIfNotExists(entriesBucket)
+		return bErr //nolint:wrapcheck // wrapped below
+	}); updErr != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%w: failed to create bucket: %w", ErrInitFailed, updErr)
+	}
+
+	return &Log{retention: config.Retention, db: db}, nil
+}
+
+// Enabled reports whether entries are recorded.
+func (l *Log) Enabled() bool {
+	return l.db != nil
+}
+
+// Record appends the entry, setting its ID and the current time if missing.
+func (l *Log) Record(entry Entry) error {
+	if l.db == nil {
+		return nil
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	if err := l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(entriesBucket)
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return fmt.Errorf("failed to get next id: %w", err)
+		}
+		entry.ID = id
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode entry: %w", err)
+		}
+
+		return bucket.Put(itob(id), data) //nolint:wrapcheck // wrapped below
+	}); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+// Find returns the latest entries matching the filter, newest first.
+func (l *Log) Find(filter Filter) ([]Entry, error) {
+	entries := []Entry{}
+
+	err := l.eachReverse(filter, func(e Entry) error {
+		entries = append(entries, e)
+		if filter.Limit > 0 && len(entries) >= filter.Limit {
+			return errStop
+		}
+		return nil
+	})
+
+	return entries, err
+}
+
+// Export calls fn for all entries matching the filter, oldest first, until fn returns an error.
+// Entries are read in pages, each in a short transaction, so that a slow consumer does not hold
+// a read transaction, which keeps the database from reusing freed pages, for the whole export.
+func (l *Log) Export(filter Filter, fn func(Entry) error) error {
+	if l.db == nil {
+		return ErrDisabled
+	}
+
+	from := uint64(0)
+	for {
+		entries, last, more, err := l.page(filter, from)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if fnErr := fn(e); fnErr != nil {
+				return fnErr
+			}
+		}
+
+		if !more {
+			return nil
+		}
+		from = last + 1
+	}
+}
+
+// Cleanup removes the entries older than the retention period.
+func (l *Log) Cleanup() error {
+	if l.db == nil || l.retention <= 0 {
+		return nil
+	}
+
+	threshold := time.Now().Add(-l.retention)
+
+	if err := l.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(entriesBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.First() {
+			var e Entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("failed to decode entry %d: %w", btoi(k), err)
+			}
+			if !e.Time.Before(threshold) {
+				return nil
+			}
+			if err := c.Delete(); err != nil {
+				return fmt.Errorf("failed to delete entry %d: %w", btoi(k), err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+func (l *Log) Close() error {
+	if l.db == nil {
+		return nil
+	}
+
+	if err := l.db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return nil
+}
+
+// eachReverse calls fn for the entries matching the filter, newest first.
+func (l *Log) eachReverse(filter Filter, fn func(Entry) error) error {
+	if l.db == nil {
+		return ErrDisabled
+	}
+
+	err := l.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(entriesBucket).Cursor()
+
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var e Entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("failed to decode entry %d: %w", btoi(k), err)
+			}
+			if !filter.matches(e) {
+				continue
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if errors.Is(err, errStop) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+// page returns the entries matching the filter among at most exportPageSize entries starting from the ID,
+// the ID of the last scanned entry and whether there are entries after it.
+func (l *Log) page(filter Filter, from uint64) ([]Entry, uint64, bool, error) {
+	var (
+		entries []Entry
+		last    uint64
+		more    bool
+	)
+
+	if err := l.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(entriesBucket).Cursor()
+
+		scanned := 0
+		for k, v := c.Seek(itob(from)); k != nil; k, v = c.Next() {
+			if scanned == exportPageSize {
+				more = true
+				return nil
+			}
+			scanned++
+			last = btoi(k)
+
+			var e Entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("failed to decode entry %d: %w", last, err)
+			}
+			if filter.matches(e) {
+				entries = append(entries, e)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, 0, false, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return entries, last, more, nil
+}
+
+func itob(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+func btoi(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
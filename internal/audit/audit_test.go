@@ -0,0 +1,113 @@
+package audit_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/audit"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func newLog(t *testing.T, retention time.Duration) *audit.Log {
+	t.Helper()
+
+	log, err := audit.New(audit.Config{Path: filepath.Join(t.TempDir(), "audit.db"), Retention: retention})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = log.Close() })
+
+	return log
+}
+
+func TestLog_Find(t *testing.T) {
+	log := newLog(t, 0)
+
+	start := time.Now()
+	entries := []audit.Entry{
+		{ChatID: 1, UserID: 10, Plugin: "keyword", Time: start},
+		{ChatID: 1, UserID: 20, Plugin: "llm", Time: start.Add(time.Minute)},
+		{ChatID: 2, UserID: 10, Plugin: "keyword", Time: start.Add(2 * time.Minute)},
+	}
+	for _, e := range entries {
+		require.NoError(t, log.Record(e))
+	}
+
+	ids := func(entries []audit.Entry) []uint64 {
+		return lo.Map(entries, func(e audit.Entry, _ int) uint64 { return e.ID })
+	}
+
+	tests := []struct {
+		name   string
+		filter audit.Filter
+		want   []uint64
+	}{
+		{"all newest first", audit.Filter{}, []uint64{3, 2, 1}},
+		{"by chat", audit.Filter{ChatID: lo.ToPtr[int64](1)}, []uint64{2, 1}},
+		{"by user and plugin", audit.Filter{UserID: lo.ToPtr[int64](10), Plugin: "keyword"}, []uint64{3, 1}},
+		{"by time range", audit.Filter{From: start.Add(time.Minute), To: start.Add(2 * time.Minute)}, []uint64{2}},
+		{"limited", audit.Filter{Limit: 1}, []uint64{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := log.Find(tt.filter)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, ids(found))
+		})
+	}
+
+	var exported []audit.Entry
+	require.NoError(t, log.Export(audit.Filter{UserID: lo.ToPtr[int64](10)}, func(e audit.Entry) error {
+		exported = append(exported, e)
+		return nil
+	}))
+	require.Equal(t, []uint64{1, 3}, ids(exported))
+}
+
+func TestLog_ExportPages(t *testing.T) {
+	log := newLog(t, 0)
+
+	const count = 1200 // more than two pages
+	for i := range count {
+		require.NoError(t, log.Record(audit.Entry{ChatID: int64(i % 2), UserID: 10, Plugin: "keyword"}))
+	}
+
+	var exported []uint64
+	require.NoError(t, log.Export(audit.Filter{ChatID: lo.ToPtr[int64](1)}, func(e audit.Entry) error {
+		exported = append(exported, e.ID)
+		// Recording while exporting is not blocked by the export
+		if len(exported) == 1 {
+			return log.Record(audit.Entry{ChatID: 0, UserID: 10, Plugin: "keyword"})
+		}
+		return nil
+	}))
+
+	require.Len(t, exported, count/2)
+	require.Equal(t, uint64(2), exported[0])
+	require.Equal(t, uint64(count), exported[len(exported)-1])
+}
+
+func TestLog_Cleanup(t *testing.T) {
+	log := newLog(t, time.Hour)
+
+	require.NoError(t, log.Record(audit.Entry{Plugin: "old", Time: time.Now().Add(-2 * time.Hour)}))
+	require.NoError(t, log.Record(audit.Entry{Plugin: "new"}))
+	require.NoError(t, log.Cleanup())
+
+	found, err := log.Find(audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, "new", found[0].Plugin)
+}
+
+func TestLog_Disabled(t *testing.T) {
+	log, err := audit.New(audit.Config{})
+	require.NoError(t, err)
+
+	require.False(t, log.Enabled())
+	require.NoError(t, log.Record(audit.Entry{}))
+
+	_, err = log.Find(audit.Filter{})
+	require.ErrorIs(t, err, audit.ErrDisabled)
+}
@@ -0,0 +1,8 @@
+package audit
+
+import "time"
+
+type Config struct {
+	Path      string        // bbolt database file, the audit log is disabled when empty
+	Retention time.Duration // how long entries are kept, 0 keeps them forever
+}
@@ -0,0 +1,46 @@
+package audit
+
+import "time"
+
+// Entry is a moderated message.
+type Entry struct {
+	ID        uint64         `json:"id"`
+	Time      time.Time      `json:"time"`
+	ChatID    int64          `json:"chat_id"`
+	UserID    int64          `json:"user_id"`
+	MessageID int            `json:"message_id"`
+	Text      string         `json:"text"`
+	Plugin    string         `json:"plugin"`
+	Reason    string         `json:"reason"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	Action    string         `json:"action"`          // moderation action taken
+	DryRun    bool           `json:"dry_run"`         // the action was only reported
+	Error     string         `json:"error,omitempty"` // error applying the action
+}
+
+// Filter selects entries, zero fields match any entry.
+type Filter struct {
+	ChatID *int64
+	UserID *int64
+	Plugin string
+	From   time.Time // inclusive
+	To     time.Time // exclusive
+	Limit  int       // maximum number of entries returned by Find
+}
+
+func (f Filter) matches(e Entry) bool {
+	switch {
+	case f.ChatID != nil && *f.ChatID != e.ChatID:
+		return false
+	case f.UserID != nil && *f.UserID != e.UserID:
+		return false
+	case f.Plugin != "" && f.Plugin != e.Plugin:
+		return false
+	case !f.From.IsZero() && e.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.Time.Before(f.To):
+		return false
+	}
+
+	return true
+}
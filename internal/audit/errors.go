@@ -0,0 +1,9 @@
+package audit
+
+import "errors"
+
+var (
+	ErrInitFailed    = errors.New("failed to init audit log")
+	ErrDisabled      = errors.New("audit log is disabled")
+	ErrStorageFailed = errors.New("audit log operation failed")
+)
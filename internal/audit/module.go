@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-core-fx/logger"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// cleanupInterval is how often expired entries are removed.
+const cleanupInterval = time.Hour
+
+func Module() fx.Option {
+	return fx.Module(
+		"audit",
+		logger.WithNamedLogger("audit"),
+		fx.Provide(New),
+		fx.Invoke(func(lc fx.Lifecycle, log *Log, logger *zap.Logger) {
+			ctx, cancel := context.WithCancel(context.Background())
+			waitCh := make(chan struct{})
+			lc.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					logger.Info("audit log", zap.Bool("enabled", log.Enabled()))
+
+					go func() {
+						defer close(waitCh)
+
+						ticker := time.NewTicker(cleanupInterval)
+						defer ticker.Stop()
+						for {
+							select {
+							case <-ticker.C:
+								if err := log.Cleanup(); err != nil {
+									logger.Error("failed to clean up audit log", zap.Error(err))
+								}
+							case <-ctx.Done():
+								return
+							}
+						}
+					}()
+					return nil
+				},
+				OnStop: func(ctx context.Context) error {
+					cancel()
+					select {
+					case <-waitCh:
+					case <-ctx.Done():
+					}
+					return log.Close()
+				},
+			})
+		}),
+	)
+}
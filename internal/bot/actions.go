@@ -0,0 +1,286 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/pkg/tgbotapifx"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// applyStep applies the escalation step to the sender of a blocked message and notifies admins.
+func (b *Bot) applyStep(
+	ctx context.Context,
+	bot *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	result plugin.Result,
+	step Step,
+) error {
+	b.logger.Info("apply escalation step",
+		zap.String("action", string(step.Action)),
+		zap.Duration("duration", step.Duration),
+		zap.Any("message", message),
+	)
+
+	if step.Action == ActionWarn {
+		return b.warn(ctx, bot, message, result, step)
+	}
+
+	deleteReq := tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID)
+	if _, delErr := bot.Request(deleteReq); delErr != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionMessageDeleted, MetricLabelStatusFailed)
+		return fmt.Errorf("error deleting message: %w", delErr)
+	}
+	b.metrics.IncProcessedAction(MetricLabelActionMessageDeleted, MetricLabelStatusSuccess)
+
+	related := result.Related()
+	deleted := b.deleteRelated(bot, message, result)
+
+	if !step.Silent {
+		// Enhanced admin notification with plugin details
+		notification := fmt.Sprintf(
+			"Removed message from %s\nPlugin: %s\nReason: %s%s%s\n<pre>%s</pre>",
+			userToString(message.From),
+			result.Plugin,
+			result.Reason,
+			severityToString(result.Severity()),
+			relatedToString(related, deleted),
+			messageToString(message),
+		)
+		keyboard := b.noticeKeyboard(ctx, newNotice(message, result, step, true))
+		if ntfErr := b.notifyAdmins(bot, notification, keyboard); ntfErr != nil {
+			b.metrics.IncProcessedAction(MetricLabelActionAdminNotified, MetricLabelStatusFailed)
+			return fmt.Errorf("error notifying admins: %w", ntfErr)
+		}
+		b.metrics.IncProcessedAction(MetricLabelActionAdminNotified, MetricLabelStatusSuccess)
+	}
+
+	var err error
+	switch step.Action {
+	case ActionWarn, ActionDelete:
+		return nil
+	case ActionMute:
+		err = b.mute(bot, message.Chat.ID, message.From.ID, step.Duration)
+	case ActionKick:
+		err = b.kick(bot, message.Chat.ID, message.From.ID)
+	case ActionBan:
+		err = b.ban(bot, message.Chat.ID, message.From.ID, step.Duration)
+	}
+	if err != nil {
+		return err
+	}
+
+	if step.Silent {
+		return nil
+	}
+
+	notification := fmt.Sprintf("Applied %s to %s", stepToString(step), userToString(message.From))
+	if ntfErr := b.notifyAdmins(bot, notification, nil); ntfErr != nil {
+		return fmt.Errorf("error notifying admins: %w", ntfErr)
+	}
+
+	return nil
+}
+
+// deleteRelated deletes the earlier messages blocked together with a message, e.g. the rest of a spam wave,
+// and returns the number of deleted ones. Failures are logged, as some messages may be already deleted.
+// The deletions are recorded in the audit log, their senders are not escalated against, as the later
+// messages of a wave, which are deleted silently, do not count as violations either.
+func (b *Bot) deleteRelated(bot *tgbotapifx.Bot, message *tgbotapi.Message, result plugin.Result) int {
+	deleted := 0
+	for _, ref := range result.Related() {
+		_, err := bot.Request(tgbotapi.NewDeleteMessage(ref.ChatID, ref.MessageID))
+		b.recordRelatedAudit(message, ref, result, err)
+		if err != nil {
+			b.metrics.IncProcessedAction(MetricLabelActionRelatedDeleted, MetricLabelStatusFailed)
+			b.logger.Warn("error deleting related message", zap.Any("message", ref), zap.Error(err))
+			continue
+		}
+		b.metrics.IncProcessedAction(MetricLabelActionRelatedDeleted, MetricLabelStatusSuccess)
+		deleted++
+	}
+
+	return deleted
+}
+
+// warn replies to the blocked message with a warning.
+func (b *Bot) warn(
+	ctx context.Context,
+	bot *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	result plugin.Result,
+	step Step,
+) error {
+	warning := tgbotapi.NewMessage(
+		message.Chat.ID,
+		fmt.Sprintf(
+			"%s, your message violates the chat rules: %s",
+			userToString(message.From),
+			html.EscapeString(result.Reason),
+		),
+	)
+	warning.ParseMode = tgbotapi.ModeHTML
+	warning.ReplyToMessageID = message.MessageID
+
+	if _, err := bot.Send(warning); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionUserWarned, MetricLabelStatusFailed)
+		return fmt.Errorf("error warning user: %w", err)
+	}
+	b.metrics.IncProcessedAction(MetricLabelActionUserWarned, MetricLabelStatusSuccess)
+
+	notification := fmt.Sprintf(
+		"Warned %s\nPlugin: %s\nReason: %s%s\n<pre>%s</pre>",
+		userToString(message.From),
+		result.Plugin,
+		result.Reason,
+		severityToString(result.Severity()),
+		messageToString(message),
+	)
+	keyboard := b.noticeKeyboard(ctx, newNotice(message, result, step, false))
+	if err := b.notifyAdmins(bot, notification, keyboard); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionAdminNotified, MetricLabelStatusFailed)
+		return fmt.Errorf("error notifying admins: %w", err)
+	}
+	b.metrics.IncProcessedAction(MetricLabelActionAdminNotified, MetricLabelStatusSuccess)
+
+	return nil
+}
+
+// mute revokes all permissions of the user in the chat for the duration, forever if zero.
+func (b *Bot) mute(bot *tgbotapifx.Bot, chatID, userID int64, duration time.Duration) error {
+	muteReq := tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{
+			ChatID: chatID,
+			UserID: userID,
+		},
+		UntilDate:   untilDate(duration),
+		Permissions: &tgbotapi.ChatPermissions{}, //nolint:exhaustruct // all permissions revoked
+	}
+	if _, err := bot.Request(muteReq); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionUserMuted, MetricLabelStatusFailed)
+		return fmt.Errorf("error muting user: %w", err)
+	}
+	b.metrics.IncProcessedAction(MetricLabelActionUserMuted, MetricLabelStatusSuccess)
+
+	return nil
+}
+
+// kick removes the user from the chat without preventing them from joining again.
+func (b *Bot) kick(bot *tgbotapifx.Bot, chatID, userID int64) error {
+	member := tgbotapi.ChatMemberConfig{
+		ChatID: chatID,
+		UserID: userID,
+	}
+
+	if _, err := bot.Request(tgbotapi.BanChatMemberConfig{ChatMemberConfig: member}); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionUserKicked, MetricLabelStatusFailed)
+		return fmt.Errorf("error kicking user: %w", err)
+	}
+
+	if _, err := bot.Request(tgbotapi.UnbanChatMemberConfig{ChatMemberConfig: member, OnlyIfBanned: true}); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionUserKicked, MetricLabelStatusFailed)
+		return fmt.Errorf("error kicking user: %w", err)
+	}
+	b.metrics.IncProcessedAction(MetricLabelActionUserKicked, MetricLabelStatusSuccess)
+
+	return nil
+}
+
+// ban bans the user in the chat for the duration, forever if zero.
+func (b *Bot) ban(bot *tgbotapifx.Bot, chatID, userID int64, duration time.Duration) error {
+	banReq := tgbotapi.BanChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{
+			ChatID: chatID,
+			UserID: userID,
+		},
+		UntilDate: untilDate(duration),
+	}
+	if _, err := bot.Request(banReq); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionUserBanned, MetricLabelStatusFailed)
+		return fmt.Errorf("error banning user: %w", err)
+	}
+	b.metrics.IncProcessedAction(MetricLabelActionUserBanned, MetricLabelStatusSuccess)
+
+	return nil
+}
+
+// unban lifts the ban or restrictions of the user in the chat.
+func (b *Bot) unban(bot *tgbotapifx.Bot, chatID, userID int64) error {
+	member := tgbotapi.ChatMemberConfig{
+		ChatID: chatID,
+		UserID: userID,
+	}
+
+	if _, err := bot.Request(tgbotapi.UnbanChatMemberConfig{ChatMemberConfig: member, OnlyIfBanned: true}); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionUserUnbanned, MetricLabelStatusFailed)
+		return fmt.Errorf("error unbanning user: %w", err)
+	}
+
+	if err := b.unmute(bot, chatID, userID); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionUserUnbanned, MetricLabelStatusFailed)
+		return err
+	}
+	b.metrics.IncProcessedAction(MetricLabelActionUserUnbanned, MetricLabelStatusSuccess)
+
+	return nil
+}
+
+// unmute restores the permissions of a restricted user in the chat to the default permissions of the chat.
+func (b *Bot) unmute(bot *tgbotapifx.Bot, chatID, userID int64) error {
+	unmuteReq := tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{
+			ChatID: chatID,
+			UserID: userID,
+		},
+		UntilDate:   0,
+		Permissions: b.chatPermissions(bot, chatID),
+	}
+	if _, err := bot.Request(unmuteReq); err != nil {
+		return fmt.Errorf("error unmuting user: %w", err)
+	}
+
+	return nil
+}
+
+// chatPermissions returns the default member permissions of the chat,
+// the permissions of a regular member if they can not be retrieved.
+func (b *Bot) chatPermissions(bot *tgbotapifx.Bot, chatID int64) *tgbotapi.ChatPermissions {
+	chat, err := bot.GetChat(tgbotapi.ChatInfoConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}, //nolint:exhaustruct // chat is referenced by ID
+	})
+	if err != nil {
+		b.logger.Warn("error getting chat permissions", zap.Int64("chat_id", chatID), zap.Error(err))
+		return memberPermissions()
+	}
+	if chat.Permissions == nil {
+		return memberPermissions()
+	}
+
+	return chat.Permissions
+}
+
+// memberPermissions returns the permissions of a regular chat member.
+func memberPermissions() *tgbotapi.ChatPermissions {
+	return &tgbotapi.ChatPermissions{
+		CanSendMessages:       true,
+		CanSendMediaMessages:  true,
+		CanSendPolls:          true,
+		CanSendOtherMessages:  true,
+		CanAddWebPagePreviews: true,
+		CanChangeInfo:         false,
+		CanInviteUsers:        true,
+		CanPinMessages:        false,
+	}
+}
+
+// untilDate converts a restriction duration to a Telegram until date, zero means forever.
+func untilDate(duration time.Duration) int64 {
+	if duration <= 0 {
+		return 0
+	}
+	return time.Now().Add(duration).Unix()
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/capcom6/censor-tg-bot/internal/audit"
+	"github.com/capcom6/censor-tg-bot/internal/captcha"
 	"github.com/capcom6/censor-tg-bot/internal/censor"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
 	"github.com/capcom6/censor-tg-bot/internal/storage"
 	"github.com/capcom6/censor-tg-bot/pkg/tgbotapifx"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -18,23 +22,65 @@ type Bot struct {
 	config Config
 
 	censor  *censor.Service
-	storage *storage.Storage
+	storage storage.Storage
+	audit   *audit.Log
+	captcha *captcha.Captcha
 	metrics *Metrics
 
+	// raid lockdowns of chats and the chat permissions saved to restore them afterwards
+	lockdown    *lockdown.Registry
+	permissions *permissionStore
+
+	// users allowed and blocked with admin commands
+	users *userLists
+
+	// blocked messages referenced by admin notification buttons
+	notices *noticeStore
+
 	logger *zap.Logger
 }
 
-func New(cfg Config, censor *censor.Service, storage *storage.Storage, metrics *Metrics, logger *zap.Logger) *Bot {
+func New(
+	cfg Config,
+	censor *censor.Service,
+	storage storage.Storage,
+	state storage.State,
+	audit *audit.Log,
+	captcha *captcha.Captcha,
+	lockdown *lockdown.Registry,
+	metrics *Metrics,
+	logger *zap.Logger,
+) (*Bot, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &Bot{
 		config:  cfg,
 		censor:  censor,
 		storage: storage,
+		audit:   audit,
+		captcha: captcha,
 		metrics: metrics,
+
+		lockdown:    lockdown,
+		permissions: newPermissionStore(state),
+
+		users:   newUserLists(state),
+		notices: newNoticeStore(state),
 		logger:  logger,
-	}
+	}, nil
 }
 
 func (b *Bot) Handler(ctx context.Context, bot *tgbotapifx.Bot, update tgbotapi.Update) error {
+	if chat, users := joinedMembers(update); len(users) > 0 {
+		b.lockdown.Join(chat.ID, lo.Map(users, func(u tgbotapi.User, _ int) int64 { return u.ID })...)
+
+		if b.captcha.Enabled() {
+			return b.challengeMembers(ctx, bot, chat, users)
+		}
+	}
+
 	message, ok := lo.Find([]*tgbotapi.Message{
 		update.Message,
 		update.EditedMessage,
@@ -56,6 +102,17 @@ func (b *Bot) Handler(ctx context.Context, bot *tgbotapifx.Bot, update tgbotapi.
 
 func (b *Bot) processMessage(ctx context.Context, bot *tgbotapifx.Bot, message *tgbotapi.Message) error {
 	result := b.evaluateMessage(ctx, message)
+
+	if l, ok := b.config.LockdownForResult(result); ok {
+		b.requestLockdown(ctx, l)
+	}
+
+	if shadow, ok := result.Metadata[censor.MetadataKeyShadow].([]plugin.Result); ok {
+		if err := b.reportShadow(ctx, bot, message, result, shadow); err != nil {
+			return err
+		}
+	}
+
 	if result.Action != plugin.ActionBlock {
 		return nil
 	}
@@ -63,57 +120,165 @@ func (b *Bot) processMessage(ctx context.Context, bot *tgbotapifx.Bot, message *
 	b.logger.Info("message blocked",
 		zap.String("plugin", result.Plugin),
 		zap.String("reason", result.Reason),
+		zap.String("recommendation", string(result.Recommendation())),
+		zap.String("severity", string(result.Severity())),
 		zap.Any("metadata", result.Metadata),
 		zap.Any("message", message),
+		zap.Bool("dry_run", b.config.DryRun),
 	)
 
-	deleteReq := tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID)
-	if _, delErr := bot.Request(deleteReq); delErr != nil {
-		b.metrics.IncProcessedAction(MetricLabelActionMessageDeleted, MetricLabelStatusFailed)
-		return fmt.Errorf("error deleting message: %w", delErr)
+	step := b.escalate(message, result)
+
+	var err error
+	if b.config.DryRun {
+		err = b.reportDryRun(ctx, bot, message, result, step)
+	} else {
+		err = b.applyStep(ctx, bot, message, result, step)
 	}
-	b.metrics.IncProcessedAction(MetricLabelActionMessageDeleted, MetricLabelStatusSuccess)
 
-	// Enhanced admin notification with plugin details
-	notification := fmt.Sprintf(
-		"Removed message from %s\nPlugin: %s\nReason: %s\n<pre>%s</pre>",
-		userToString(message.From),
-		result.Plugin,
-		result.Reason,
-		messageToString(message),
-	)
-	if ntfErr := b.notifyAdmins(bot, notification); ntfErr != nil {
-		b.metrics.IncProcessedAction(MetricLabelActionAdminNotified, MetricLabelStatusFailed)
-		return fmt.Errorf("error notifying admins: %w", ntfErr)
+	b.recordAudit(message, result, step, err)
+
+	return err
+}
+
+// recordAudit appends the moderated message to the audit log.
+func (b *Bot) recordAudit(message *tgbotapi.Message, result plugin.Result, step Step, err error) {
+	entry := audit.Entry{
+		ID:        0,
+		Time:      time.Now(),
+		ChatID:    message.Chat.ID,
+		UserID:    message.From.ID,
+		MessageID: message.MessageID,
+		Text:      messageToString(message),
+		Plugin:    result.Plugin,
+		Reason:    result.Reason,
+		Metadata:  result.Metadata,
+		Action:    stepToString(step),
+		DryRun:    b.config.DryRun,
+		Error:     "",
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if recErr := b.audit.Record(entry); recErr != nil {
+		b.logger.Error("error recording audit entry", zap.Error(recErr))
+	}
+}
+
+// recordRelatedAudit appends the related message deleted together with the blocked message to the audit log.
+func (b *Bot) recordRelatedAudit(message *tgbotapi.Message, ref plugin.MessageRef, result plugin.Result, err error) {
+	entry := audit.Entry{
+		ID:        0,
+		Time:      time.Now(),
+		ChatID:    ref.ChatID,
+		UserID:    ref.UserID,
+		MessageID: ref.MessageID,
+		Text:      "",
+		Plugin:    result.Plugin,
+		Reason:    result.Reason,
+		Metadata: map[string]any{
+			"related_chat_id":    message.Chat.ID,
+			"related_message_id": message.MessageID,
+		},
+		Action: string(ActionDelete),
+		DryRun: false,
+		Error:  "",
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if recErr := b.audit.Record(entry); recErr != nil {
+		b.logger.Error("error recording audit entry", zap.Error(recErr))
+	}
+}
+
+// escalate increments the violation count of the sender and returns the step to apply,
+// a moderation action recommended by the plugin takes precedence over the escalation ladder.
+// Results which do not count as violations leave the count unchanged.
+func (b *Bot) escalate(message *tgbotapi.Message, result plugin.Result) Step {
+	if !b.config.CountsViolation(result) {
+		return b.config.StepForResult(result, 0)
 	}
-	b.metrics.IncProcessedAction(MetricLabelActionAdminNotified, MetricLabelStatusSuccess)
 
-	cnt, err := b.storage.GetOrSet(strconv.FormatInt(message.From.ID, 10))
+	cnt, err := b.countViolation(message.From.ID)
 	if err != nil {
 		b.logger.Warn("error getting violation count", zap.Any("message", message), zap.Error(err))
 	}
 	b.logger.Info("violation count", zap.Any("message", message), zap.Int("count", cnt))
-	if cnt < int(b.config.BanThreshold) {
-		return nil
+
+	return b.config.StepForResult(result, cnt)
+}
+
+// countViolation increments the violation counter of the user and returns the new value.
+// In dry run mode the counter is not changed, the value it would have been incremented to is returned,
+// so strikes collected while nothing is enforced are not acted upon later.
+func (b *Bot) countViolation(userID int64) (int, error) {
+	key := strconv.FormatInt(userID, 10)
+	if !b.config.DryRun {
+		return b.storage.GetOrSet(key) //nolint:wrapcheck // wrapped by the storage
 	}
 
-	b.logger.Info("ban user", zap.Any("message", message))
+	cnt, err := b.storage.Get(key)
+	return cnt + 1, err //nolint:wrapcheck // wrapped by the storage
+}
 
-	banReq := tgbotapi.BanChatMemberConfig{
-		ChatMemberConfig: tgbotapi.ChatMemberConfig{
-			ChatID: message.Chat.ID,
-			UserID: message.From.ID,
-		},
+// reportDryRun notifies admins about the actions that would have been taken for a blocked message.
+func (b *Bot) reportDryRun(
+	ctx context.Context,
+	bot *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	result plugin.Result,
+	step Step,
+) error {
+	notification := fmt.Sprintf(
+		"[dry run] Would have applied %s to %s\nPlugin: %s\nReason: %s%s\n<pre>%s</pre>",
+		stepToString(step),
+		userToString(message.From),
+		result.Plugin,
+		result.Reason,
+		relatedToString(result.Related(), len(result.Related())),
+		messageToString(message),
+	)
+
+	keyboard := b.noticeKeyboard(ctx, newNotice(message, result, step, false))
+	if err := b.notifyAdmins(bot, notification, keyboard); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionDryRunReported, MetricLabelStatusFailed)
+		return fmt.Errorf("error notifying admins: %w", err)
 	}
-	if _, banErr := bot.Request(banReq); banErr != nil {
-		b.metrics.IncProcessedAction(MetricLabelActionUserBanned, MetricLabelStatusFailed)
-		return fmt.Errorf("error banning user: %w", banErr)
+	b.metrics.IncProcessedAction(MetricLabelActionDryRunReported, MetricLabelStatusSuccess)
+
+	return nil
+}
+
+// reportShadow notifies admins about blocks of shadow plugins, which are not acted upon.
+func (b *Bot) reportShadow(
+	ctx context.Context,
+	bot *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	result plugin.Result,
+	shadow []plugin.Result,
+) error {
+	b.logger.Info("message blocked by shadow plugins",
+		zap.Strings("plugins", lo.Map(shadow, func(r plugin.Result, _ int) string { return r.Plugin })),
+		zap.Any("message", message),
+	)
+
+	notification := "[shadow] Would have removed message from " + userToString(message.From)
+	for _, r := range shadow {
+		notification += fmt.Sprintf("\nPlugin: %s\nReason: %s", r.Plugin, r.Reason)
 	}
-	b.metrics.IncProcessedAction(MetricLabelActionUserBanned, MetricLabelStatusSuccess)
+	notification += fmt.Sprintf("\n<pre>%s</pre>", messageToString(message))
 
-	if ntfErr := b.notifyAdmins(bot, "Banned "+userToString(message.From)); ntfErr != nil {
-		return fmt.Errorf("error notifying admins: %w", ntfErr)
+	n := newNotice(message, result, Step{}, false) //nolint:exhaustruct // no step is applied
+	n.Plugin = shadow[0].Plugin
+	keyboard := b.noticeKeyboard(ctx, n)
+	if err := b.notifyAdmins(bot, notification, keyboard); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionShadowReported, MetricLabelStatusFailed)
+		return fmt.Errorf("error notifying admins: %w", err)
 	}
+	b.metrics.IncProcessedAction(MetricLabelActionShadowReported, MetricLabelStatusSuccess)
 
 	return nil
 }
@@ -135,6 +300,26 @@ func (b *Bot) evaluateMessage(ctx context.Context, message *tgbotapi.Message) pl
 			Plugin:   "bot",
 		}
 	}
+	switch b.listed(ctx, message.From.ID) {
+	case userListAllowed:
+		return plugin.Result{
+			Action:   plugin.ActionAllow,
+			Reason:   "user allowed by admin",
+			Metadata: nil,
+			Plugin:   "bot",
+		}
+	case userListBlocked:
+		return plugin.Result{
+			Action: plugin.ActionBlock,
+			Reason: "user blocked by admin",
+			Metadata: map[string]any{
+				plugin.MetadataKeyRecommendation: plugin.RecommendationBanImmediately,
+				plugin.MetadataKeySeverity:       plugin.SeverityCritical,
+			},
+			Plugin: "bot",
+		}
+	case userListNone:
+	}
 
 	chatID := int64(0)
 	if message.Chat != nil {
@@ -162,15 +347,42 @@ func (b *Bot) evaluateMessage(ctx context.Context, message *tgbotapi.Message) pl
 				}
 				return nil
 			}(),
+			Entities:        entitiesToPlugin(message.Entities),
+			CaptionEntities: entitiesToPlugin(message.CaptionEntities),
+			Sender:          senderToPlugin(message.From),
+			SenderChatID: func() *int64 {
+				if message.SenderChat != nil {
+					return &message.SenderChat.ID
+				}
+				return nil
+			}(),
+			ViaBot:  viaBotToPlugin(message.ViaBot),
+			ReplyTo: replyToPlugin(message.ReplyToMessage),
+			Media:   mediaToPlugin(message),
+			Buttons: buttonsToPlugin(message.ReplyMarkup),
+
+			Source: "",
 		},
 	)
 
 	return result
 }
 
-func (b *Bot) notifyAdmins(bot *tgbotapifx.Bot, message string) error {
+// listed returns the list of the user, errors are logged and the user is treated as not listed.
+func (b *Bot) listed(ctx context.Context, userID int64) userList {
+	list, err := b.users.get(ctx, userID)
+	if err != nil {
+		b.logger.Error("error checking user list", zap.Int64("user_id", userID), zap.Error(err))
+	}
+
+	return list
+}
+
+// notifyAdmins sends the notification to admins with optional buttons.
+func (b *Bot) notifyAdmins(bot *tgbotapifx.Bot, message string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
 	notifyReq := tgbotapi.NewMessage(b.config.AdminID, message)
 	notifyReq.ParseMode = "HTML"
+	notifyReq.ReplyMarkup = keyboard
 
 	if _, err := bot.Send(notifyReq); err != nil {
 		return fmt.Errorf("error sending message: %w", err)
@@ -0,0 +1,295 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/pkg/tgbotapifx"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+)
+
+// Callback data prefixes of admin notification buttons.
+const (
+	callbackRepost  = "repost"
+	callbackBan     = "ban"
+	callbackAllow   = "allow"
+	callbackKeyword = "keyword"
+	callbackAddWord = "addword"
+	callbackExplain = "explain"
+)
+
+// keywordCandidatesLimit is the maximum number of words of a message offered to be added to the keyword list.
+const keywordCandidatesLimit = 8
+
+// keywordAdder is implemented by plugins accepting new keywords at runtime.
+type keywordAdder interface {
+	AddKeyword(ctx context.Context, keyword string) (bool, error)
+}
+
+// callbackFunc executes the action of a notification button and returns the answer text,
+// arg is the part of the callback data after the notice ID, if any.
+type callbackFunc func(ctx context.Context, bot *tgbotapifx.Bot, n notice, arg string) (string, error)
+
+// Callbacks returns the handlers of admin notification buttons by callback data prefix.
+func (b *Bot) Callbacks() map[string]tgbotapifx.Handler {
+	return map[string]tgbotapifx.Handler{
+		callbackRepost:  b.noticeCallback(b.repostCallback),
+		callbackBan:     b.noticeCallback(b.banCallback),
+		callbackAllow:   b.noticeCallback(b.allowCallback),
+		callbackKeyword: b.noticeCallback(b.keywordCallback),
+		callbackAddWord: b.noticeCallback(b.addWordCallback),
+		callbackExplain: b.noticeCallback(b.explainCallback),
+		callbackCaptcha: b.captchaCallback,
+	}
+}
+
+// noticeKeyboard stores the notice and returns the buttons of an admin notification about it,
+// nil if the notice can not be stored.
+func (b *Bot) noticeKeyboard(ctx context.Context, n notice) *tgbotapi.InlineKeyboardMarkup {
+	id, err := b.notices.add(ctx, n)
+	if err != nil {
+		b.logger.Warn("error storing notice, notification is sent without buttons", zap.Error(err))
+		return nil
+	}
+
+	first := []tgbotapi.InlineKeyboardButton{}
+	if n.Deleted {
+		first = append(first, tgbotapi.NewInlineKeyboardButtonData("False positive – repost", callbackRepost+":"+id))
+	}
+	first = append(first, tgbotapi.NewInlineKeyboardButtonData("Ban user", callbackBan+":"+id))
+
+	second := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("Whitelist user", callbackAllow+":"+id),
+	}
+	if n.Text != "" {
+		second = append(second, tgbotapi.NewInlineKeyboardButtonData("Add to keyword list", callbackKeyword+":"+id))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{first, second}
+	if len(n.Trace) > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Explain decision", callbackExplain+":"+id),
+		))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+// noticeCallback executes the button action for the admin and answers the callback query.
+func (b *Bot) noticeCallback(fn callbackFunc) tgbotapifx.Handler {
+	return func(ctx context.Context, bot *tgbotapifx.Bot, update tgbotapi.Update) error {
+		query := update.CallbackQuery
+		if query.From == nil || query.From.ID != b.config.AdminID {
+			return b.answerCallback(bot, query, "Not allowed")
+		}
+
+		_, data, _ := strings.Cut(query.Data, ":")
+		id, arg, _ := strings.Cut(data, ":")
+		n, ok, err := b.notices.get(ctx, id)
+		if err != nil {
+			b.logger.Warn("error loading notice", zap.String("data", query.Data), zap.Error(err))
+			return b.answerCallback(bot, query, "Error: "+err.Error())
+		}
+		if !ok {
+			return b.answerCallback(bot, query, "Notification expired")
+		}
+
+		b.logger.Info("notification button pressed",
+			zap.String("data", query.Data),
+			zap.Int64("chat_id", n.ChatID),
+			zap.Int64("user_id", n.UserID),
+		)
+
+		answer, err := fn(ctx, bot, n, arg)
+		if err != nil {
+			b.metrics.IncProcessedAction(MetricLabelActionCallbackHandled, MetricLabelStatusFailed)
+			b.logger.Warn("notification button failed", zap.String("data", query.Data), zap.Error(err))
+			return b.answerCallback(bot, query, "Error: "+err.Error())
+		}
+		b.metrics.IncProcessedAction(MetricLabelActionCallbackHandled, MetricLabelStatusSuccess)
+
+		// The button is removed so that the action is not repeated
+		if query.Message != nil && query.Message.ReplyMarkup != nil {
+			editReq := tgbotapi.NewEditMessageReplyMarkup(
+				query.Message.Chat.ID,
+				query.Message.MessageID,
+				withoutButton(*query.Message.ReplyMarkup, query.Data),
+			)
+			if _, editErr := bot.Request(editReq); editErr != nil {
+				b.logger.Warn("error updating notification buttons", zap.Error(editErr))
+			}
+		}
+
+		return b.answerCallback(bot, query, answer)
+	}
+}
+
+func (b *Bot) answerCallback(bot *tgbotapifx.Bot, query *tgbotapi.CallbackQuery, text string) error {
+	if _, err := bot.Request(tgbotapi.NewCallback(query.ID, text)); err != nil {
+		return fmt.Errorf("error answering callback query: %w", err)
+	}
+
+	return nil
+}
+
+// repostCallback undoes the moderation of a false positive and reposts the message.
+func (b *Bot) repostCallback(_ context.Context, bot *tgbotapifx.Bot, n notice, _ string) (string, error) {
+	if n.Step.Action == ActionMute || n.Step.Action == ActionKick || n.Step.Action == ActionBan {
+		if err := b.unban(bot, n.ChatID, n.UserID); err != nil {
+			return "", err
+		}
+	}
+
+	if err := b.storage.Delete(strconv.FormatInt(n.UserID, 10)); err != nil {
+		return "", fmt.Errorf("error resetting violation count: %w", err)
+	}
+
+	repost := tgbotapi.NewMessage(
+		n.ChatID,
+		fmt.Sprintf("Restored message from %s:\n%s", n.User, html.EscapeString(n.Text)),
+	)
+	repost.ParseMode = tgbotapi.ModeHTML
+	if _, err := bot.Send(repost); err != nil {
+		return "", fmt.Errorf("error reposting message: %w", err)
+	}
+
+	return "Message restored", nil
+}
+
+// banCallback bans the user in the chat forever.
+func (b *Bot) banCallback(_ context.Context, bot *tgbotapifx.Bot, n notice, _ string) (string, error) {
+	if err := b.ban(bot, n.ChatID, n.UserID, 0); err != nil {
+		return "", err
+	}
+
+	return "User banned", nil
+}
+
+// allowCallback stops censoring messages of the user.
+func (b *Bot) allowCallback(ctx context.Context, _ *tgbotapifx.Bot, n notice, _ string) (string, error) {
+	if err := b.allow(ctx, n.UserID); err != nil {
+		return "", err
+	}
+
+	return "User whitelisted", nil
+}
+
+// keywordCallback offers the admin the words of the message to choose the keyword to add.
+func (b *Bot) keywordCallback(ctx context.Context, bot *tgbotapifx.Bot, n notice, _ string) (string, error) {
+	if _, err := b.keywordPlugin(n.ChatID); err != nil {
+		return "", err
+	}
+
+	candidates := keywordCandidates(n.Text)
+	if len(candidates) == 0 {
+		return "No words to add", nil
+	}
+
+	id, err := b.notices.add(ctx, n)
+	if err != nil {
+		return "", err
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(candidates))
+	for i, candidate := range candidates {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(candidate, callbackAddWord+":"+id+":"+strconv.Itoa(i)),
+		))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	text := fmt.Sprintf("Choose the keyword to add from the message of %s:", n.User)
+	if ntfErr := b.notifyAdmins(bot, text, &keyboard); ntfErr != nil {
+		return "", ntfErr
+	}
+
+	return "Choose the keyword", nil
+}
+
+// addWordCallback adds the keyword chosen by the admin to the keyword plugin effective for the chat.
+func (b *Bot) addWordCallback(ctx context.Context, _ *tgbotapifx.Bot, n notice, arg string) (string, error) {
+	adder, err := b.keywordPlugin(n.ChatID)
+	if err != nil {
+		return "", err
+	}
+
+	candidates := keywordCandidates(n.Text)
+	i, err := strconv.Atoi(arg)
+	if err != nil || i < 0 || i >= len(candidates) {
+		return "", fmt.Errorf("%w: unknown keyword", ErrInvalidCommand)
+	}
+
+	added, err := adder.AddKeyword(ctx, candidates[i])
+	if err != nil {
+		return "", fmt.Errorf("error adding keyword: %w", err)
+	}
+	if !added {
+		return "Already in keyword list", nil
+	}
+
+	return fmt.Sprintf("Added %q to keyword list", candidates[i]), nil
+}
+
+// keywordPlugin returns the keyword plugin effective for the chat.
+func (b *Bot) keywordPlugin(chatID int64) (keywordAdder, error) {
+	_, plugins := b.censor.GetChatPlugins(chatID)
+
+	p, ok := lo.Find(plugins, func(p plugin.Plugin) bool { return p.Name() == "keyword" })
+	if !ok {
+		return nil, fmt.Errorf("%w: keyword plugin is not enabled", ErrInvalidCommand)
+	}
+
+	adder, ok := p.(keywordAdder)
+	if !ok {
+		return nil, fmt.Errorf("%w: keyword plugin does not accept keywords", ErrInvalidCommand)
+	}
+
+	return adder, nil
+}
+
+// explainCallback sends the trace of the evaluation to the admin.
+func (b *Bot) explainCallback(_ context.Context, bot *tgbotapifx.Bot, n notice, _ string) (string, error) {
+	text := fmt.Sprintf("Evaluation trace of the message from %s:\n%s", n.User, traceToString(n.Trace))
+	if err := b.notifyAdmins(bot, text, nil); err != nil {
+		return "", err
+	}
+
+	return "Trace sent", nil
+}
+
+// keywordCandidates returns the distinct words of the text, which may be added to the keyword list,
+// in the order of appearance. Short words are skipped, as they would match too many messages.
+func keywordCandidates(text string) []string {
+	const minLength = 3
+
+	words := lo.Map(strings.Fields(strings.ToLower(text)), func(word string, _ int) string {
+		return strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	})
+	words = lo.Uniq(lo.Filter(words, func(word string, _ int) bool { return utf8.RuneCountInString(word) >= minLength }))
+
+	return lo.Slice(words, 0, keywordCandidatesLimit)
+}
+
+// withoutButton returns the keyboard without the button with the callback data.
+func withoutButton(keyboard tgbotapi.InlineKeyboardMarkup, data string) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(keyboard.InlineKeyboard))
+	for _, row := range keyboard.InlineKeyboard {
+		row = lo.Reject(row, func(button tgbotapi.InlineKeyboardButton, _ int) bool {
+			return button.CallbackData != nil && *button.CallbackData == data
+		})
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
@@ -0,0 +1,228 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/captcha"
+	"github.com/capcom6/censor-tg-bot/pkg/tgbotapifx"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+)
+
+// callbackCaptcha is the callback data prefix of challenge buttons, followed by the challenge ID and the option.
+const callbackCaptcha = "captcha"
+
+// captchaButtonsPerRow is the maximum number of challenge options in a keyboard row.
+const captchaButtonsPerRow = 3
+
+// captchaMuteMargin is how long new members stay restricted after their challenge expires,
+// so that they are kicked before they can write even if the bot was not running.
+const captchaMuteMargin = 10 * time.Minute
+
+// Chat member statuses of the Bot API.
+const (
+	memberStatusMember     = "member"
+	memberStatusRestricted = "restricted"
+	memberStatusLeft       = "left"
+	memberStatusKicked     = "kicked"
+)
+
+// joinedMembers returns the chat and the users who joined it with the update.
+// A join is reported by a service message with new members and by a chat member update if it is allowed.
+func joinedMembers(update tgbotapi.Update) (*tgbotapi.Chat, []tgbotapi.User) {
+	if update.Message != nil && len(update.Message.NewChatMembers) > 0 {
+		return update.Message.Chat, update.Message.NewChatMembers
+	}
+
+	if u := update.ChatMember; u != nil {
+		wasMember := u.OldChatMember.Status != memberStatusLeft && u.OldChatMember.Status != memberStatusKicked &&
+			(u.OldChatMember.Status != memberStatusRestricted || u.OldChatMember.IsMember)
+		isMember := u.NewChatMember.Status == memberStatusMember ||
+			(u.NewChatMember.Status == memberStatusRestricted && u.NewChatMember.IsMember)
+
+		if !wasMember && isMember && u.NewChatMember.User != nil {
+			return &u.Chat, []tgbotapi.User{*u.NewChatMember.User}
+		}
+	}
+
+	return nil, nil
+}
+
+// challengeMembers restricts the new members until they solve a challenge.
+// Bots and users allowed by admins are not challenged.
+func (b *Bot) challengeMembers(
+	ctx context.Context,
+	bot *tgbotapifx.Bot,
+	chat *tgbotapi.Chat,
+	users []tgbotapi.User,
+) error {
+	for _, user := range users {
+		if user.IsBot || b.listed(ctx, user.ID) == userListAllowed {
+			continue
+		}
+
+		challenge, ok, err := b.captcha.Start(ctx, chat.ID, user.ID)
+		if err != nil {
+			b.metrics.IncProcessedAction(MetricLabelActionCaptchaSent, MetricLabelStatusFailed)
+			return fmt.Errorf("error starting challenge: %w", err)
+		}
+		if !ok {
+			continue
+		}
+
+		if sendErr := b.sendChallenge(ctx, bot, challenge, &user); sendErr != nil {
+			if cancelErr := b.captcha.Cancel(ctx, challenge.ID); cancelErr != nil {
+				b.logger.Warn("error canceling challenge", zap.String("challenge_id", challenge.ID), zap.Error(cancelErr))
+			}
+			b.metrics.IncProcessedAction(MetricLabelActionCaptchaSent, MetricLabelStatusFailed)
+			return sendErr
+		}
+		b.metrics.IncProcessedAction(MetricLabelActionCaptchaSent, MetricLabelStatusSuccess)
+
+		b.logger.Info("captcha sent",
+			zap.Int64("chat_id", chat.ID),
+			zap.Int64("user_id", user.ID),
+			zap.String("challenge_id", challenge.ID),
+		)
+	}
+
+	return nil
+}
+
+// sendChallenge restricts the user until shortly after the challenge expires and sends the challenge to the chat.
+func (b *Bot) sendChallenge(
+	ctx context.Context,
+	bot *tgbotapifx.Bot,
+	challenge captcha.Challenge,
+	user *tgbotapi.User,
+) error {
+	if err := b.mute(bot, challenge.ChatID, challenge.UserID, b.captcha.Timeout()+captchaMuteMargin); err != nil {
+		return err
+	}
+
+	buttons := lo.Map(challenge.Options, func(option string, i int) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(
+			option,
+			callbackCaptcha+":"+challenge.ID+":"+strconv.Itoa(i),
+		)
+	})
+
+	message := tgbotapi.NewMessage(
+		challenge.ChatID,
+		fmt.Sprintf(
+			"Welcome, %s! %s\nAnswer within %s to be able to write in the chat.",
+			userToString(user),
+			challenge.Question,
+			b.captcha.Timeout(),
+		),
+	)
+	message.ParseMode = tgbotapi.ModeHTML
+	message.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(lo.Chunk(buttons, captchaButtonsPerRow)...)
+
+	sent, err := bot.Send(message)
+	if err != nil {
+		return fmt.Errorf("error sending challenge: %w", err)
+	}
+	if setErr := b.captcha.SetMessageID(ctx, challenge.ID, sent.MessageID); setErr != nil {
+		return fmt.Errorf("error saving challenge message: %w", setErr)
+	}
+
+	return nil
+}
+
+// captchaCallback checks the option picked by a new member.
+func (b *Bot) captchaCallback(ctx context.Context, bot *tgbotapifx.Bot, update tgbotapi.Update) error {
+	query := update.CallbackQuery
+
+	_, data, _ := strings.Cut(query.Data, ":")
+	id, optionStr, _ := strings.Cut(data, ":")
+	option, err := strconv.Atoi(optionStr)
+	if err != nil || query.From == nil {
+		return b.answerCallback(bot, query, "Invalid challenge")
+	}
+
+	challenge, outcome, err := b.captcha.Answer(ctx, id, query.From.ID, option)
+	if err != nil {
+		return fmt.Errorf("error answering challenge: %w", err)
+	}
+
+	b.logger.Info("captcha answered",
+		zap.Int64("chat_id", challenge.ChatID),
+		zap.Int64("user_id", query.From.ID),
+		zap.String("challenge_id", id),
+		zap.String("outcome", string(outcome)),
+	)
+
+	switch outcome {
+	case captcha.OutcomeNotFound:
+		return b.answerCallback(bot, query, "The challenge has expired")
+	case captcha.OutcomeNotYours:
+		return b.answerCallback(bot, query, "This challenge is not for you")
+	case captcha.OutcomePassed:
+		b.deleteChallenge(bot, challenge)
+		if unmuteErr := b.unmute(bot, challenge.ChatID, challenge.UserID); unmuteErr != nil {
+			b.metrics.IncProcessedAction(MetricLabelActionCaptchaPassed, MetricLabelStatusFailed)
+			return unmuteErr
+		}
+		b.metrics.IncProcessedAction(MetricLabelActionCaptchaPassed, MetricLabelStatusSuccess)
+
+		return b.answerCallback(bot, query, "Welcome to the chat!")
+	case captcha.OutcomeFailed:
+		b.deleteChallenge(bot, challenge)
+		if kickErr := b.kick(bot, challenge.ChatID, challenge.UserID); kickErr != nil {
+			b.metrics.IncProcessedAction(MetricLabelActionCaptchaFailed, MetricLabelStatusFailed)
+			return kickErr
+		}
+		b.metrics.IncProcessedAction(MetricLabelActionCaptchaFailed, MetricLabelStatusSuccess)
+
+		return b.answerCallback(bot, query, "Wrong answer")
+	}
+
+	return nil
+}
+
+// ExpireChallenges kicks the new members who did not solve their challenges in time,
+// including those whose challenges expired while the bot was not running.
+func (b *Bot) ExpireChallenges(ctx context.Context, bot *tgbotapifx.Bot) {
+	expired, err := b.captcha.Expired(ctx)
+	if err != nil {
+		b.logger.Error("error checking expired challenges", zap.Error(err))
+	}
+
+	for _, challenge := range expired {
+		b.deleteChallenge(bot, challenge)
+
+		if err := b.kick(bot, challenge.ChatID, challenge.UserID); err != nil {
+			b.metrics.IncProcessedAction(MetricLabelActionCaptchaExpired, MetricLabelStatusFailed)
+			b.logger.Error("error kicking user with expired captcha",
+				zap.Int64("chat_id", challenge.ChatID),
+				zap.Int64("user_id", challenge.UserID),
+				zap.Error(err),
+			)
+			continue
+		}
+		b.metrics.IncProcessedAction(MetricLabelActionCaptchaExpired, MetricLabelStatusSuccess)
+
+		b.logger.Info("captcha expired",
+			zap.Int64("chat_id", challenge.ChatID),
+			zap.Int64("user_id", challenge.UserID),
+			zap.String("challenge_id", challenge.ID),
+		)
+	}
+}
+
+// deleteChallenge removes the challenge message from the chat.
+func (b *Bot) deleteChallenge(bot *tgbotapifx.Bot, challenge captcha.Challenge) {
+	if challenge.MessageID == 0 {
+		return
+	}
+
+	if _, err := bot.Request(tgbotapi.NewDeleteMessage(challenge.ChatID, challenge.MessageID)); err != nil {
+		b.logger.Warn("error deleting challenge message", zap.Error(err))
+	}
+}
@@ -0,0 +1,324 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/pkg/tgbotapifx"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+)
+
+// commandFunc executes an admin command and returns the reply text.
+type commandFunc func(ctx context.Context, bot *tgbotapifx.Bot, message *tgbotapi.Message, args []string) (string, error)
+
+// Commands returns the handlers of admin commands by name.
+func (b *Bot) Commands() map[string]tgbotapifx.Handler {
+	return map[string]tgbotapifx.Handler{
+		"ban":     b.adminCommand(b.banCommand),
+		"unban":   b.adminCommand(b.unbanCommand),
+		"mute":    b.adminCommand(b.muteCommand),
+		"allow":   b.adminCommand(b.allowCommand),
+		"block":   b.adminCommand(b.blockCommand),
+		"forgive": b.adminCommand(b.forgiveCommand),
+		"status":  b.adminCommand(b.statusCommand),
+	}
+}
+
+// adminCommand executes the command for the admin and replies with its result,
+// commands of other users are handled as regular messages.
+func (b *Bot) adminCommand(fn commandFunc) tgbotapifx.Handler {
+	return func(ctx context.Context, bot *tgbotapifx.Bot, update tgbotapi.Update) error {
+		message := update.Message
+		if message.From == nil || message.From.ID != b.config.AdminID {
+			return b.Handler(ctx, bot, update)
+		}
+
+		b.logger.Info("admin command",
+			zap.String("command", message.Command()),
+			zap.String("arguments", message.CommandArguments()),
+			zap.Int64("chat_id", message.Chat.ID),
+		)
+
+		reply, err := fn(ctx, bot, message, strings.Fields(message.CommandArguments()))
+		if err != nil {
+			b.metrics.IncProcessedAction(MetricLabelActionCommandHandled, MetricLabelStatusFailed)
+			b.logger.Warn("admin command failed", zap.String("command", message.Command()), zap.Error(err))
+			reply = "Error: " + html.EscapeString(err.Error())
+		} else {
+			b.metrics.IncProcessedAction(MetricLabelActionCommandHandled, MetricLabelStatusSuccess)
+		}
+
+		replyReq := tgbotapi.NewMessage(message.Chat.ID, reply)
+		replyReq.ParseMode = tgbotapi.ModeHTML
+		replyReq.ReplyToMessageID = message.MessageID
+		if _, sendErr := bot.Send(replyReq); sendErr != nil {
+			return fmt.Errorf("error replying to command: %w", sendErr)
+		}
+
+		return nil
+	}
+}
+
+// banCommand handles "/ban [user] [duration]" in a group chat.
+func (b *Bot) banCommand(
+	_ context.Context,
+	bot *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	args []string,
+) (string, error) {
+	userID, args, err := commandTarget(message, args)
+	if err != nil {
+		return "", err
+	}
+	duration, err := commandDuration(args, 0)
+	if err != nil {
+		return "", err
+	}
+	if chatErr := requireGroup(message); chatErr != nil {
+		return "", chatErr
+	}
+
+	if banErr := b.ban(bot, message.Chat.ID, userID, duration); banErr != nil {
+		return "", banErr
+	}
+
+	return "Applied " + stepToString(Step{Violations: 0, Action: ActionBan, Duration: duration, Silent: false}) +
+		" to " + userIDToString(userID), nil
+}
+
+// unbanCommand handles "/unban [user]" in a group chat.
+func (b *Bot) unbanCommand(
+	_ context.Context,
+	bot *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	args []string,
+) (string, error) {
+	userID, _, err := commandTarget(message, args)
+	if err != nil {
+		return "", err
+	}
+	if chatErr := requireGroup(message); chatErr != nil {
+		return "", chatErr
+	}
+
+	if unbanErr := b.unban(bot, message.Chat.ID, userID); unbanErr != nil {
+		return "", unbanErr
+	}
+
+	return "Unbanned " + userIDToString(userID), nil
+}
+
+// muteCommand handles "/mute [user] [duration]" in a group chat.
+func (b *Bot) muteCommand(
+	_ context.Context,
+	bot *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	args []string,
+) (string, error) {
+	userID, args, err := commandTarget(message, args)
+	if err != nil {
+		return "", err
+	}
+	duration, err := commandDuration(args, b.config.MuteDuration)
+	if err != nil {
+		return "", err
+	}
+	if chatErr := requireGroup(message); chatErr != nil {
+		return "", chatErr
+	}
+
+	if muteErr := b.mute(bot, message.Chat.ID, userID, duration); muteErr != nil {
+		return "", muteErr
+	}
+
+	return "Applied " + stepToString(Step{Violations: 0, Action: ActionMute, Duration: duration, Silent: false}) +
+		" to " + userIDToString(userID), nil
+}
+
+// allowCommand handles "/allow [user]", messages of the user are no longer censored.
+func (b *Bot) allowCommand(
+	ctx context.Context,
+	_ *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	args []string,
+) (string, error) {
+	userID, _, err := commandTarget(message, args)
+	if err != nil {
+		return "", err
+	}
+
+	if allowErr := b.allow(ctx, userID); allowErr != nil {
+		return "", allowErr
+	}
+
+	return "Allowed " + userIDToString(userID), nil
+}
+
+// blockCommand handles "/block [user]", the user is banned on the next message.
+func (b *Bot) blockCommand(
+	ctx context.Context,
+	_ *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	args []string,
+) (string, error) {
+	userID, _, err := commandTarget(message, args)
+	if err != nil {
+		return "", err
+	}
+
+	if setErr := b.users.set(ctx, userID, userListBlocked); setErr != nil {
+		return "", setErr
+	}
+
+	return "Blocked " + userIDToString(userID), nil
+}
+
+// allow moves the user to the allowed list.
+func (b *Bot) allow(ctx context.Context, userID int64) error {
+	return b.users.set(ctx, userID, userListAllowed)
+}
+
+// forgiveCommand handles "/forgive [user]", resetting the violation count of the user.
+func (b *Bot) forgiveCommand(
+	_ context.Context,
+	_ *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	args []string,
+) (string, error) {
+	userID, _, err := commandTarget(message, args)
+	if err != nil {
+		return "", err
+	}
+
+	if delErr := b.storage.Delete(strconv.FormatInt(userID, 10)); delErr != nil {
+		return "", fmt.Errorf("error resetting violation count: %w", delErr)
+	}
+
+	return "Forgave " + userIDToString(userID), nil
+}
+
+// statusCommand handles "/status [user]", reporting the state of the bot or the user.
+func (b *Bot) statusCommand(
+	ctx context.Context,
+	_ *tgbotapifx.Bot,
+	message *tgbotapi.Message,
+	args []string,
+) (string, error) {
+	userID, _, err := commandTarget(message, args)
+	if err == nil {
+		return b.userStatus(ctx, userID)
+	}
+	if !errors.Is(err, ErrInvalidCommand) || len(args) > 0 {
+		return "", err
+	}
+
+	allowed, err := b.users.list(ctx, userListAllowed)
+	if err != nil {
+		return "", err
+	}
+	blocked, err := b.users.list(ctx, userListBlocked)
+	if err != nil {
+		return "", err
+	}
+
+	config := b.censor.GetConfig()
+	plugins := lo.Map(b.censor.GetPlugins(), func(p plugin.Plugin, _ int) string {
+		if config.Plugins[p.Name()].Shadow {
+			return p.Name() + " (shadow)"
+		}
+		return p.Name()
+	})
+
+	return fmt.Sprintf(
+		"Dry run: %t\nStrategy: %s\nPlugins: %s\nEscalation: %s\nAllowed users: %d\nBlocked users: %d",
+		b.config.DryRun,
+		config.Strategy,
+		strings.Join(plugins, ", "),
+		strings.Join(lo.Map(b.config.Steps(), func(s Step, _ int) string {
+			return strconv.Itoa(s.Violations) + ": " + stepToString(s)
+		}), ", "),
+		len(allowed),
+		len(blocked),
+	), nil
+}
+
+func (b *Bot) userStatus(ctx context.Context, userID int64) (string, error) {
+	cnt, err := b.storage.Get(strconv.FormatInt(userID, 10))
+	if err != nil {
+		return "", fmt.Errorf("error getting violation count: %w", err)
+	}
+
+	history, err := b.storage.History(strconv.FormatInt(userID, 10))
+	if err != nil {
+		return "", fmt.Errorf("error getting violation history: %w", err)
+	}
+
+	list, err := b.users.get(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	last := "never"
+	if len(history) > 0 {
+		last = history[len(history)-1].UTC().Format(time.RFC3339)
+	}
+
+	return fmt.Sprintf(
+		"User: %s\nViolations: %d\nRecorded violations: %d\nLast violation: %s\nAllowed: %t\nBlocked: %t",
+		userIDToString(userID),
+		cnt,
+		len(history),
+		last,
+		list == userListAllowed,
+		list == userListBlocked,
+	), nil
+}
+
+// commandTarget returns the user the command is applied to: the sender of the replied message
+// or the user ID in the first argument, and the remaining arguments.
+func commandTarget(message *tgbotapi.Message, args []string) (int64, []string, error) {
+	if message.ReplyToMessage != nil && message.ReplyToMessage.From != nil {
+		return message.ReplyToMessage.From.ID, args, nil
+	}
+
+	if len(args) == 0 {
+		return 0, nil, fmt.Errorf("%w: reply to a message or specify a user ID", ErrInvalidCommand)
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: invalid user ID: %s", ErrInvalidCommand, args[0])
+	}
+
+	return userID, args[1:], nil
+}
+
+// commandDuration parses the optional duration argument.
+func commandDuration(args []string, defaultValue time.Duration) (time.Duration, error) {
+	if len(args) == 0 {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(args[0])
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%w: invalid duration: %s", ErrInvalidCommand, args[0])
+	}
+
+	return duration, nil
+}
+
+func requireGroup(message *tgbotapi.Message) error {
+	if message.Chat == nil || (!message.Chat.IsGroup() && !message.Chat.IsSuperGroup()) {
+		return fmt.Errorf("%w: the command must be sent in a group chat", ErrInvalidCommand)
+	}
+
+	return nil
+}
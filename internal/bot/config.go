@@ -1,6 +1,164 @@
 package bot
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
+)
+
+// Action is a moderation action applied to a user whose message was blocked.
+type Action string
+
+const (
+	ActionWarn   Action = "warn"   // reply to the message with a warning
+	ActionDelete Action = "delete" // delete the message
+	ActionMute   Action = "mute"   // delete the message and restrict the user for the step duration
+	ActionKick   Action = "kick"   // delete the message and remove the user from the chat, allowing to rejoin
+	ActionBan    Action = "ban"    // delete the message and ban the user for the step duration, forever if zero
+)
+
+func (a Action) IsValid() bool {
+	switch a {
+	case ActionWarn, ActionDelete, ActionMute, ActionKick, ActionBan:
+		return true
+	default:
+		return false
+	}
+}
+
+// Step is a rung of the escalation ladder, applied when the user's violation count reaches Violations.
+type Step struct {
+	Violations int
+	Action     Action
+	Duration   time.Duration // mute or ban duration
+	Silent     bool          // do not notify admins
+}
+
 type Config struct {
 	AdminID      int64
 	BanThreshold uint8
+	DryRun       bool          // report blocked messages to admins without deleting them or banning users
+	Escalation   []Step        // escalation ladder, defaults to delete and ban after BanThreshold violations
+	MuteDuration time.Duration // duration of mutes recommended by plugins, forever if zero
+}
+
+// Steps returns the escalation ladder in ascending order of violation counts.
+func (c Config) Steps() []Step {
+	if len(c.Escalation) == 0 {
+		return []Step{
+			{Violations: 1, Action: ActionDelete, Duration: 0, Silent: false},
+			{Violations: max(int(c.BanThreshold), 1), Action: ActionBan, Duration: 0, Silent: false},
+		}
+	}
+
+	return c.Escalation
+}
+
+// StepFor returns the highest escalation step reached by the violation count, the first one if none is reached.
+func (c Config) StepFor(violations int) Step {
+	steps := c.Steps()
+
+	step := steps[0]
+	for _, s := range steps {
+		if violations >= s.Violations {
+			step = s
+		}
+	}
+
+	return step
+}
+
+// StepForResult returns the step to apply to a blocked message: the moderation action recommended
+// by the plugin if any, the escalation step reached by the violation count otherwise.
+// Messages with related messages, e.g. the message completing a spam wave, are deleted at least,
+// so that the whole wave is removed.
+func (c Config) StepForResult(result plugin.Result, violations int) Step {
+	step := c.stepForResult(result, violations)
+	if step.Action == ActionWarn && len(result.Related()) > 0 {
+		step.Action = ActionDelete
+	}
+
+	return step
+}
+
+func (c Config) stepForResult(result plugin.Result, violations int) Step {
+	step := Step{Violations: violations, Action: "", Duration: 0, Silent: false}
+
+	switch result.Recommendation() {
+	case plugin.RecommendationWarnOnly:
+		step.Action = ActionWarn
+	case plugin.RecommendationDeleteSilently:
+		step.Action = ActionDelete
+		step.Silent = true
+	case plugin.RecommendationMute:
+		step.Action = ActionMute
+		step.Duration = c.MuteDuration
+	case plugin.RecommendationBanImmediately:
+		step.Action = ActionBan
+	case plugin.RecommendationNone:
+		return c.StepFor(violations)
+	}
+
+	return step
+}
+
+// CountsViolation reports whether the blocked message counts as a violation of its sender.
+// Silent deletions recommended by plugins, e.g. of newcomers during a lockdown or of the later
+// messages of a spam wave, are not counted, like the earlier messages deleted with the wave.
+func (c Config) CountsViolation(result plugin.Result) bool {
+	return result.Recommendation() != plugin.RecommendationDeleteSilently
+}
+
+// LockdownForResult returns the lockdown requested by a plugin during the evaluation, if any.
+// Requests of shadow plugins are ignored, as well as all requests if the message was allowed by a plugin.
+func (c Config) LockdownForResult(result plugin.Result) (lockdown.Lockdown, bool) {
+	var (
+		requested lockdown.Lockdown
+		ok        bool
+	)
+
+	for _, entry := range censor.Trace(result) {
+		if entry.Shadow {
+			continue
+		}
+		if entry.Action == plugin.ActionAllow {
+			return lockdown.Lockdown{}, false //nolint:exhaustruct // no lockdown
+		}
+		if !ok {
+			requested, ok = entry.Metadata[lockdown.MetadataKey].(lockdown.Lockdown)
+		}
+	}
+
+	return requested, ok
+}
+
+// Validate checks if the configuration is valid.
+func (c Config) Validate() error {
+	previous := 0
+	for i, step := range c.Escalation {
+		if !step.Action.IsValid() {
+			return fmt.Errorf("%w: invalid action of escalation step %d: %s", ErrInvalidConfig, i, step.Action)
+		}
+
+		if step.Violations <= previous {
+			return fmt.Errorf(
+				"%w: violations of escalation step %d must be greater than %d, got: %d",
+				ErrInvalidConfig, i, previous, step.Violations,
+			)
+		}
+		previous = step.Violations
+
+		if step.Duration < 0 {
+			return fmt.Errorf("%w: invalid duration of escalation step %d: %s", ErrInvalidConfig, i, step.Duration)
+		}
+	}
+
+	if c.MuteDuration < 0 {
+		return fmt.Errorf("%w: invalid mute duration: %s", ErrInvalidConfig, c.MuteDuration)
+	}
+
+	return nil
 }
@@ -0,0 +1,188 @@
+package bot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/bot"
+	"github.com/capcom6/censor-tg-bot/internal/censor"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_StepFor(t *testing.T) {
+	ladder := bot.Config{
+		Escalation: []bot.Step{
+			{Violations: 1, Action: bot.ActionWarn},
+			{Violations: 2, Action: bot.ActionDelete},
+			{Violations: 3, Action: bot.ActionMute, Duration: 10 * time.Minute},
+			{Violations: 4, Action: bot.ActionKick},
+			{Violations: 5, Action: bot.ActionBan, Duration: 24 * time.Hour},
+			{Violations: 6, Action: bot.ActionBan},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		config     bot.Config
+		violations int
+		expected   bot.Step
+	}{
+		{"default first violation", bot.Config{BanThreshold: 3}, 1, bot.Step{Violations: 1, Action: bot.ActionDelete}},
+		{"default below threshold", bot.Config{BanThreshold: 3}, 2, bot.Step{Violations: 1, Action: bot.ActionDelete}},
+		{"default threshold reached", bot.Config{BanThreshold: 3}, 3, bot.Step{Violations: 3, Action: bot.ActionBan}},
+		{"ladder first step", ladder, 1, ladder.Escalation[0]},
+		{"ladder mute", ladder, 3, ladder.Escalation[2]},
+		{"ladder permanent ban", ladder, 10, ladder.Escalation[5]},
+		{"count unavailable", ladder, 0, ladder.Escalation[0]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.config.StepFor(tt.violations))
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []bot.Step
+		wantErr bool
+	}{
+		{"default ladder", nil, false},
+		{"valid ladder", []bot.Step{{Violations: 1, Action: bot.ActionWarn}, {Violations: 2, Action: bot.ActionBan}}, false},
+		{"invalid action", []bot.Step{{Violations: 1, Action: "unknown"}}, true},
+		{"zero violations", []bot.Step{{Violations: 0, Action: bot.ActionWarn}}, true},
+		{"unordered", []bot.Step{{Violations: 2, Action: bot.ActionWarn}, {Violations: 1, Action: bot.ActionBan}}, true},
+		{"negative duration", []bot.Step{{Violations: 1, Action: bot.ActionMute, Duration: -time.Minute}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bot.Config{Escalation: tt.steps}.Validate()
+			if tt.wantErr {
+				require.ErrorIs(t, err, bot.ErrInvalidConfig)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_StepForResult(t *testing.T) {
+	config := bot.Config{BanThreshold: 3, MuteDuration: time.Hour}
+
+	recommend := func(rec plugin.Recommendation) plugin.Result {
+		return plugin.Result{
+			Action:   plugin.ActionBlock,
+			Metadata: map[string]any{plugin.MetadataKeyRecommendation: rec},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		result   plugin.Result
+		expected bot.Step
+	}{
+		{"no recommendation", plugin.Result{Action: plugin.ActionBlock}, bot.Step{Violations: 1, Action: bot.ActionDelete}},
+		{"warn only", recommend(plugin.RecommendationWarnOnly), bot.Step{Violations: 1, Action: bot.ActionWarn}},
+		{
+			"delete silently",
+			recommend(plugin.RecommendationDeleteSilently),
+			bot.Step{Violations: 1, Action: bot.ActionDelete, Silent: true},
+		},
+		{
+			"mute",
+			recommend(plugin.RecommendationMute),
+			bot.Step{Violations: 1, Action: bot.ActionMute, Duration: time.Hour},
+		},
+		{"ban immediately", recommend(plugin.RecommendationBanImmediately), bot.Step{Violations: 1, Action: bot.ActionBan}},
+		{"unknown recommendation", recommend("unknown"), bot.Step{Violations: 1, Action: bot.ActionDelete}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, config.StepForResult(tt.result, 1))
+		})
+	}
+
+	// A spam wave is deleted even if the escalation starts with a warning
+	warnFirst := bot.Config{
+		BanThreshold: 3,
+		Escalation:   []bot.Step{{Violations: 1, Action: bot.ActionWarn}, {Violations: 2, Action: bot.ActionBan}},
+	}
+	wave := plugin.Result{
+		Action: plugin.ActionBlock,
+		Metadata: map[string]any{
+			plugin.MetadataKeyRelated: []plugin.MessageRef{{ChatID: -1001, UserID: 2, MessageID: 10}},
+		},
+	}
+	require.Equal(t, bot.ActionWarn, warnFirst.StepForResult(plugin.Result{Action: plugin.ActionBlock}, 1).Action)
+	require.Equal(t, bot.Step{Violations: 1, Action: bot.ActionDelete}, warnFirst.StepForResult(wave, 1))
+	require.Equal(t, bot.ActionBan, warnFirst.StepForResult(wave, 2).Action)
+}
+
+func TestConfig_CountsViolation(t *testing.T) {
+	config := bot.Config{BanThreshold: 3}
+
+	wave := plugin.Result{
+		Action: plugin.ActionBlock,
+		Metadata: map[string]any{
+			plugin.MetadataKeyRelated: []plugin.MessageRef{{ChatID: -1001, UserID: 2, MessageID: 10}},
+		},
+	}
+	later := plugin.Result{
+		Action:   plugin.ActionBlock,
+		Metadata: map[string]any{plugin.MetadataKeyRecommendation: plugin.RecommendationDeleteSilently},
+	}
+
+	// The message completing a spam wave is escalated against, while the later messages of the wave
+	// are deleted silently like the earlier ones, without counting violations of their senders
+	require.True(t, config.CountsViolation(wave))
+	require.False(t, config.CountsViolation(later))
+	require.True(t, config.CountsViolation(plugin.Result{
+		Action:   plugin.ActionBlock,
+		Metadata: map[string]any{plugin.MetadataKeyRecommendation: plugin.RecommendationMute},
+	}))
+}
+
+func TestConfig_LockdownForResult(t *testing.T) {
+	config := bot.Config{BanThreshold: 3}
+
+	requested := lockdown.Lockdown{ChatID: 1, Reason: "spike", Restriction: lockdown.RestrictionReadOnly}
+	raid := censor.TraceEntry{
+		Plugin:   "raid",
+		Action:   plugin.ActionSkip,
+		Metadata: map[string]any{lockdown.MetadataKey: requested},
+	}
+	withTrace := func(entries ...censor.TraceEntry) plugin.Result {
+		return plugin.Result{
+			Action:   plugin.ActionSkip,
+			Metadata: map[string]any{censor.MetadataKeyTrace: entries},
+		}
+	}
+
+	l, ok := config.LockdownForResult(withTrace(raid, censor.TraceEntry{Plugin: "keyword", Action: plugin.ActionSkip}))
+	require.True(t, ok)
+	require.Equal(t, requested, l)
+
+	// Lockdowns requested by shadow plugins are not started
+	shadow := raid
+	shadow.Shadow = true
+	_, ok = config.LockdownForResult(withTrace(shadow))
+	require.False(t, ok)
+
+	// Nor are lockdowns requested for messages allowed by a plugin, unless the plugin is in shadow mode
+	_, ok = config.LockdownForResult(withTrace(raid, censor.TraceEntry{Plugin: "users", Action: plugin.ActionAllow}))
+	require.False(t, ok)
+	_, ok = config.LockdownForResult(withTrace(
+		raid,
+		censor.TraceEntry{Plugin: "users", Action: plugin.ActionAllow, Shadow: true},
+	))
+	require.True(t, ok)
+
+	_, ok = config.LockdownForResult(plugin.Result{Action: plugin.ActionSkip})
+	require.False(t, ok)
+}
@@ -0,0 +1,8 @@
+package bot
+
+import "errors"
+
+var (
+	ErrInvalidConfig  = errors.New("invalid config")
+	ErrInvalidCommand = errors.New("invalid command")
+)
@@ -0,0 +1,326 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+	"github.com/capcom6/censor-tg-bot/pkg/tgbotapifx"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// permissionsKey is the state key of the default member permissions of chats in lockdown.
+	permissionsKey = "bot:lockdown:permissions"
+	// lockdownRestoreMargin is how long after the end of a lockdown its saved permissions are restored
+	// by any replica, if the replica handling the lockdown did not lift it, e.g. as it was stopped.
+	lockdownRestoreMargin = time.Minute
+)
+
+// savedPermissions are the default member permissions of a chat saved at the start of a lockdown.
+type savedPermissions struct {
+	Permissions *tgbotapi.ChatPermissions
+	Until       time.Time // end of the lockdown, moved with its extensions
+}
+
+// permissionStore keeps the default member permissions of chats in lockdown to restore them afterwards,
+// in the state of the storage backend, so they are restored even if the lockdown is lost with its replica.
+type permissionStore struct {
+	state storage.State
+}
+
+func newPermissionStore(state storage.State) *permissionStore {
+	return &permissionStore{
+		state: state,
+	}
+}
+
+func (s *permissionStore) put(ctx context.Context, chatID int64, saved savedPermissions) error {
+	return s.update(ctx, func(items map[int64]savedPermissions) {
+		items[chatID] = saved
+	})
+}
+
+// extend moves the end of the lockdown of the chat, if its permissions were saved.
+func (s *permissionStore) extend(ctx context.Context, chatID int64, until time.Time) error {
+	return s.update(ctx, func(items map[int64]savedPermissions) {
+		if saved, ok := items[chatID]; ok && until.After(saved.Until) {
+			saved.Until = until
+			items[chatID] = saved
+		}
+	})
+}
+
+// take removes and returns the permissions of the chat, false if they were not saved.
+func (s *permissionStore) take(ctx context.Context, chatID int64) (*tgbotapi.ChatPermissions, bool, error) {
+	var (
+		saved savedPermissions
+		ok    bool
+	)
+
+	err := s.update(ctx, func(items map[int64]savedPermissions) {
+		saved, ok = items[chatID]
+		delete(items, chatID)
+	})
+
+	return saved.Permissions, ok, err
+}
+
+// takeIf removes and returns the permissions of the chats matching the predicate.
+// The state is only updated if any chat matches.
+func (s *permissionStore) takeIf(
+	ctx context.Context,
+	predicate func(chatID int64, saved savedPermissions) bool,
+) (map[int64]*tgbotapi.ChatPermissions, error) {
+	items, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	taken := map[int64]*tgbotapi.ChatPermissions{}
+	maps.DeleteFunc(items, func(chatID int64, saved savedPermissions) bool {
+		return !predicate(chatID, saved)
+	})
+	if len(items) == 0 {
+		return taken, nil
+	}
+
+	err = s.update(ctx, func(items map[int64]savedPermissions) {
+		clear(taken) // the update may be retried
+		maps.DeleteFunc(items, func(chatID int64, saved savedPermissions) bool {
+			if !predicate(chatID, saved) {
+				return false
+			}
+			taken[chatID] = saved.Permissions
+			return true
+		})
+	})
+
+	return taken, err
+}
+
+func (s *permissionStore) load(ctx context.Context) (map[int64]savedPermissions, error) {
+	data, err := s.state.Load(ctx, permissionsKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return map[int64]savedPermissions{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading chat permissions: %w", err)
+	}
+
+	items := map[int64]savedPermissions{}
+	if unmarshalErr := json.Unmarshal(data, &items); unmarshalErr != nil {
+		return nil, fmt.Errorf("error decoding chat permissions: %w", unmarshalErr)
+	}
+
+	return items, nil
+}
+
+func (s *permissionStore) update(ctx context.Context, fn func(items map[int64]savedPermissions)) error {
+	var codecErr error
+
+	if err := s.state.Update(ctx, permissionsKey, 0, func(data []byte) []byte {
+		items := map[int64]savedPermissions{}
+		if len(data) > 0 {
+			if codecErr = json.Unmarshal(data, &items); codecErr != nil {
+				return data
+			}
+		}
+
+		fn(items)
+
+		updated, err := json.Marshal(items)
+		if err != nil {
+			codecErr = err
+			return data
+		}
+		return updated
+	}); err != nil {
+		return fmt.Errorf("error updating chat permissions: %w", err)
+	}
+
+	if codecErr != nil {
+		return fmt.Errorf("error serializing chat permissions: %w", codecErr)
+	}
+
+	return nil
+}
+
+// LockdownChanged restricts or restores the permissions of the chat and notifies admins.
+// In dry run mode admins are notified only.
+func (b *Bot) LockdownChanged(ctx context.Context, bot *tgbotapifx.Bot, event lockdown.Event) {
+	l := event.Lockdown
+
+	b.logger.Info("lockdown changed",
+		zap.Int64("chat_id", l.ChatID),
+		zap.Bool("active", event.Active),
+		zap.String("reason", l.Reason),
+		zap.String("restriction", string(l.Restriction)),
+		zap.Bool("dry_run", b.config.DryRun),
+	)
+
+	var err error
+	if event.Active {
+		err = b.startLockdown(ctx, bot, l)
+	} else {
+		err = b.liftLockdown(ctx, bot, l)
+	}
+	if err != nil {
+		b.logger.Error("error changing lockdown", zap.Int64("chat_id", l.ChatID), zap.Error(err))
+	}
+}
+
+func (b *Bot) startLockdown(ctx context.Context, bot *tgbotapifx.Bot, l lockdown.Lockdown) error {
+	notification := fmt.Sprintf(
+		"Raid detected in chat %d: %s\nMessages of users who joined within %s are deleted "+
+			"until no spike is detected for %s.",
+		l.ChatID,
+		l.Reason,
+		l.NewUserWindow,
+		l.Until.Sub(l.Since),
+	)
+
+	if permissions := restrictedPermissions(l.Restriction); permissions != nil {
+		if b.config.DryRun {
+			notification += fmt.Sprintf("\n[dry run] Would have restricted permissions: %s", l.Restriction)
+		} else if err := b.restrictChat(ctx, bot, l, permissions); err != nil {
+			notification += "\nFailed to restrict permissions, check the bot rights."
+			b.logger.Error("error restricting chat", zap.Int64("chat_id", l.ChatID), zap.Error(err))
+		} else {
+			notification += fmt.Sprintf("\nPermissions restricted: %s", l.Restriction)
+		}
+	}
+
+	if err := b.notifyAdmins(bot, notification, nil); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionLockdownStarted, MetricLabelStatusFailed)
+		return fmt.Errorf("error notifying admins: %w", err)
+	}
+	b.metrics.IncProcessedAction(MetricLabelActionLockdownStarted, MetricLabelStatusSuccess)
+
+	return nil
+}
+
+func (b *Bot) liftLockdown(ctx context.Context, bot *tgbotapifx.Bot, l lockdown.Lockdown) error {
+	notification := fmt.Sprintf("Lockdown of chat %d lifted, the raid is over.", l.ChatID)
+
+	permissions, ok, err := b.permissions.take(ctx, l.ChatID)
+	if err != nil {
+		notification += "\nFailed to load saved permissions, restore them manually."
+		b.logger.Error("error loading chat permissions", zap.Int64("chat_id", l.ChatID), zap.Error(err))
+	}
+	if ok {
+		if err := b.setChatPermissions(bot, l.ChatID, permissions); err != nil {
+			notification += "\nFailed to restore permissions, restore them manually."
+			b.logger.Error("error restoring chat permissions", zap.Int64("chat_id", l.ChatID), zap.Error(err))
+		} else {
+			notification += "\nPermissions restored."
+		}
+	}
+
+	if err := b.notifyAdmins(bot, notification, nil); err != nil {
+		b.metrics.IncProcessedAction(MetricLabelActionLockdownLifted, MetricLabelStatusFailed)
+		return fmt.Errorf("error notifying admins: %w", err)
+	}
+	b.metrics.IncProcessedAction(MetricLabelActionLockdownLifted, MetricLabelStatusSuccess)
+
+	return nil
+}
+
+// restrictChat saves the current default permissions of the chat in lockdown and replaces them.
+func (b *Bot) restrictChat(
+	ctx context.Context,
+	bot *tgbotapifx.Bot,
+	l lockdown.Lockdown,
+	permissions *tgbotapi.ChatPermissions,
+) error {
+	chat, err := bot.GetChat(tgbotapi.ChatInfoConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: l.ChatID}, //nolint:exhaustruct // chat is referenced by ID
+	})
+	if err != nil {
+		return fmt.Errorf("error getting chat: %w", err)
+	}
+
+	// The permissions are saved first, so that they are restored even if the bot stops meanwhile
+	saved := chat.Permissions
+	if saved == nil {
+		saved = memberPermissions()
+	}
+	if err = b.permissions.put(ctx, l.ChatID, savedPermissions{Permissions: saved, Until: l.Until}); err != nil {
+		return err
+	}
+
+	return b.setChatPermissions(bot, l.ChatID, permissions)
+}
+
+// requestLockdown starts the lockdown requested for a message, or extends the current lockdown of the chat
+// along with the time its saved permissions are kept for.
+func (b *Bot) requestLockdown(ctx context.Context, l lockdown.Lockdown) {
+	if b.lockdown.Start(l) {
+		return
+	}
+
+	if err := b.permissions.extend(ctx, l.ChatID, l.Until); err != nil {
+		b.logger.Error("error extending lockdown", zap.Int64("chat_id", l.ChatID), zap.Error(err))
+	}
+}
+
+// RestorePermissions restores the saved permissions of chats whose lockdowns ended without being lifted,
+// e.g. as the replica handling them was stopped, lockdowns themselves are not kept across restarts.
+// Permissions are restored only some time after the saved end of the lockdown, so that lockdowns
+// handled by other replicas are not ended early.
+func (b *Bot) RestorePermissions(ctx context.Context, bot *tgbotapifx.Bot) {
+	now := time.Now()
+	saved, err := b.permissions.takeIf(ctx, func(chatID int64, saved savedPermissions) bool {
+		_, active := b.lockdown.Active(chatID)
+		return !active && now.After(saved.Until.Add(lockdownRestoreMargin))
+	})
+	if err != nil {
+		b.logger.Error("error loading chat permissions", zap.Error(err))
+		return
+	}
+
+	for chatID, permissions := range saved {
+		notification := fmt.Sprintf("Lockdown of chat %d ended without being lifted, e.g. by a restart.", chatID)
+		if setErr := b.setChatPermissions(bot, chatID, permissions); setErr != nil {
+			notification += "\nFailed to restore permissions, restore them manually."
+			b.logger.Error("error restoring chat permissions", zap.Int64("chat_id", chatID), zap.Error(setErr))
+		} else {
+			notification += "\nPermissions restored."
+		}
+
+		if ntfErr := b.notifyAdmins(bot, notification, nil); ntfErr != nil {
+			b.logger.Error("error notifying admins", zap.Error(ntfErr))
+		}
+	}
+}
+
+func (b *Bot) setChatPermissions(bot *tgbotapifx.Bot, chatID int64, permissions *tgbotapi.ChatPermissions) error {
+	req := tgbotapi.SetChatPermissionsConfig{
+		ChatConfig:  tgbotapi.ChatConfig{ChatID: chatID}, //nolint:exhaustruct // chat is referenced by ID
+		Permissions: permissions,
+	}
+	if _, err := bot.Request(req); err != nil {
+		return fmt.Errorf("error setting chat permissions: %w", err)
+	}
+
+	return nil
+}
+
+// restrictedPermissions returns the default member permissions during lockdown, nil if they are kept.
+func restrictedPermissions(restriction lockdown.Restriction) *tgbotapi.ChatPermissions {
+	switch restriction {
+	case lockdown.RestrictionTextOnly:
+		return &tgbotapi.ChatPermissions{CanSendMessages: true} //nolint:exhaustruct // text messages only
+	case lockdown.RestrictionReadOnly:
+		return &tgbotapi.ChatPermissions{} //nolint:exhaustruct // all permissions revoked
+	case "", lockdown.RestrictionNone:
+	}
+
+	return nil
+}
@@ -16,8 +16,23 @@ const (
 
 	MetricLabelActionMessageProcessed MetricLabelAction = "message_processed"
 	MetricLabelActionMessageDeleted   MetricLabelAction = "message_deleted"
+	MetricLabelActionRelatedDeleted   MetricLabelAction = "related_deleted"
+	MetricLabelActionUserWarned       MetricLabelAction = "user_warned"
+	MetricLabelActionUserMuted        MetricLabelAction = "user_muted"
+	MetricLabelActionUserKicked       MetricLabelAction = "user_kicked"
 	MetricLabelActionUserBanned       MetricLabelAction = "user_banned"
+	MetricLabelActionUserUnbanned     MetricLabelAction = "user_unbanned"
 	MetricLabelActionAdminNotified    MetricLabelAction = "admin_notified"
+	MetricLabelActionDryRunReported   MetricLabelAction = "dry_run_reported"
+	MetricLabelActionShadowReported   MetricLabelAction = "shadow_reported"
+	MetricLabelActionCommandHandled   MetricLabelAction = "command_handled"
+	MetricLabelActionCallbackHandled  MetricLabelAction = "callback_handled"
+	MetricLabelActionCaptchaSent      MetricLabelAction = "captcha_sent"
+	MetricLabelActionCaptchaPassed    MetricLabelAction = "captcha_passed"
+	MetricLabelActionCaptchaFailed    MetricLabelAction = "captcha_failed"
+	MetricLabelActionCaptchaExpired   MetricLabelAction = "captcha_expired"
+	MetricLabelActionLockdownStarted  MetricLabelAction = "lockdown_started"
+	MetricLabelActionLockdownLifted   MetricLabelAction = "lockdown_lifted"
 
 	MetricLabelStatusSuccess MetricLabelStatus = "success"
 	MetricLabelStatusFailed  MetricLabelStatus = "failed"
@@ -1,11 +1,22 @@
 package bot
 
 import (
+	"context"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
 	"github.com/capcom6/censor-tg-bot/pkg/tgbotapifx"
 	"github.com/go-core-fx/logger"
 	"go.uber.org/fx"
 )
 
+const (
+	// captchaCheckInterval is how often new members with expired challenges are kicked.
+	captchaCheckInterval = 5 * time.Second
+	// lockdownCheckInterval is how often lockdowns of calmed down chats are lifted.
+	lockdownCheckInterval = 5 * time.Second
+)
+
 func Module() fx.Option {
 	return fx.Module(
 		"bot",
@@ -14,6 +25,60 @@ func Module() fx.Option {
 		fx.Provide(New),
 		fx.Invoke(func(bot *Bot, api *tgbotapifx.Bot) {
 			api.SetDefaultHandler(bot.Handler)
+			for command, handler := range bot.Commands() {
+				api.AddCommandHandler(command, handler)
+			}
+			for prefix, handler := range bot.Callbacks() {
+				api.AddCallbackHandler(prefix, handler)
+			}
+		}),
+		fx.Invoke(func(lc fx.Lifecycle, bot *Bot, api *tgbotapifx.Bot) {
+			if !bot.captcha.Enabled() {
+				return
+			}
+
+			tick(lc, captchaCheckInterval, func() { bot.ExpireChallenges(context.Background(), api) })
+		}),
+		fx.Invoke(func(lc fx.Lifecycle, bot *Bot, registry *lockdown.Registry, api *tgbotapifx.Bot) {
+			registry.OnChange(func(event lockdown.Event) { bot.LockdownChanged(context.Background(), api, event) })
+
+			tick(lc, lockdownCheckInterval, func() {
+				registry.Expire()
+				bot.RestorePermissions(context.Background(), api)
+			})
 		}),
 	)
 }
+
+// tick calls fn every interval while the application is running.
+func tick(lc fx.Lifecycle, interval time.Duration, fn func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	waitCh := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			go func() {
+				defer close(waitCh)
+
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ticker.C:
+						fn()
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			cancel()
+			select {
+			case <-waitCh:
+			case <-ctx.Done():
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// noticeTTL is how long the buttons of an admin notification stay usable.
+const noticeTTL = 48 * time.Hour
+
+// notice is a blocked message referenced by the buttons of an admin notification.
+type notice struct {
+	ChatID    int64
+	UserID    int64
+	User      string // HTML representation of the user
+	MessageID int
+	Text      string
+	Plugin    string
+	Step      Step
+	Deleted   bool                // the message was deleted and can be reposted
+	Trace     []censor.TraceEntry // outcomes of the plugins, shown on demand
+}
+
+// noticeStore keeps the notices of recent admin notifications in the state of the storage backend,
+// so the buttons keep working after a restart and on any replica.
+type noticeStore struct {
+	state storage.State
+}
+
+func newNoticeStore(state storage.State) *noticeStore {
+	return &noticeStore{
+		state: state,
+	}
+}
+
+// add stores the notice and returns its ID, notices expire after noticeTTL.
+func (s *noticeStore) add(ctx context.Context, n notice) (string, error) {
+	seq, err := s.state.Increment(ctx, "bot:notices:seq", 0)
+	if err != nil {
+		return "", fmt.Errorf("error generating notice ID: %w", err)
+	}
+	id := strconv.FormatInt(int64(seq), 36)
+
+	data, err := json.Marshal(n)
+	if err != nil {
+		return "", fmt.Errorf("error encoding notice: %w", err)
+	}
+
+	if storeErr := s.state.Store(ctx, "bot:notices:"+id, data, noticeTTL); storeErr != nil {
+		return "", fmt.Errorf("error storing notice: %w", storeErr)
+	}
+
+	return id, nil
+}
+
+// get returns the notice, false if it is unknown or expired.
+func (s *noticeStore) get(ctx context.Context, id string) (notice, bool, error) {
+	data, err := s.state.Load(ctx, "bot:notices:"+id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return notice{}, false, nil
+	}
+	if err != nil {
+		return notice{}, false, fmt.Errorf("error loading notice: %w", err)
+	}
+
+	var n notice
+	if unmarshalErr := json.Unmarshal(data, &n); unmarshalErr != nil {
+		return notice{}, false, fmt.Errorf("error decoding notice: %w", unmarshalErr)
+	}
+
+	return n, true, nil
+}
+
+// newNotice creates a notice of the message blocked with the result of the evaluation.
+func newNotice(message *tgbotapi.Message, result plugin.Result, step Step, deleted bool) notice {
+	return notice{
+		ChatID:    message.Chat.ID,
+		UserID:    message.From.ID,
+		User:      userToString(message.From),
+		MessageID: message.MessageID,
+		Text:      messageToString(message),
+		Plugin:    result.Plugin,
+		Step:      step,
+		Deleted:   deleted,
+		Trace:     censor.Trace(result),
+	}
+}
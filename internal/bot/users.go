@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+)
+
+// userList is the list a user was put on with admin commands.
+type userList string
+
+const (
+	userListNone    userList = ""
+	userListAllowed userList = "allowed"
+	userListBlocked userList = "blocked"
+)
+
+// userLists keeps the users allowed and blocked with admin commands in the state of the storage backend,
+// so they survive restarts and are shared between replicas with Redis. The list of each user is kept
+// under a key of its own, so that checking the sender of a message takes a single load, and the IDs
+// of each list are kept for the status.
+type userLists struct {
+	state storage.State
+}
+
+func newUserLists(state storage.State) *userLists {
+	return &userLists{
+		state: state,
+	}
+}
+
+// get returns the list of the user, userListNone if the user is not listed.
+func (l *userLists) get(ctx context.Context, userID int64) (userList, error) {
+	data, err := l.state.Load(ctx, userKey(userID))
+	if errors.Is(err, storage.ErrNotFound) {
+		return userListNone, nil
+	}
+	if err != nil {
+		return userListNone, fmt.Errorf("error loading user list: %w", err)
+	}
+
+	return userList(data), nil
+}
+
+// set moves the user to the list.
+func (l *userLists) set(ctx context.Context, userID int64, list userList) error {
+	if err := l.state.Store(ctx, userKey(userID), []byte(list), 0); err != nil {
+		return fmt.Errorf("error storing user list: %w", err)
+	}
+
+	for _, other := range []userList{userListAllowed, userListBlocked} {
+		if err := l.update(ctx, other, func(ids []int64) []int64 {
+			ids = slices.DeleteFunc(ids, func(id int64) bool { return id == userID })
+			if other == list {
+				ids = append(ids, userID)
+				slices.Sort(ids)
+			}
+			return ids
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// list returns the sorted IDs of the users on the list.
+func (l *userLists) list(ctx context.Context, list userList) ([]int64, error) {
+	data, err := l.state.Load(ctx, listKey(list))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading user list: %w", err)
+	}
+
+	var ids []int64
+	if unmarshalErr := json.Unmarshal(data, &ids); unmarshalErr != nil {
+		return nil, fmt.Errorf("error decoding user list: %w", unmarshalErr)
+	}
+
+	return ids, nil
+}
+
+func (l *userLists) update(ctx context.Context, list userList, fn func(ids []int64) []int64) error {
+	var codecErr error
+
+	if err := l.state.Update(ctx, listKey(list), 0, func(data []byte) []byte {
+		var ids []int64
+		if len(data) > 0 {
+			if codecErr = json.Unmarshal(data, &ids); codecErr != nil {
+				return data
+			}
+		}
+
+		updated, err := json.Marshal(fn(ids))
+		if err != nil {
+			codecErr = err
+			return data
+		}
+		return updated
+	}); err != nil {
+		return fmt.Errorf("error updating user list: %w", err)
+	}
+
+	if codecErr != nil {
+		return fmt.Errorf("error serializing user list: %w", codecErr)
+	}
+
+	return nil
+}
+
+func userKey(userID int64) string {
+	return "bot:users:user:" + strconv.FormatInt(userID, 10)
+}
+
+func listKey(list userList) string {
+	return "bot:users:" + string(list)
+}
@@ -1,11 +1,17 @@
 package bot
 
 import (
+	"encoding/json"
+	"fmt"
 	"html"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/capcom6/censor-tg-bot/internal/censor"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/samber/lo"
 )
 
 func userToString(user *tgbotapi.User) string {
@@ -32,3 +38,262 @@ func messageToString(message *tgbotapi.Message) string {
 
 	return ""
 }
+
+func stepToString(step Step) string {
+	action := string(step.Action)
+	if step.Duration > 0 && (step.Action == ActionMute || step.Action == ActionBan) {
+		action += " for " + step.Duration.String()
+	}
+
+	return action
+}
+
+func severityToString(severity plugin.Severity) string {
+	if severity == plugin.SeverityNone {
+		return ""
+	}
+
+	return "\nSeverity: " + string(severity)
+}
+
+// relatedToString describes the earlier messages blocked together with a message, of which deleted were removed.
+func relatedToString(related []plugin.MessageRef, deleted int) string {
+	if len(related) == 0 {
+		return ""
+	}
+
+	chats := lo.Uniq(lo.Map(related, func(ref plugin.MessageRef, _ int) int64 { return ref.ChatID }))
+	text := fmt.Sprintf("\nRelated: %d earlier messages in %d chats", len(related), len(chats))
+	if deleted < len(related) {
+		text += fmt.Sprintf(", %d not removed", len(related)-deleted)
+	}
+
+	return text
+}
+
+func userIDToString(userID int64) string {
+	id := strconv.FormatInt(userID, 10)
+	return "<a href=\"tg://user?id=" + id + "\">" + id + "</a>"
+}
+
+func entitiesToPlugin(entities []tgbotapi.MessageEntity) []plugin.Entity {
+	return lo.Map(entities, func(e tgbotapi.MessageEntity, _ int) plugin.Entity {
+		return plugin.Entity{
+			Type:   e.Type,
+			Offset: e.Offset,
+			Length: e.Length,
+			URL:    e.URL,
+		}
+	})
+}
+
+// senderToPlugin converts the sender.
+func senderToPlugin(user *tgbotapi.User) plugin.Sender {
+	if user == nil {
+		return plugin.Sender{} //nolint:exhaustruct // unknown sender
+	}
+
+	return plugin.Sender{
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Username:  user.UserName,
+		IsBot:     user.IsBot,
+	}
+}
+
+func viaBotToPlugin(user *tgbotapi.User) *plugin.Bot {
+	if user == nil {
+		return nil
+	}
+
+	return &plugin.Bot{
+		ID:       user.ID,
+		Username: user.UserName,
+	}
+}
+
+func replyToPlugin(message *tgbotapi.Message) *plugin.Reply {
+	if message == nil {
+		return nil
+	}
+
+	reply := &plugin.Reply{
+		MessageID:    message.MessageID,
+		UserID:       0,
+		SenderChatID: nil,
+		Text:         messageToString(message),
+	}
+	if message.From != nil {
+		reply.UserID = message.From.ID
+	}
+	if message.SenderChat != nil {
+		reply.SenderChatID = &message.SenderChat.ID
+	}
+
+	return reply
+}
+
+// mediaToPlugin returns the media attached to the message, or nil for text messages.
+//
+//nolint:exhaustruct // only the fields known for the kind are set
+func mediaToPlugin(message *tgbotapi.Message) *plugin.Media {
+	switch {
+	case len(message.Photo) > 0:
+		// Sizes are sorted from the smallest, the largest is the original
+		photo := message.Photo[len(message.Photo)-1]
+		return &plugin.Media{
+			Kind:         plugin.MediaKindPhoto,
+			FileID:       photo.FileID,
+			FileUniqueID: photo.FileUniqueID,
+			FileSize:     int64(photo.FileSize),
+			Width:        photo.Width,
+			Height:       photo.Height,
+		}
+	// Animations are sent with a document for old clients, so they are checked first
+	case message.Animation != nil:
+		a := message.Animation
+		return &plugin.Media{
+			Kind:         plugin.MediaKindAnimation,
+			FileID:       a.FileID,
+			FileUniqueID: a.FileUniqueID,
+			FileName:     a.FileName,
+			MimeType:     a.MimeType,
+			FileSize:     int64(a.FileSize),
+			Width:        a.Width,
+			Height:       a.Height,
+			Duration:     a.Duration,
+		}
+	case message.Video != nil:
+		v := message.Video
+		return &plugin.Media{
+			Kind:         plugin.MediaKindVideo,
+			FileID:       v.FileID,
+			FileUniqueID: v.FileUniqueID,
+			FileName:     v.FileName,
+			MimeType:     v.MimeType,
+			FileSize:     int64(v.FileSize),
+			Width:        v.Width,
+			Height:       v.Height,
+			Duration:     v.Duration,
+		}
+	case message.Document != nil:
+		d := message.Document
+		return &plugin.Media{
+			Kind:         plugin.MediaKindDocument,
+			FileID:       d.FileID,
+			FileUniqueID: d.FileUniqueID,
+			FileName:     d.FileName,
+			MimeType:     d.MimeType,
+			FileSize:     int64(d.FileSize),
+		}
+	case message.Audio != nil:
+		a := message.Audio
+		return &plugin.Media{
+			Kind:         plugin.MediaKindAudio,
+			FileID:       a.FileID,
+			FileUniqueID: a.FileUniqueID,
+			FileName:     a.FileName,
+			MimeType:     a.MimeType,
+			FileSize:     int64(a.FileSize),
+			Duration:     a.Duration,
+		}
+	case message.Voice != nil:
+		v := message.Voice
+		return &plugin.Media{
+			Kind:         plugin.MediaKindVoice,
+			FileID:       v.FileID,
+			FileUniqueID: v.FileUniqueID,
+			MimeType:     v.MimeType,
+			FileSize:     int64(v.FileSize),
+			Duration:     v.Duration,
+		}
+	case message.VideoNote != nil:
+		v := message.VideoNote
+		return &plugin.Media{
+			Kind:         plugin.MediaKindVideoNote,
+			FileID:       v.FileID,
+			FileUniqueID: v.FileUniqueID,
+			FileSize:     int64(v.FileSize),
+			Width:        v.Length,
+			Height:       v.Length,
+			Duration:     v.Duration,
+		}
+	case message.Sticker != nil:
+		s := message.Sticker
+		return &plugin.Media{
+			Kind:         plugin.MediaKindSticker,
+			FileID:       s.FileID,
+			FileUniqueID: s.FileUniqueID,
+			FileSize:     int64(s.FileSize),
+			Width:        s.Width,
+			Height:       s.Height,
+			IsAnimated:   s.IsAnimated,
+		}
+	case message.Contact != nil:
+		return &plugin.Media{Kind: plugin.MediaKindContact}
+	// Venues contain a location, so they are checked first
+	case message.Venue != nil:
+		return &plugin.Media{Kind: plugin.MediaKindVenue}
+	case message.Location != nil:
+		return &plugin.Media{Kind: plugin.MediaKindLocation}
+	case message.Poll != nil:
+		return &plugin.Media{Kind: plugin.MediaKindPoll}
+	case message.Dice != nil:
+		return &plugin.Media{Kind: plugin.MediaKindDice}
+	case message.Game != nil:
+		return &plugin.Media{Kind: plugin.MediaKindGame}
+	}
+
+	return nil
+}
+
+func buttonsToPlugin(markup *tgbotapi.InlineKeyboardMarkup) []plugin.Button {
+	if markup == nil {
+		return nil
+	}
+
+	return lo.FlatMap(markup.InlineKeyboard, func(row []tgbotapi.InlineKeyboardButton, _ int) []plugin.Button {
+		return lo.Map(row, func(b tgbotapi.InlineKeyboardButton, _ int) plugin.Button {
+			return plugin.Button{
+				Text:         b.Text,
+				URL:          lo.FromPtr(b.URL),
+				CallbackData: lo.FromPtr(b.CallbackData),
+			}
+		})
+	})
+}
+
+// traceMetadataLimit is the maximum length of plugin metadata shown in a trace.
+const traceMetadataLimit = 200
+
+// traceToString formats the trace of an evaluation as HTML, one plugin per line.
+func traceToString(trace []censor.TraceEntry) string {
+	lines := make([]string, 0, len(trace))
+	for i, entry := range trace {
+		outcome := string(entry.Action)
+		if entry.Error != "" {
+			outcome = "error"
+		}
+
+		line := fmt.Sprintf(
+			"%d. <b>%s</b>%s – %s (%s)",
+			i+1,
+			html.EscapeString(entry.Plugin),
+			lo.Ternary(entry.Shadow, " [shadow]", ""),
+			outcome,
+			entry.Duration.Round(time.Millisecond),
+		)
+		if reason := lo.Ternary(entry.Error != "", entry.Error, entry.Reason); reason != "" {
+			line += "\n    " + html.EscapeString(reason)
+		}
+		if len(entry.Metadata) > 0 {
+			if metadata, err := json.Marshal(entry.Metadata); err == nil {
+				line += "\n    <code>" + html.EscapeString(lo.Ellipsis(string(metadata), traceMetadataLimit)) + "</code>"
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,338 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+)
+
+// retention is how long challenges are kept after they expire, so that their users are kicked
+// when the bot was not running at the time.
+const retention = 24 * time.Hour
+
+// State keys of challenges.
+const (
+	keySequence  = "captcha:seq"        // ID of the latest challenge
+	keyQueue     = "captcha:queue"      // IDs of the challenges to check for expiration, in order of creation
+	keyChallenge = "captcha:challenge:" // challenge by ID
+	keyMember    = "captcha:member:"    // ID of the pending challenge of a member
+	keyVerified  = "captcha:verified:"  // members who passed recently
+)
+
+// Outcome is the result of answering a challenge.
+type Outcome string
+
+const (
+	OutcomePassed   Outcome = "passed"    // the answer is correct, the challenge is removed
+	OutcomeFailed   Outcome = "failed"    // the answer is wrong, the challenge is removed
+	OutcomeNotYours Outcome = "not_yours" // the challenge was answered by another user
+	OutcomeNotFound Outcome = "not_found" // the challenge expired or was answered already
+)
+
+// Captcha keeps the challenges of new members in the state until they are answered or expire,
+// so that they survive restarts and can be answered on any replica.
+type Captcha struct {
+	config Config
+
+	state storage.State
+}
+
+// storedChallenge is the challenge with its answer, as kept in the state.
+type storedChallenge struct {
+	Challenge
+
+	Answer int
+}
+
+func New(config Config, state storage.State) (*Captcha, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Captcha{
+		config: config,
+
+		state: state,
+	}, nil
+}
+
+// Enabled reports whether new members have to solve a challenge.
+func (c *Captcha) Enabled() bool {
+	return c.config.Enabled()
+}
+
+// Timeout returns the time to solve a challenge.
+func (c *Captcha) Timeout() time.Duration {
+	return c.config.Timeout
+}
+
+// Start creates a challenge for the new member.
+// It returns false if the member has a challenge already or passed one recently.
+func (c *Captcha) Start(ctx context.Context, chatID, userID int64) (Challenge, bool, error) {
+	for _, key := range []string{keyMember, keyVerified} {
+		_, err := c.state.Load(ctx, key+memberKey(chatID, userID))
+		if err == nil {
+			return Challenge{}, false, nil //nolint:exhaustruct // no challenge
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			return Challenge{}, false, fmt.Errorf("failed to load member: %w", err) //nolint:exhaustruct // no challenge
+		}
+	}
+
+	seq, err := c.state.Increment(ctx, keySequence, 0)
+	if err != nil {
+		return Challenge{}, false, fmt.Errorf("failed to get next id: %w", err) //nolint:exhaustruct // no challenge
+	}
+
+	question, options, answer := newQuestion(c.config.Kind)
+	challenge := Challenge{
+		ID:        strconv.FormatInt(int64(seq), 36),
+		ChatID:    chatID,
+		UserID:    userID,
+		MessageID: 0,
+		Question:  question,
+		Options:   options,
+		ExpiresAt: time.Now().Add(c.config.Timeout),
+		answer:    answer,
+	}
+
+	if storeErr := c.store(ctx, challenge); storeErr != nil {
+		return Challenge{}, false, storeErr //nolint:exhaustruct // no challenge
+	}
+	if storeErr := c.state.Store(
+		ctx,
+		keyMember+memberKey(chatID, userID),
+		[]byte(challenge.ID),
+		c.config.Timeout+retention,
+	); storeErr != nil {
+		return Challenge{}, false, fmt.Errorf("failed to store member: %w", storeErr) //nolint:exhaustruct // no challenge
+	}
+
+	// The challenge is queued for expiration only once it is stored, so that it is never missed by a sweep
+	if queueErr := c.updateQueue(ctx, func(ids []string) []string { return append(ids, challenge.ID) }); queueErr != nil {
+		if removeErr := c.remove(ctx, challenge); removeErr != nil {
+			queueErr = errors.Join(queueErr, removeErr)
+		}
+		return Challenge{}, false, queueErr //nolint:exhaustruct // no challenge
+	}
+
+	return challenge, true, nil
+}
+
+// SetMessageID sets the message the challenge was sent with.
+func (c *Captcha) SetMessageID(ctx context.Context, id string, messageID int) error {
+	challenge, ok, err := c.load(ctx, id)
+	if err != nil || !ok {
+		return err
+	}
+
+	challenge.MessageID = messageID
+
+	return c.store(ctx, challenge)
+}
+
+// Cancel removes the challenge, e.g. when it could not be sent.
+func (c *Captcha) Cancel(ctx context.Context, id string) error {
+	challenge, ok, err := c.load(ctx, id)
+	if err != nil || !ok {
+		return err
+	}
+
+	return c.remove(ctx, challenge)
+}
+
+// Answer checks the option picked by the user, the challenge is removed unless it belongs to another user.
+func (c *Captcha) Answer(ctx context.Context, id string, userID int64, option int) (Challenge, Outcome, error) {
+	challenge, ok, err := c.load(ctx, id)
+	if err != nil {
+		return Challenge{}, "", err //nolint:exhaustruct // no challenge
+	}
+	if !ok || time.Now().After(challenge.ExpiresAt) {
+		return Challenge{}, OutcomeNotFound, nil //nolint:exhaustruct // no challenge
+	}
+
+	if challenge.UserID != userID {
+		return challenge, OutcomeNotYours, nil
+	}
+
+	if removeErr := c.remove(ctx, challenge); removeErr != nil {
+		return Challenge{}, "", removeErr //nolint:exhaustruct // no challenge
+	}
+
+	if !challenge.IsCorrect(option) {
+		return challenge, OutcomeFailed, nil
+	}
+
+	verifiedKey := keyVerified + memberKey(challenge.ChatID, challenge.UserID)
+	if err = c.state.Store(ctx, verifiedKey, []byte{1}, c.config.Timeout); err != nil {
+		return Challenge{}, "", fmt.Errorf("failed to store verified member: %w", err) //nolint:exhaustruct // no challenge
+	}
+
+	return challenge, OutcomePassed, nil
+}
+
+// Expired removes and returns the challenges not answered in time, including those which expired
+// while the bot was not running. Challenges are checked in the order they were created,
+// which is also the order they expire in.
+func (c *Captcha) Expired(ctx context.Context) ([]Challenge, error) {
+	queue, err := c.loadQueue(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	// Answered and canceled challenges are dropped from the queue along with the expired ones
+	done := map[string]*Challenge{}
+	for _, id := range queue {
+		challenge, ok, loadErr := c.load(ctx, id)
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		if !ok {
+			done[id] = nil
+			continue
+		}
+		if now.Before(challenge.ExpiresAt) {
+			break
+		}
+		done[id] = &challenge
+	}
+	if len(done) == 0 {
+		return []Challenge{}, nil
+	}
+
+	// Only the challenges taken from the queue by this call are returned, other replicas may sweep concurrently
+	var taken []string
+	if updateErr := c.updateQueue(ctx, func(ids []string) []string {
+		taken = nil
+		return slices.DeleteFunc(ids, func(id string) bool {
+			_, ok := done[id]
+			if ok {
+				taken = append(taken, id)
+			}
+			return ok
+		})
+	}); updateErr != nil {
+		return nil, updateErr
+	}
+
+	expired := []Challenge{}
+	for _, id := range taken {
+		challenge := done[id]
+		if challenge == nil {
+			continue
+		}
+
+		if removeErr := c.remove(ctx, *challenge); removeErr != nil {
+			return expired, removeErr
+		}
+		expired = append(expired, *challenge)
+	}
+
+	return expired, nil
+}
+
+// loadQueue returns the IDs of the challenges to check for expiration.
+func (c *Captcha) loadQueue(ctx context.Context) ([]string, error) {
+	data, err := c.state.Load(ctx, keyQueue)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load expiration queue: %w", err)
+	}
+
+	var ids []string
+	if err = json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("failed to decode expiration queue: %w", err)
+	}
+
+	return ids, nil
+}
+
+// updateQueue atomically replaces the IDs of the challenges to check for expiration.
+func (c *Captcha) updateQueue(ctx context.Context, fn func(ids []string) []string) error {
+	var codecErr error
+
+	if err := c.state.Update(ctx, keyQueue, 0, func(data []byte) []byte {
+		var ids []string
+		if len(data) > 0 {
+			if codecErr = json.Unmarshal(data, &ids); codecErr != nil {
+				return data
+			}
+		}
+
+		updated, err := json.Marshal(fn(ids))
+		if err != nil {
+			codecErr = err
+			return data
+		}
+		return updated
+	}); err != nil {
+		return fmt.Errorf("failed to update expiration queue: %w", err)
+	}
+
+	if codecErr != nil {
+		return fmt.Errorf("failed to serialize expiration queue: %w", codecErr)
+	}
+
+	return nil
+}
+
+func (c *Captcha) load(ctx context.Context, id string) (Challenge, bool, error) {
+	data, err := c.state.Load(ctx, keyChallenge+id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Challenge{}, false, nil //nolint:exhaustruct // no challenge
+	}
+	if err != nil {
+		return Challenge{}, false, fmt.Errorf("failed to load challenge: %w", err) //nolint:exhaustruct // no challenge
+	}
+
+	var stored storedChallenge
+	if err = json.Unmarshal(data, &stored); err != nil {
+		return Challenge{}, false, fmt.Errorf("failed to decode challenge: %w", err) //nolint:exhaustruct // no challenge
+	}
+	stored.answer = stored.Answer
+
+	return stored.Challenge, true, nil
+}
+
+func (c *Captcha) store(ctx context.Context, challenge Challenge) error {
+	data, err := json.Marshal(storedChallenge{Challenge: challenge, Answer: challenge.answer})
+	if err != nil {
+		return fmt.Errorf("failed to encode challenge: %w", err)
+	}
+
+	if storeErr := c.state.Store(
+		ctx,
+		keyChallenge+challenge.ID,
+		data,
+		time.Until(challenge.ExpiresAt)+retention,
+	); storeErr != nil {
+		return fmt.Errorf("failed to store challenge: %w", storeErr)
+	}
+
+	return nil
+}
+
+func (c *Captcha) remove(ctx context.Context, challenge Challenge) error {
+	if err := c.state.Remove(ctx, keyChallenge+challenge.ID); err != nil {
+		return fmt.Errorf("failed to remove challenge: %w", err)
+	}
+	if err := c.state.Remove(ctx, keyMember+memberKey(challenge.ChatID, challenge.UserID)); err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	return nil
+}
+
+func memberKey(chatID, userID int64) string {
+	return strconv.FormatInt(chatID, 10) + ":" + strconv.FormatInt(userID, 10)
+}
@@ -0,0 +1,217 @@
+package captcha_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/captcha"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func newState(t *testing.T) storage.State {
+	t.Helper()
+
+	s, err := storage.New(storage.Config{URL: "memory://storage?ttl=1h"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	return storage.NewState(s)
+}
+
+func newCaptcha(t *testing.T, kind captcha.Kind, timeout time.Duration, state storage.State) *captcha.Captcha {
+	t.Helper()
+
+	c, err := captcha.New(captcha.Config{Kind: kind, Timeout: timeout}, state)
+	require.NoError(t, err)
+
+	return c
+}
+
+func start(t *testing.T, c *captcha.Captcha, chatID, userID int64) (captcha.Challenge, bool) {
+	t.Helper()
+
+	challenge, ok, err := c.Start(context.Background(), chatID, userID)
+	require.NoError(t, err)
+
+	return challenge, ok
+}
+
+func answer(t *testing.T, c *captcha.Captcha, id string, userID int64, option int) captcha.Outcome {
+	t.Helper()
+
+	_, outcome, err := c.Answer(context.Background(), id, userID, option)
+	require.NoError(t, err)
+
+	return outcome
+}
+
+func expired(t *testing.T, c *captcha.Captcha) []captcha.Challenge {
+	t.Helper()
+
+	challenges, err := c.Expired(context.Background())
+	require.NoError(t, err)
+
+	return challenges
+}
+
+// correctOption returns the index of the answer of the challenge.
+func correctOption(t *testing.T, challenge captcha.Challenge) int {
+	t.Helper()
+
+	for i := range challenge.Options {
+		if challenge.IsCorrect(i) {
+			return i
+		}
+	}
+
+	require.Fail(t, "challenge has no answer")
+	return -1
+}
+
+func TestCaptcha_Kinds(t *testing.T) {
+	for kind, options := range map[captcha.Kind]int{
+		captcha.KindButton: 1,
+		captcha.KindMath:   4,
+		captcha.KindEmoji:  6,
+	} {
+		t.Run(string(kind), func(t *testing.T) {
+			c := newCaptcha(t, kind, time.Minute, newState(t))
+
+			for userID := range int64(50) {
+				challenge, ok := start(t, c, -100, userID)
+				require.True(t, ok)
+				require.NotEmpty(t, challenge.Question)
+				require.Len(t, challenge.Options, options)
+
+				seen := map[string]bool{}
+				for _, option := range challenge.Options {
+					require.False(t, seen[option], "duplicate option %q", option)
+					seen[option] = true
+				}
+
+				correctOption(t, challenge)
+			}
+		})
+	}
+}
+
+func TestCaptcha_Answer(t *testing.T) {
+	c := newCaptcha(t, captcha.KindMath, time.Minute, newState(t))
+
+	challenge, ok := start(t, c, -100, 1)
+	require.True(t, ok)
+
+	// A join is reported by several updates
+	_, ok = start(t, c, -100, 1)
+	require.False(t, ok)
+
+	require.Equal(t, captcha.OutcomeNotYours, answer(t, c, challenge.ID, 2, correctOption(t, challenge)))
+	require.Equal(t, captcha.OutcomePassed, answer(t, c, challenge.ID, 1, correctOption(t, challenge)))
+	require.Equal(t, captcha.OutcomeNotFound, answer(t, c, challenge.ID, 1, correctOption(t, challenge)))
+
+	// Passed members are not challenged again by the other updates of the join
+	_, ok = start(t, c, -100, 1)
+	require.False(t, ok)
+
+	challenge, ok = start(t, c, -200, 1)
+	require.True(t, ok)
+
+	wrong := (correctOption(t, challenge) + 1) % len(challenge.Options)
+	require.Equal(t, captcha.OutcomeFailed, answer(t, c, challenge.ID, 1, wrong))
+}
+
+func TestCaptcha_Expired(t *testing.T) {
+	c := newCaptcha(t, captcha.KindButton, 50*time.Millisecond, newState(t))
+
+	challenge, ok := start(t, c, -100, 1)
+	require.True(t, ok)
+	require.NoError(t, c.SetMessageID(context.Background(), challenge.ID, 42))
+
+	require.Empty(t, expired(t, c))
+
+	time.Sleep(100 * time.Millisecond)
+
+	require.Equal(t, captcha.OutcomeNotFound, answer(t, c, challenge.ID, 1, 0))
+
+	challenges := expired(t, c)
+	require.Len(t, challenges, 1)
+	require.Equal(t, 42, challenges[0].MessageID)
+	require.Empty(t, expired(t, c))
+
+	// Members who failed are challenged again when they rejoin
+	_, ok = start(t, c, -100, 1)
+	require.True(t, ok)
+}
+
+func TestCaptcha_Restart(t *testing.T) {
+	state := newState(t)
+	c := newCaptcha(t, captcha.KindMath, 50*time.Millisecond, state)
+
+	answered, ok := start(t, c, -100, 1)
+	require.True(t, ok)
+	pending, ok := start(t, c, -100, 2)
+	require.True(t, ok)
+
+	// Challenges are kept in the state, so they can be answered and expire after a restart
+	c = newCaptcha(t, captcha.KindMath, 50*time.Millisecond, state)
+
+	_, ok = start(t, c, -100, 2)
+	require.False(t, ok)
+	require.Equal(t, captcha.OutcomePassed, answer(t, c, answered.ID, 1, correctOption(t, answered)))
+
+	time.Sleep(100 * time.Millisecond)
+
+	c = newCaptcha(t, captcha.KindMath, 50*time.Millisecond, state)
+
+	challenges := expired(t, c)
+	require.Len(t, challenges, 1)
+	require.Equal(t, pending.ID, challenges[0].ID)
+	require.Empty(t, expired(t, c))
+}
+
+func TestCaptcha_ExpiredReplicas(t *testing.T) {
+	state := newState(t)
+	replicas := []*captcha.Captcha{
+		newCaptcha(t, captcha.KindButton, 50*time.Millisecond, state),
+		newCaptcha(t, captcha.KindButton, 50*time.Millisecond, state),
+	}
+
+	started := map[string]bool{}
+	for userID := range int64(10) {
+		challenge, ok := start(t, replicas[userID%2], -100, userID)
+		require.True(t, ok)
+		started[challenge.ID] = true
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Replicas sweeping at once return every expired challenge exactly once
+	results := make([][]captcha.Challenge, len(replicas))
+	errs := make([]error, len(replicas))
+	wg := sync.WaitGroup{}
+	for i, c := range replicas {
+		wg.Go(func() { results[i], errs[i] = c.Expired(context.Background()) })
+	}
+	wg.Wait()
+	require.NoError(t, errors.Join(errs...))
+
+	swept := map[string]bool{}
+	for _, challenges := range results {
+		for _, challenge := range challenges {
+			require.False(t, swept[challenge.ID], challenge.ID)
+			swept[challenge.ID] = true
+		}
+	}
+	require.Equal(t, started, swept)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	require.NoError(t, captcha.Config{Kind: "", Timeout: 0}.Validate())
+	require.NoError(t, captcha.Config{Kind: captcha.KindEmoji, Timeout: time.Minute}.Validate())
+	require.ErrorIs(t, captcha.Config{Kind: "riddle", Timeout: time.Minute}.Validate(), captcha.ErrInvalidConfig)
+	require.ErrorIs(t, captcha.Config{Kind: captcha.KindMath, Timeout: 0}.Validate(), captcha.ErrInvalidConfig)
+}
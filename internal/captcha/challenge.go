@@ -0,0 +1,75 @@
+package captcha
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+const (
+	mathOptions  = 4
+	emojiOptions = 6
+)
+
+// emojis are the emojis of emoji challenges with their names.
+var emojis = [][2]string{
+	{"🐱", "cat"}, {"🐶", "dog"}, {"🍎", "apple"}, {"🚗", "car"}, {"🌵", "cactus"}, {"⚽", "ball"},
+	{"🎸", "guitar"}, {"🐟", "fish"}, {"🌙", "moon"}, {"🔑", "key"}, {"🍕", "pizza"}, {"🚀", "rocket"},
+}
+
+// Challenge is a question a new member has to answer by picking one of the options.
+type Challenge struct {
+	ID        string
+	ChatID    int64
+	UserID    int64
+	MessageID int // message with the challenge, zero until it is sent
+	Question  string
+	Options   []string
+	ExpiresAt time.Time
+
+	answer int
+}
+
+// IsCorrect reports whether the option is the answer.
+func (c Challenge) IsCorrect(option int) bool {
+	return option == c.answer
+}
+
+// newQuestion returns the question of a challenge of the kind, its options and the index of the answer.
+func newQuestion(kind Kind) (string, []string, int) {
+	switch kind {
+	case KindMath:
+		a, b := rand.IntN(9)+1, rand.IntN(9)+1 //nolint:gosec,mnd // not a secret, single digits
+
+		// Wrong options are the nearest sums, so that they can't be told apart by magnitude
+		options := []string{}
+		for _, delta := range rand.Perm(mathOptions*2 + 1) {
+			if sum := a + b + delta - mathOptions; sum > 1 && sum != a+b && len(options) < mathOptions-1 {
+				options = append(options, strconv.Itoa(sum))
+			}
+		}
+
+		answer := rand.IntN(len(options) + 1) //nolint:gosec // not a secret
+		options = append(options[:answer], append([]string{strconv.Itoa(a + b)}, options[answer:]...)...)
+
+		return "How much is " + strconv.Itoa(a) + " + " + strconv.Itoa(b) + "?", options, answer
+	case KindEmoji:
+		options := make([]string, 0, emojiOptions)
+		for _, i := range rand.Perm(len(emojis))[:emojiOptions] {
+			options = append(options, emojis[i][0])
+		}
+
+		answer := rand.IntN(emojiOptions) //nolint:gosec // not a secret
+		name := emojis[0][1]
+		for _, e := range emojis {
+			if e[0] == options[answer] {
+				name = e[1]
+			}
+		}
+
+		return "Press the " + name + ".", options, answer
+	case KindButton:
+	}
+
+	return "Press the button to confirm you are not a bot.", []string{"I'm not a bot"}, 0
+}
@@ -0,0 +1,44 @@
+package captcha
+
+import (
+	"fmt"
+	"time"
+)
+
+// Kind is the kind of challenge new members have to solve.
+type Kind string
+
+const (
+	KindButton Kind = "button" // press the only button
+	KindMath   Kind = "math"   // pick the sum of two numbers
+	KindEmoji  Kind = "emoji"  // pick the named emoji
+)
+
+type Config struct {
+	Kind    Kind          // challenge kind, the captcha is disabled when empty
+	Timeout time.Duration // time to solve the challenge before the user is kicked
+}
+
+// Enabled reports whether new members have to solve a challenge.
+func (c Config) Enabled() bool {
+	return c.Kind != ""
+}
+
+// Validate checks if the configuration is valid.
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	switch c.Kind {
+	case KindButton, KindMath, KindEmoji:
+	default:
+		return fmt.Errorf("%w: invalid kind: %s", ErrInvalidConfig, c.Kind)
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("%w: timeout must be positive, got: %s", ErrInvalidConfig, c.Timeout)
+	}
+
+	return nil
+}
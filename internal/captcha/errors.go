@@ -0,0 +1,7 @@
+package captcha
+
+import "errors"
+
+var (
+	ErrInvalidConfig = errors.New("invalid config")
+)
@@ -0,0 +1,14 @@
+package captcha
+
+import (
+	"github.com/go-core-fx/logger"
+	"go.uber.org/fx"
+)
+
+func Module() fx.Option {
+	return fx.Module(
+		"captcha",
+		logger.WithNamedLogger("captcha"),
+		fx.Provide(New),
+	)
+}
@@ -2,6 +2,7 @@ package censor
 
 import (
 	"fmt"
+	"maps"
 	"time"
 
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
@@ -12,8 +13,12 @@ type Config struct {
 	Strategy    ExecutionStrategy
 	Timeout     time.Duration
 	Plugins     map[string]PluginConfig
+	Chats       map[int64]ChatConfig // per-chat overrides keyed by chat ID
 	EnabledOnly bool
 
+	// ScoreThreshold is the summed weighted score at which the scoring strategy blocks a message.
+	ScoreThreshold float64
+
 	ErrorAction plugin.Action
 	SkipAction  plugin.Action
 }
@@ -22,9 +27,80 @@ type Config struct {
 type PluginConfig struct {
 	Enabled  bool
 	Priority int
+	Weight   float64 // Weight of the plugin's score in the scoring strategy
+	Shadow   bool    // Shadow plugins' blocks are reported but not acted upon
 	Config   map[string]any
 }
 
+// ChatConfig overrides plugin configuration for a single chat.
+type ChatConfig struct {
+	Plugins map[string]PluginOverride
+}
+
+// PluginOverride replaces selected fields of PluginConfig, nil fields are inherited.
+type PluginOverride struct {
+	Enabled  *bool
+	Priority *int
+	Weight   *float64
+	Shadow   *bool
+	Config   map[string]any // replaces the whole plugin configuration and creates a dedicated plugin instance
+}
+
+// ForChat returns the configuration effective for the chat with overrides applied.
+func (c Config) ForChat(chatID int64) Config {
+	chat, ok := c.Chats[chatID]
+	if !ok {
+		return c
+	}
+
+	plugins := make(map[string]PluginConfig, len(c.Plugins)+len(chat.Plugins))
+	maps.Copy(plugins, c.Plugins)
+
+	for name, override := range chat.Plugins {
+		config, exists := plugins[name]
+		if !exists {
+			config = PluginConfig{
+				Enabled:  false,
+				Priority: 0,
+				Weight:   DefaultPluginWeight,
+				Shadow:   false,
+				Config:   map[string]any{},
+			}
+		}
+
+		if override.Enabled != nil {
+			config.Enabled = *override.Enabled
+		}
+		if override.Priority != nil {
+			config.Priority = *override.Priority
+		}
+		if override.Weight != nil {
+			config.Weight = *override.Weight
+		}
+		if override.Shadow != nil {
+			config.Shadow = *override.Shadow
+		}
+		if override.Config != nil {
+			config.Config = override.Config
+		}
+
+		plugins[name] = config
+	}
+
+	c.Plugins = plugins
+	c.Chats = nil
+
+	return c
+}
+
+// pluginConfig returns the configuration map of the plugin, empty if the plugin is not configured.
+func (c Config) pluginConfig(name string) map[string]any {
+	if v, ok := c.Plugins[name]; ok && v.Config != nil {
+		return v.Config
+	}
+	return map[string]any{}
+}
+
 // Validate checks if the configuration is valid.
 func (c Config) Validate() error {
 	// Check strategy
@@ -37,6 +113,11 @@ func (c Config) Validate() error {
 		return fmt.Errorf("%w: invalid timeout: %s", ErrInvalidConfig, c.Timeout)
 	}
 
+	// Check score threshold
+	if c.Strategy == StrategyScoring && c.ScoreThreshold <= 0 {
+		return fmt.Errorf("%w: invalid score threshold: %f", ErrInvalidConfig, c.ScoreThreshold)
+	}
+
 	// Check action fields
 	validActions := map[plugin.Action]bool{
 		plugin.ActionSkip:  false,
@@ -55,6 +136,27 @@ func (c Config) Validate() error {
 		if config.Priority < 0 {
 			return fmt.Errorf("%w: invalid priority for plugin %s: %d", ErrInvalidConfig, name, config.Priority)
 		}
+		if config.Weight < 0 {
+			return fmt.Errorf("%w: invalid weight for plugin %s: %f", ErrInvalidConfig, name, config.Weight)
+		}
+	}
+
+	// Check chat overrides
+	for chatID := range c.Chats {
+		for name, config := range c.ForChat(chatID).Plugins {
+			if config.Priority < 0 {
+				return fmt.Errorf(
+					"%w: invalid priority for plugin %s in chat %d: %d",
+					ErrInvalidConfig, name, chatID, config.Priority,
+				)
+			}
+			if config.Weight < 0 {
+				return fmt.Errorf(
+					"%w: invalid weight for plugin %s in chat %d: %f",
+					ErrInvalidConfig, name, chatID, config.Weight,
+				)
+			}
+		}
 	}
 
 	return nil
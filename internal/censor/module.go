@@ -2,10 +2,8 @@ package censor
 
 import (
 	"context"
-	"fmt"
 	"time"
 
-	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugins"
 	"github.com/go-core-fx/logger"
 	"go.uber.org/fx"
@@ -21,29 +19,12 @@ func Module() fx.Option {
 
 		// Provide plugins
 		plugins.Module(),
-		fx.Provide(fx.Annotate(
-			func(metadata []plugin.Metadata, config Config) ([]plugin.Plugin, error) {
-				plugins := make([]plugin.Plugin, 0, len(metadata))
-				for _, m := range metadata {
-					configMap := map[string]any{}
-					if v, ok := config.Plugins[m.Name]; ok {
-						configMap = v.Config
-					}
-
-					p, err := m.Factory(configMap)
-					if err != nil {
-						return nil, fmt.Errorf("failed to create plugin %s: %w", m.Name, err)
-					}
 
-					plugins = append(plugins, p)
-				}
-				return plugins, nil
-			},
+		// Provide service
+		fx.Provide(fx.Annotate(
+			New,
 			fx.ParamTags(`group:"metadata"`),
 		)),
-
-		// Provide service
-		fx.Provide(New),
 		fx.Invoke(func(svc *Service, lc fx.Lifecycle) {
 			ctx, cancel := context.WithCancel(context.Background())
 			waitCh := make(chan struct{})
@@ -71,6 +52,7 @@ func Module() fx.Option {
 					case <-waitCh:
 					case <-ctx.Done():
 					}
+					svc.Close()
 					return nil
 				},
 			})
@@ -1,6 +1,23 @@
 package plugin
 
-import "context"
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// MetadataKeyScore is the metadata key a plugin may use to report its own score (0.0 - 1.0).
+	MetadataKeyScore = "score"
+	// MetadataKeyRecommendation is the metadata key a plugin may use to recommend a moderation action (Recommendation).
+	MetadataKeyRecommendation = "recommendation"
+	// MetadataKeySeverity is the metadata key a plugin may use to report the severity of a violation (Severity).
+	MetadataKeySeverity = "severity"
+	// MetadataKeyRelated is the metadata key a plugin may use to report earlier messages to be blocked
+	// together with the evaluated one ([]MessageRef), e.g. the rest of a spam wave.
+	MetadataKeyRelated = "related"
+)
 
 // Result represents the decision made by a plugin.
 type Result struct {
@@ -19,20 +36,265 @@ const (
 	ActionBlock Action = "block" // Block the message
 )
 
+// Score returns the plugin's contribution to the scoring strategy before weighting.
+// A score reported via MetadataKeyScore takes precedence; otherwise Block scores 1.0
+// and any other action scores 0.0.
+func (r Result) Score() float64 {
+	if score, ok := r.Metadata[MetadataKeyScore].(float64); ok {
+		return min(max(score, 0), 1)
+	}
+
+	if r.Action == ActionBlock {
+		return 1
+	}
+
+	return 0
+}
+
+// Recommendation returns the moderation action recommended via MetadataKeyRecommendation, if any.
+func (r Result) Recommendation() Recommendation {
+	if rec, ok := r.Metadata[MetadataKeyRecommendation].(Recommendation); ok && rec.IsValid() {
+		return rec
+	}
+
+	return RecommendationNone
+}
+
+// Severity returns the severity reported via MetadataKeySeverity, if any.
+func (r Result) Severity() Severity {
+	if severity, ok := r.Metadata[MetadataKeySeverity].(Severity); ok && severity.IsValid() {
+		return severity
+	}
+
+	return SeverityNone
+}
+
+// Related returns the earlier messages reported via MetadataKeyRelated, if any.
+func (r Result) Related() []MessageRef {
+	if related, ok := r.Metadata[MetadataKeyRelated].([]MessageRef); ok {
+		return related
+	}
+
+	return nil
+}
+
 func (a Action) IsValid() bool {
 	return a == ActionSkip || a == ActionAllow || a == ActionBlock
 }
 
+// Recommendation is a moderation action a plugin recommends for a blocked message,
+// taking precedence over the bot's escalation ladder.
+type Recommendation string
+
+const (
+	RecommendationNone           Recommendation = ""                // Use the escalation ladder
+	RecommendationWarnOnly       Recommendation = "warn_only"       // Warn the user, keep the message
+	RecommendationDeleteSilently Recommendation = "delete_silently" // Delete the message without notifying anyone
+	RecommendationMute           Recommendation = "mute"            // Delete the message and mute the user
+	RecommendationBanImmediately Recommendation = "ban_immediately" // Delete the message and ban the user forever
+)
+
+func (r Recommendation) IsValid() bool {
+	switch r {
+	case RecommendationNone,
+		RecommendationWarnOnly,
+		RecommendationDeleteSilently,
+		RecommendationMute,
+		RecommendationBanImmediately:
+		return true
+	default:
+		return false
+	}
+}
+
+// Severity describes how serious a violation is.
+type Severity string
+
+const (
+	SeverityNone     Severity = ""
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+func (s Severity) IsValid() bool {
+	return s.Level() >= 0
+}
+
+// Level returns the numeric level of the severity for comparison, -1 if it is invalid.
+func (s Severity) Level() int {
+	switch s {
+	case SeverityNone:
+		return 0
+	case SeverityLow:
+		return 1
+	case SeverityMedium:
+		return 2 //nolint:mnd // severity level
+	case SeverityHigh:
+		return 3 //nolint:mnd // severity level
+	case SeverityCritical:
+		return 4 //nolint:mnd // severity level
+	default:
+		return -1
+	}
+}
+
 // Message contains all inspectable content from a Telegram message.
 type Message struct {
-	Text                string // Message text
-	Caption             string // Message caption (for media)
-	UserID              int64  // User ID who sent the message
-	ChatID              int64  // Chat ID where message was sent
-	MessageID           int    // Message ID
-	IsEdit              bool   // Whether this is an edited message
-	ForwardedFromUserID *int64 // User ID of original message author (if forwarded)
-	ForwardedFromChatID *int64 // Chat ID where original message was sent (if forwarded)
+	Text                string   // Message text
+	Caption             string   // Message caption (for media)
+	UserID              int64    // User ID who sent the message
+	ChatID              int64    // Chat ID where message was sent
+	MessageID           int      // Message ID
+	IsEdit              bool     // Whether this is an edited message
+	ForwardedFromUserID *int64   // User ID of original message author (if forwarded)
+	ForwardedFromChatID *int64   // Chat ID where original message was sent (if forwarded)
+	Entities            []Entity // Entities of the text
+	CaptionEntities     []Entity // Entities of the caption
+
+	Sender       Sender   // Profile of the user who sent the message
+	SenderChatID *int64   // Chat ID the message was sent on behalf of (anonymous admins, channels)
+	ViaBot       *Bot     // Bot the inline message was sent via
+	ReplyTo      *Reply   // Message this message replies to
+	Media        *Media   // Attached media (nil for text messages)
+	Buttons      []Button // Inline keyboard buttons attached to the message, row by row
+
+	Source string // Source isolating the state of stateful plugins, empty for Telegram updates
+}
+
+// SourceAPI is the source of messages evaluated by the moderation API.
+const SourceAPI = "api"
+
+// StateKey returns the key of plugin state prefixed with the source of the message, so that messages
+// of other sources, e.g. the moderation API, never affect the state built from Telegram updates.
+func (m Message) StateKey(key string) string {
+	if m.Source == "" {
+		return key
+	}
+
+	return m.Source + ":" + key
+}
+
+// MessageRef references a message sent to a chat.
+type MessageRef struct {
+	ChatID    int64 // Chat ID where the message was sent
+	UserID    int64 // User ID who sent the message
+	MessageID int   // Message ID
+}
+
+// Sender is the profile of the user who sent the message.
+type Sender struct {
+	FirstName string // First name
+	LastName  string // Last name
+	Username  string // Username without "@"
+	IsBot     bool   // Whether the sender is a bot
+}
+
+// Name returns the full name of the sender.
+func (s Sender) Name() string {
+	return strings.TrimSpace(s.FirstName + " " + s.LastName)
+}
+
+// Bot is a bot an inline message was sent via.
+type Bot struct {
+	ID       int64  // Bot user ID
+	Username string // Bot username without "@"
+}
+
+// Reply is the message replied to.
+type Reply struct {
+	MessageID    int    // Message ID
+	UserID       int64  // User ID who sent the message
+	SenderChatID *int64 // Chat ID the message was sent on behalf of
+	Text         string // Message text or caption
+}
+
+// MediaKind is the kind of media attached to a message.
+type MediaKind string
+
+const (
+	MediaKindPhoto     MediaKind = "photo"
+	MediaKindVideo     MediaKind = "video"
+	MediaKindAnimation MediaKind = "animation"
+	MediaKindDocument  MediaKind = "document"
+	MediaKindAudio     MediaKind = "audio"
+	MediaKindVoice     MediaKind = "voice"
+	MediaKindVideoNote MediaKind = "video_note"
+	MediaKindSticker   MediaKind = "sticker"
+	MediaKindContact   MediaKind = "contact"
+	MediaKindLocation  MediaKind = "location"
+	MediaKindVenue     MediaKind = "venue"
+	MediaKindPoll      MediaKind = "poll"
+	MediaKindDice      MediaKind = "dice"
+	MediaKindGame      MediaKind = "game"
+	MediaKindStory     MediaKind = "story"
+)
+
+// Media is the media attached to a message, file fields are empty for media without a file, e.g. a poll.
+type Media struct {
+	Kind         MediaKind // Kind of media
+	FileID       string    // File ID, can be used to download the file
+	FileUniqueID string    // Unique file ID, the same for the same file across bots and chats
+	FileName     string    // Original file name
+	MimeType     string    // MIME type of the file
+	FileSize     int64     // File size in bytes
+	Width        int       // Width of photos, videos, animations and stickers
+	Height       int       // Height of photos, videos, animations and stickers
+	Duration     int       // Duration of audio and video in seconds
+	IsAnimated   bool      // Whether the sticker is animated
+}
+
+// Button is an inline keyboard button.
+type Button struct {
+	Text         string // Button label
+	URL          string // URL opened on tap
+	CallbackData string // Data sent to the bot on tap
+}
+
+// Content returns the text of the message, or the caption if the text is empty.
+func (m Message) Content() string {
+	if m.Text != "" {
+		return m.Text
+	}
+
+	return m.Caption
+}
+
+// HiddenURLs returns the URLs not visible in the text or the caption: targets of text links and URLs of buttons.
+func (m Message) HiddenURLs() []string {
+	urls := []string{}
+	for _, e := range slices.Concat(m.Entities, m.CaptionEntities) {
+		if e.Type == "text_link" && e.URL != "" {
+			urls = append(urls, e.URL)
+		}
+	}
+
+	for _, b := range m.Buttons {
+		if b.URL != "" {
+			urls = append(urls, b.URL)
+		}
+	}
+
+	return urls
+}
+
+// Entity is a special entity of a text, e.g. a URL, a hidden link or a mention.
+type Entity struct {
+	Type   string // Telegram entity type, e.g. "url", "text_link", "mention"
+	Offset int    // Offset in UTF-16 code units
+	Length int    // Length in UTF-16 code units
+	URL    string // URL opened on tap, for "text_link" only
+}
+
+// Text returns the part of the text covered by the entity, or an empty string if it is out of range.
+func (e Entity) Text(text string) string {
+	units := utf16.Encode([]rune(text))
+	if e.Offset < 0 || e.Length < 0 || e.Offset+e.Length > len(units) {
+		return ""
+	}
+
+	return string(utf16.Decode(units[e.Offset : e.Offset+e.Length]))
 }
 
 // Metadata contains information about a plugin.
@@ -57,3 +319,25 @@ type Plugin interface {
 	// Called periodically to clean up expired entries.
 	Cleanup(ctx context.Context)
 }
+
+// Recorder is implemented by plugins keeping track of the messages which passed the evaluation,
+// e.g. to count the messages of users.
+type Recorder interface {
+	// Record is called after the evaluation of a message which was not blocked,
+	// whether the plugin was evaluated or not.
+	Record(ctx context.Context, msg Message) error
+}
+
+// ChatScoped is implemented by plugins keeping state per instance, e.g. data added by admins,
+// which must not be shared by the instances created for chat overrides.
+type ChatScoped interface {
+	// ScopeToChat is called on every instance created for the override of the chat.
+	ScopeToChat(chatID int64)
+}
+
+// Closer is implemented by plugins holding resources, such as connections, which must be released
+// when the instance is replaced on configuration reload or the application stops.
+type Closer interface {
+	// Close releases the resources of the plugin, it is not evaluated afterwards.
+	Close() error
+}
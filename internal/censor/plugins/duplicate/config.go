@@ -2,6 +2,7 @@ package duplicate
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
@@ -16,12 +17,74 @@ const (
 	MinWindow = 10 * time.Second
 	// MaxWindow is the maximum reasonable window duration.
 	MaxWindow = 24 * time.Hour
+	// DefaultMaxDistance is the default number of differing fingerprint bits of near-duplicates.
+	DefaultMaxDistance = 6
+	// MaxMaxDistance is the maximum number of differing fingerprint bits, unrelated texts differ in about 32.
+	MaxMaxDistance = 16
+	// DefaultWaveChats is the default number of chats the same content is sent to to be a spam wave.
+	DefaultWaveChats = 3
+	// DefaultWaveUsers is the default number of users sending the same content to be a spam wave.
+	DefaultWaveUsers = 3
+	// DefaultWaveWindow is the default time window of a spam wave.
+	DefaultWaveWindow = time.Minute
+	// MaxWaveWindow is the maximum reasonable window of a spam wave.
+	MaxWaveWindow = time.Hour
 )
 
+// Mode is the way messages are compared.
+type Mode string
+
+const (
+	ModeExact Mode = "exact" // normalized texts are equal, also used when the mode is empty
+	ModeFuzzy Mode = "fuzzy" // SimHash fingerprints of normalized texts differ in at most MaxDistance bits
+)
+
+// Scope is the way occurrences of a message are grouped.
+type Scope string
+
+const (
+	ScopeChat     Scope = "chat"      // per chat regardless of the sender, also used when the scope is empty
+	ScopeUser     Scope = "user"      // per sender across all chats
+	ScopeUserChat Scope = "user+chat" // per sender in each chat
+	ScopeGlobal   Scope = "global"    // across all chats and senders
+)
+
+// Key returns the key occurrences of a message sent by the user to the chat are grouped under.
+func (s Scope) Key(chatID, userID int64) string {
+	switch s {
+	case ScopeUser:
+		return "user:" + strconv.FormatInt(userID, 10)
+	case ScopeUserChat:
+		return strconv.FormatInt(chatID, 10) + ":user:" + strconv.FormatInt(userID, 10)
+	case ScopeGlobal:
+		return "global"
+	case ScopeChat:
+	}
+
+	return strconv.FormatInt(chatID, 10)
+}
+
+// DefaultWhitelist returns common short phrases which are legitimately repeated by different users.
+func DefaultWhitelist() []string {
+	return []string{
+		"thanks", "thank you", "thx", "okay", "yes", "hello", "lol", "+1",
+		"good morning", "good night", "congratulations",
+		"спасибо", "привет", "да", "нет", "ок",
+	}
+}
+
 // Config represents the configuration for the duplicate detection plugin.
 type Config struct {
 	MaxDuplicates int           // Maximum number of duplicate messages allowed before blocking
 	Window        time.Duration // Time window to consider messages as duplicates
+	Mode          Mode          // Way messages are compared
+	MaxDistance   int           // Maximum number of differing fingerprint bits in fuzzy mode
+	Scope         Scope         // Way occurrences of a message are grouped
+	Whitelist     []string      // Phrases never counted as duplicates, compared as normalized texts
+	CrossChat     bool          // Detect the same content spreading across chats and users
+	WaveChats     int           // Number of chats the same content is sent to to be a spam wave, 0 to disable
+	WaveUsers     int           // Number of users sending the same content to be a spam wave, 0 to disable
+	WaveWindow    time.Duration // Time window of a spam wave
 }
 
 // NewConfig creates a new configuration from the provided map.
@@ -56,6 +119,99 @@ func NewConfig(config map[string]any) (Config, error) {
 		}
 	}
 
+	// Parse Mode
+	if mode, ok := config["mode"]; ok {
+		str, modeStrOk := mode.(string)
+		if !modeStrOk {
+			return Config{}, fmt.Errorf(
+				"%w: failed to parse mode: expected string, got %T",
+				plugin.ErrInvalidConfig,
+				mode,
+			)
+		}
+		c.Mode = Mode(str)
+	}
+
+	// Parse MaxDistance
+	if maxDistance, ok := config["max_distance"]; ok {
+		if c.MaxDistance, ok = maxDistance.(int); !ok {
+			return Config{}, fmt.Errorf(
+				"%w: failed to parse max_distance: expected int, got %T",
+				plugin.ErrInvalidConfig,
+				maxDistance,
+			)
+		}
+	}
+
+	// Parse Scope
+	if scope, ok := config["scope"]; ok {
+		str, scopeStrOk := scope.(string)
+		if !scopeStrOk {
+			return Config{}, fmt.Errorf(
+				"%w: failed to parse scope: expected string, got %T",
+				plugin.ErrInvalidConfig,
+				scope,
+			)
+		}
+		c.Scope = Scope(str)
+	}
+
+	// Parse Whitelist
+	var err error
+	if c.Whitelist, err = plugin.SliceFromAnyOrDefault(config, "whitelist", c.Whitelist); err != nil {
+		return Config{}, err
+	}
+
+	// Parse CrossChat
+	if crossChat, ok := config["cross_chat"]; ok {
+		if c.CrossChat, ok = crossChat.(bool); !ok {
+			return Config{}, fmt.Errorf(
+				"%w: failed to parse cross_chat: expected bool, got %T",
+				plugin.ErrInvalidConfig,
+				crossChat,
+			)
+		}
+	}
+
+	// Parse WaveChats
+	if waveChats, ok := config["wave_chats"]; ok {
+		if c.WaveChats, ok = waveChats.(int); !ok {
+			return Config{}, fmt.Errorf(
+				"%w: failed to parse wave_chats: expected int, got %T",
+				plugin.ErrInvalidConfig,
+				waveChats,
+			)
+		}
+	}
+
+	// Parse WaveUsers
+	if waveUsers, ok := config["wave_users"]; ok {
+		if c.WaveUsers, ok = waveUsers.(int); !ok {
+			return Config{}, fmt.Errorf(
+				"%w: failed to parse wave_users: expected int, got %T",
+				plugin.ErrInvalidConfig,
+				waveUsers,
+			)
+		}
+	}
+
+	// Parse WaveWindow
+	if window, ok := config["wave_window"]; ok {
+		str, windowStrOk := window.(string)
+		if !windowStrOk {
+			return Config{}, fmt.Errorf(
+				"%w: failed to parse wave_window: expected string, got %T",
+				plugin.ErrInvalidConfig,
+				window,
+			)
+		}
+
+		var err error
+		if c.WaveWindow, err = time.ParseDuration(str); err != nil {
+			return Config{}, fmt.Errorf("%w: failed to parse wave_window: %w", plugin.ErrInvalidConfig, err)
+		}
+	}
+
 	// Validate the configuration
 	if err := c.Validate(); err != nil {
 		return Config{}, err
@@ -69,6 +225,14 @@ func DefaultConfig() Config {
 	return Config{
 		MaxDuplicates: DefaultMaxDuplicates,
 		Window:        DefaultWindow,
+		Mode:          ModeExact,
+		MaxDistance:   DefaultMaxDistance,
+		Scope:         ScopeChat,
+		Whitelist:     DefaultWhitelist(),
+		CrossChat:     false,
+		WaveChats:     DefaultWaveChats,
+		WaveUsers:     DefaultWaveUsers,
+		WaveWindow:    DefaultWaveWindow,
 	}
 }
 
@@ -92,5 +256,61 @@ func (c Config) Validate() error {
 		return fmt.Errorf("%w: window must not exceed %s, got: %s", plugin.ErrInvalidConfig, MaxWindow, c.Window)
 	}
 
+	// Check Mode
+	switch c.Mode {
+	case "", ModeExact, ModeFuzzy:
+	default:
+		return fmt.Errorf("%w: invalid mode: %s", plugin.ErrInvalidConfig, c.Mode)
+	}
+
+	// Check Scope
+	switch c.Scope {
+	case "", ScopeChat, ScopeUser, ScopeUserChat, ScopeGlobal:
+	default:
+		return fmt.Errorf("%w: invalid scope: %s", plugin.ErrInvalidConfig, c.Scope)
+	}
+
+	// Check MaxDistance
+	if c.Mode == ModeFuzzy && (c.MaxDistance < 0 || c.MaxDistance > MaxMaxDistance) {
+		return fmt.Errorf(
+			"%w: max_distance must be between 0 and %d, got: %d",
+			plugin.ErrInvalidConfig,
+			MaxMaxDistance,
+			c.MaxDistance,
+		)
+	}
+
+	if c.CrossChat {
+		return c.validateWave()
+	}
+
+	return nil
+}
+
+// validateWave checks the spam wave settings of the cross-chat mode.
+func (c Config) validateWave() error {
+	// A single chat or user is not a wave
+	if c.WaveChats != 0 && c.WaveChats < 2 {
+		return fmt.Errorf("%w: wave_chats must be 0 or at least 2, got: %d", plugin.ErrInvalidConfig, c.WaveChats)
+	}
+
+	if c.WaveUsers != 0 && c.WaveUsers < 2 {
+		return fmt.Errorf("%w: wave_users must be 0 or at least 2, got: %d", plugin.ErrInvalidConfig, c.WaveUsers)
+	}
+
+	if c.WaveChats == 0 && c.WaveUsers == 0 {
+		return fmt.Errorf("%w: wave_chats and wave_users must not both be 0", plugin.ErrInvalidConfig)
+	}
+
+	if c.WaveWindow < MinWindow || c.WaveWindow > MaxWaveWindow {
+		return fmt.Errorf(
+			"%w: wave_window must be between %s and %s, got: %s",
+			plugin.ErrInvalidConfig,
+			MinWindow,
+			MaxWaveWindow,
+			c.WaveWindow,
+		)
+	}
+
 	return nil
 }
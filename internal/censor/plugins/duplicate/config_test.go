@@ -24,6 +24,14 @@ func TestConfig_NewConfig(t *testing.T) {
 			want: duplicate.Config{
 				MaxDuplicates: 5,
 				Window:        10 * time.Minute,
+				Mode:          duplicate.ModeExact,
+				MaxDistance:   duplicate.DefaultMaxDistance,
+				Scope:         duplicate.ScopeChat,
+				Whitelist:     duplicate.DefaultWhitelist(),
+				CrossChat:     false,
+				WaveChats:     duplicate.DefaultWaveChats,
+				WaveUsers:     duplicate.DefaultWaveUsers,
+				WaveWindow:    duplicate.DefaultWaveWindow,
 			},
 			wantErr: false,
 		},
@@ -33,10 +41,141 @@ func TestConfig_NewConfig(t *testing.T) {
 			want: duplicate.Config{
 				MaxDuplicates: 1,
 				Window:        5 * time.Minute,
+				Mode:          duplicate.ModeExact,
+				MaxDistance:   duplicate.DefaultMaxDistance,
+				Scope:         duplicate.ScopeChat,
+				Whitelist:     duplicate.DefaultWhitelist(),
+				CrossChat:     false,
+				WaveChats:     duplicate.DefaultWaveChats,
+				WaveUsers:     duplicate.DefaultWaveUsers,
+				WaveWindow:    duplicate.DefaultWaveWindow,
 				// default
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid fuzzy configuration",
+			config: map[string]any{
+				"mode":         "fuzzy",
+				"max_distance": 3,
+			},
+			want: duplicate.Config{
+				MaxDuplicates: 1,
+				Window:        5 * time.Minute,
+				Mode:          duplicate.ModeFuzzy,
+				MaxDistance:   3,
+				Scope:         duplicate.ScopeChat,
+				Whitelist:     duplicate.DefaultWhitelist(),
+				CrossChat:     false,
+				WaveChats:     duplicate.DefaultWaveChats,
+				WaveUsers:     duplicate.DefaultWaveUsers,
+				WaveWindow:    duplicate.DefaultWaveWindow,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid mode",
+			config: map[string]any{
+				"mode": "similar",
+			},
+			wantErr: true,
+		},
+		{
+			name: "max_distance out of range",
+			config: map[string]any{
+				"mode":         "fuzzy",
+				"max_distance": 20,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid cross-chat configuration",
+			config: map[string]any{
+				"cross_chat":  true,
+				"wave_chats":  0,
+				"wave_users":  5,
+				"wave_window": "30s",
+			},
+			want: duplicate.Config{
+				MaxDuplicates: 1,
+				Window:        5 * time.Minute,
+				Mode:          duplicate.ModeExact,
+				MaxDistance:   duplicate.DefaultMaxDistance,
+				Scope:         duplicate.ScopeChat,
+				Whitelist:     duplicate.DefaultWhitelist(),
+				CrossChat:     true,
+				WaveChats:     0,
+				WaveUsers:     5,
+				WaveWindow:    30 * time.Second,
+			},
+			wantErr: false,
+		},
+		{
+			name: "single chat wave",
+			config: map[string]any{
+				"cross_chat": true,
+				"wave_chats": 1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "disabled wave thresholds",
+			config: map[string]any{
+				"cross_chat": true,
+				"wave_chats": 0,
+				"wave_users": 0,
+			},
+			wantErr: true,
+		},
+		{
+			name: "wave_window too large",
+			config: map[string]any{
+				"cross_chat":  true,
+				"wave_window": "2h",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid scope and whitelist",
+			config: map[string]any{
+				"scope":     "user+chat",
+				"whitelist": []any{"thanks", "see you"},
+			},
+			want: duplicate.Config{
+				MaxDuplicates: 1,
+				Window:        5 * time.Minute,
+				Mode:          duplicate.ModeExact,
+				MaxDistance:   duplicate.DefaultMaxDistance,
+				Scope:         duplicate.ScopeUserChat,
+				Whitelist:     []string{"thanks", "see you"},
+				CrossChat:     false,
+				WaveChats:     duplicate.DefaultWaveChats,
+				WaveUsers:     duplicate.DefaultWaveUsers,
+				WaveWindow:    duplicate.DefaultWaveWindow,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid scope",
+			config: map[string]any{
+				"scope": "channel",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid whitelist type",
+			config: map[string]any{
+				"whitelist": "thanks",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid cross_chat type",
+			config: map[string]any{
+				"cross_chat": "yes",
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid max_duplicates type",
 			config: map[string]any{
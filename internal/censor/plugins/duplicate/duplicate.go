@@ -6,18 +6,36 @@ import (
 	"fmt"
 	"hash/fnv"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
 )
 
 const (
-	minTextLength = 3 // Minimum text length for duplicate detection
+	minTextLength = 3  // Minimum text length for duplicate detection
+	minWaveLength = 20 // Minimum text length for spam wave detection, shorter texts are common replies
+
+	// wavesScope is the scope near-duplicates of all chats are grouped under for spam wave detection
+	wavesScope = "wave"
 )
 
 var multiSpaceRegex = regexp.MustCompile(`\s+`)
 
-func Metadata() plugin.Metadata {
+// Recorder tracks occurrences of messages within a window.
+type Recorder interface {
+	Record(ctx context.Context, scope, messageHash string) (Entry, error)
+	// RecordSimilar records a message by its fingerprint, counting it as an occurrence of the first
+	// message within the window whose fingerprint differs in at most maxDistance bits.
+	RecordSimilar(ctx context.Context, scope string, fingerprint uint64, maxDistance int) (Entry, error)
+	Cleanup()
+}
+
+// Metadata returns the plugin metadata, occurrences are shared between replicas if kv is not nil.
+func Metadata(kv storage.KV) plugin.Metadata {
 	return plugin.Metadata{
 		Name: "duplicate",
 		Factory: func(params map[string]any) (plugin.Plugin, error) {
@@ -26,21 +44,65 @@ func Metadata() plugin.Metadata {
 				return nil, err
 			}
 
+			if kv != nil {
+				return NewShared(config, kv), nil
+			}
+
 			return New(config), nil
 		},
 	}
 }
 
 type Plugin struct {
-	storage *Storage
+	storage Recorder
 	config  Config
+
+	// spam waves of the cross-chat mode, clusters group near-duplicates across chats in fuzzy mode
+	waves    WaveTracker
+	clusters Recorder
+
+	// keys of whitelisted phrases, see phraseKey
+	whitelist map[string]struct{}
 }
 
+// New creates a plugin tracking messages in memory.
 func New(config Config) plugin.Plugin {
-	return &Plugin{
-		storage: NewStorage(config.Window),
+	return newPlugin(
+		config,
+		NewStorage(config.Window),
+		NewWaveStorage(config.WaveWindow, config.WaveChats, config.WaveUsers),
+		NewStorage(config.WaveWindow),
+	)
+}
+
+// NewShared creates a plugin tracking messages in the shared store.
+func NewShared(config Config, kv storage.KV) plugin.Plugin {
+	return newPlugin(
+		config,
+		NewSharedStorage(config.Window, kv),
+		NewSharedWaveStorage(config.WaveWindow, config.WaveChats, config.WaveUsers, kv),
+		NewSharedStorage(config.WaveWindow, kv),
+	)
+}
+
+func newPlugin(config Config, storage Recorder, waves WaveTracker, clusters Recorder) *Plugin {
+	p := &Plugin{
+		storage: storage,
 		config:  config,
+
+		waves:    waves,
+		clusters: clusters,
+
+		whitelist: make(map[string]struct{}, len(config.Whitelist)),
+	}
+
+	for _, phrase := range config.Whitelist {
+		if key := phraseKey(p.normalizeText(phrase)); key != "" {
+			p.whitelist[key] = struct{}{}
+		}
 	}
+
+	return p
 }
 
 func (p *Plugin) Name() string {
@@ -52,10 +114,34 @@ func (p *Plugin) Priority() int {
 	return priority
 }
 
-func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result, error) {
+func (p *Plugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Result, error) {
 	// Get the message text to analyze
 	text := p.getMessageText(msg)
 
+	// Common phrases are legitimately repeated by different users
+	if _, ok := p.whitelist[phraseKey(text)]; ok {
+		return plugin.Result{
+			Action:   plugin.ActionSkip,
+			Reason:   "message is a whitelisted phrase",
+			Metadata: nil,
+			Plugin:   p.Name(),
+		}, nil
+	}
+
+	// The same content spreading across chats and users is blocked as a whole, edits are not new messages
+	if p.config.CrossChat && !msg.IsEdit && utf8.RuneCountInString(text) >= minWaveLength {
+		if result, detected, err := p.evaluateWave(ctx, msg, text); err != nil || detected {
+			return result, err
+		}
+	}
+
+	// Media without text is compared by its file, which is the same for every copy of the file
+	fuzzy := p.config.Mode == ModeFuzzy
+	if len(text) < minTextLength && msg.Media != nil && msg.Media.FileUniqueID != "" {
+		text = "media:" + msg.Media.FileUniqueID
+		fuzzy = false
+	}
+
 	// Skip empty or very short messages
 	if len(text) < minTextLength {
 		return plugin.Result{
@@ -66,8 +152,8 @@ func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result,
 		}, nil
 	}
 
-	// Generate message hash for duplicate detection
-	messageHash, err := p.generateMessageHash(text)
+	// Record duplicate and check if limit exceeded
+	messageHash, stat, err := p.record(ctx, p.scopeKey(msg), text, fuzzy)
 	if err != nil {
 		return plugin.Result{}, err
 	}
@@ -75,13 +161,18 @@ func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result,
 	// Calculate max occurrences (original + allowed duplicates)
 	maxOccurrences := p.config.MaxDuplicates + 1
 
-	// Record duplicate and check if limit exceeded
-	stat := p.storage.Record(
-		msg.ChatID,
-		messageHash,
-	)
-
 	if stat.Count > maxOccurrences {
+		metadata := map[string]any{
+			"count":           stat.Count,
+			"max_occurrences": maxOccurrences,
+			"window":          p.config.Window.String(),
+			"message_hash":    messageHash,
+			"scope":           string(p.config.Scope),
+		}
+		if fuzzy {
+			metadata["distance"] = stat.Distance
+		}
+
 		return plugin.Result{
 			Action: plugin.ActionBlock,
 			Reason: fmt.Sprintf(
@@ -90,13 +181,8 @@ func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result,
 				maxOccurrences,
 				p.config.Window,
 			),
-			Metadata: map[string]any{
-				"count":           stat.Count,
-				"max_occurrences": maxOccurrences,
-				"window":          p.config.Window.String(),
-				"message_hash":    messageHash,
-			},
-			Plugin: p.Name(),
+			Metadata: metadata,
+			Plugin:   p.Name(),
 		}, nil
 	}
 
@@ -111,6 +197,106 @@ func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result,
 	}, nil
 }
 
+// evaluateWave tracks the message in the spam wave of its content and blocks it if the wave is detected.
+// The message detecting the wave reports the earlier messages of the wave to be blocked with it,
+// later messages are deleted silently so that admins are notified of a wave once.
+func (p *Plugin) evaluateWave(ctx context.Context, msg plugin.Message, text string) (plugin.Result, bool, error) {
+	key, err := p.waveKey(ctx, msg, text)
+	if err != nil {
+		return plugin.Result{}, false, err
+	}
+
+	wave, err := p.waves.Track(
+		ctx,
+		msg.StateKey(key),
+		plugin.MessageRef{ChatID: msg.ChatID, UserID: msg.UserID, MessageID: msg.MessageID},
+	)
+	if err != nil {
+		return plugin.Result{}, false, err
+	}
+
+	if !wave.Detected {
+		return plugin.Result{}, false, nil
+	}
+
+	if !wave.New {
+		return plugin.Result{
+			Action: plugin.ActionBlock,
+			Reason: "message is part of a detected spam wave",
+			Metadata: map[string]any{
+				"wave_key":                       key,
+				plugin.MetadataKeyRecommendation: plugin.RecommendationDeleteSilently,
+			},
+			Plugin: p.Name(),
+		}, true, nil
+	}
+
+	return plugin.Result{
+		Action: plugin.ActionBlock,
+		Reason: fmt.Sprintf(
+			"spam wave detected (%d messages in %d chats from %d users within %s)",
+			len(wave.Messages)+1,
+			wave.Chats,
+			wave.Users,
+			p.config.WaveWindow,
+		),
+		Metadata: map[string]any{
+			"wave_key":                 key,
+			"wave_messages":            len(wave.Messages) + 1,
+			"wave_chats":               wave.Chats,
+			"wave_users":               wave.Users,
+			"window":                   p.config.WaveWindow.String(),
+			plugin.MetadataKeyRelated:  wave.Messages,
+			plugin.MetadataKeySeverity: plugin.SeverityHigh,
+		},
+		Plugin: p.Name(),
+	}, true, nil
+}
+
+// waveKey returns the key of the spam wave of the text, near-duplicates are keyed by the first message
+// of their cluster in fuzzy mode.
+func (p *Plugin) waveKey(ctx context.Context, msg plugin.Message, text string) (string, error) {
+	if p.config.Mode != ModeFuzzy {
+		return p.generateMessageHash(text)
+	}
+
+	stat, err := p.clusters.RecordSimilar(ctx, msg.StateKey(wavesScope), simhash(text), p.config.MaxDistance)
+	if err != nil {
+		return "", err
+	}
+
+	return "fuzzy:" + strconv.FormatUint(stat.Fingerprint, 16), nil
+}
+
+// scopeKey returns the key occurrences of the message are grouped under, messages sent on behalf
+// of a chat are attributed to the chat.
+func (p *Plugin) scopeKey(msg plugin.Message) string {
+	userID := msg.UserID
+	if msg.SenderChatID != nil {
+		userID = *msg.SenderChatID
+	}
+
+	return msg.StateKey(p.config.Scope.Key(msg.ChatID, userID))
+}
+
+// record records the message and returns its hash, exact or fingerprint, and the occurrences.
+func (p *Plugin) record(ctx context.Context, scope, text string, fuzzy bool) (string, Entry, error) {
+	if fuzzy {
+		fingerprint := simhash(text)
+		stat, err := p.storage.RecordSimilar(ctx, scope, fingerprint, p.config.MaxDistance)
+		return strconv.FormatUint(fingerprint, 16), stat, err
+	}
+
+	// Generate message hash for duplicate detection
+	messageHash, err := p.generateMessageHash(text)
+	if err != nil {
+		return "", Entry{}, err
+	}
+
+	stat, err := p.storage.Record(ctx, scope, messageHash)
+	return messageHash, stat, err
+}
+
 // getMessageText extracts the primary text content from a message.
 // Prefers Text over Caption, falling back to Caption if Text is empty.
 func (p *Plugin) getMessageText(msg plugin.Message) string {
@@ -135,6 +321,14 @@ func (p *Plugin) normalizeText(text string) string {
 	return strings.TrimSpace(text)
 }
 
+// phraseKey returns the normalized text without leading and trailing punctuation, symbols and emoji,
+// so that "Thanks!" and "thanks 🙏" match the phrase "thanks".
+func phraseKey(text string) string {
+	return strings.TrimFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
 // generateMessageHash creates a hash for duplicate detection.
 func (p *Plugin) generateMessageHash(text string) (string, error) {
 	// Generate hash based on text content
@@ -149,4 +343,6 @@ func (p *Plugin) generateMessageHash(text string) (string, error) {
 // Should be called periodically to clean up expired entries.
 func (p *Plugin) Cleanup(_ context.Context) {
 	p.storage.Cleanup()
+	p.waves.Cleanup()
+	p.clusters.Cleanup()
 }
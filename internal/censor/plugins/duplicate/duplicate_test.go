@@ -6,8 +6,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/duplicate"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
 	"github.com/stretchr/testify/require"
 )
 
@@ -194,55 +196,100 @@ func TestPlugin_Evaluate(t *testing.T) {
 
 func TestStorage_RecordDuplicate(t *testing.T) {
 	storage := duplicate.NewStorage(5 * time.Minute)
+	ctx := context.Background()
 
 	// First occurrence - should return 1 (not exceeded)
-	stat := storage.Record(12345, "hash1")
+	stat, err := storage.Record(ctx, "12345", "hash1")
+	require.NoError(t, err)
 	require.Equal(t, 1, stat.Count)
 
 	// Second occurrence - should return 2 (not exceeded)
-	stat = storage.Record(12345, "hash1")
+	stat, err = storage.Record(ctx, "12345", "hash1")
+	require.NoError(t, err)
 	require.Equal(t, 2, stat.Count)
 
 	// Third occurrence - should return 3 (exceeded)
-	stat = storage.Record(12345, "hash1")
+	stat, err = storage.Record(ctx, "12345", "hash1")
+	require.NoError(t, err)
 	require.Equal(t, 3, stat.Count)
 
 	// Different chat ID - should return 1 (separate tracking)
-	stat = storage.Record(99999, "hash1")
+	stat, err = storage.Record(ctx, "99999", "hash1")
+	require.NoError(t, err)
 	require.Equal(t, 1, stat.Count)
 
 	// Different hash - should return 1
-	stat = storage.Record(12345, "hash2")
+	stat, err = storage.Record(ctx, "12345", "hash2")
+	require.NoError(t, err)
 	require.Equal(t, 1, stat.Count)
 }
 
 func TestStorage_WindowExpiration(t *testing.T) {
 	storage := duplicate.NewStorage(1 * time.Second)
+	ctx := context.Background()
 
 	// Record a duplicate
-	stat := storage.Record(12345, "hash1")
+	stat, err := storage.Record(ctx, "12345", "hash1")
+	require.NoError(t, err)
 	require.Equal(t, 1, stat.Count)
 
 	// Second occurrence within window
-	stat = storage.Record(12345, "hash1")
+	stat, err = storage.Record(ctx, "12345", "hash1")
+	require.NoError(t, err)
 	require.Equal(t, 2, stat.Count)
 
 	// Wait for window to expire
 	time.Sleep(2 * time.Second)
 
 	// Should reset count after window expiration
-	stat = storage.Record(12345, "hash1")
+	stat, err = storage.Record(ctx, "12345", "hash1")
+	require.NoError(t, err)
 	require.Equal(t, 1, stat.Count)
 
 	// Should still allow another within the new window
-	stat = storage.Record(12345, "hash1")
+	stat, err = storage.Record(ctx, "12345", "hash1")
+	require.NoError(t, err)
 	require.Equal(t, 2, stat.Count)
 
 	// Third occurrence should now exceed
-	stat = storage.Record(12345, "hash1")
+	stat, err = storage.Record(ctx, "12345", "hash1")
+	require.NoError(t, err)
 	require.Equal(t, 3, stat.Count)
 }
 
+func TestPlugin_Shared(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	s, err := storage.New(storage.Config{URL: "redis://" + mr.Addr() + "?ttl=1h"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	config := duplicate.Config{
+		MaxDuplicates: 1,
+		Window:        time.Minute,
+	}
+
+	// Two replicas share occurrences of the same message
+	replicas := []plugin.Plugin{
+		duplicate.NewShared(config, storage.NewKV(s)),
+		duplicate.NewShared(config, storage.NewKV(s)),
+	}
+	msg := plugin.Message{Text: "Buy cheap followers", ChatID: 12345}
+
+	expected := []plugin.Action{plugin.ActionSkip, plugin.ActionSkip, plugin.ActionBlock}
+	for i, action := range expected {
+		result, evalErr := replicas[i%len(replicas)].Evaluate(context.Background(), msg)
+		require.NoError(t, evalErr)
+		require.Equal(t, action, result.Action)
+	}
+
+	// Occurrences expire after the window
+	mr.FastForward(2 * time.Minute)
+	result, err := replicas[0].Evaluate(context.Background(), msg)
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionSkip, result.Action)
+}
+
 func TestPlugin_UnicodeHandling(t *testing.T) {
 	config := duplicate.Config{
 		MaxDuplicates: 1,
@@ -323,6 +370,36 @@ func TestPlugin_TextExtraction(t *testing.T) {
 	}
 }
 
+func TestPlugin_MediaWithoutText(t *testing.T) {
+	p := duplicate.New(duplicate.Config{
+		MaxDuplicates: 1,
+		Window:        5 * time.Minute,
+	})
+
+	photo := func(fileUniqueID string) plugin.Message {
+		return plugin.Message{
+			ChatID: 12345,
+			Media: &plugin.Media{
+				Kind:         plugin.MediaKindPhoto,
+				FileID:       "file-" + fileUniqueID,
+				FileUniqueID: fileUniqueID,
+			},
+		}
+	}
+
+	for _, expected := range []plugin.Action{plugin.ActionSkip, plugin.ActionSkip, plugin.ActionBlock} {
+		result, err := p.Evaluate(context.Background(), photo("AQADxyz"))
+		require.NoError(t, err)
+		require.Equal(t, expected, result.Action)
+	}
+
+	// Other files are counted separately
+	result, err := p.Evaluate(context.Background(), photo("AQADabc"))
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionSkip, result.Action)
+	require.Equal(t, "duplicate limit not exceeded", result.Reason)
+}
+
 func TestPlugin_HashGeneration(t *testing.T) {
 	config := duplicate.Config{
 		MaxDuplicates: 3,
@@ -513,6 +590,6 @@ func BenchmarkStorage_RecordDuplicate(b *testing.B) {
 
 	b.ResetTimer()
 	for i := range b.N {
-		storage.Record(12345, fmt.Sprintf("hash-%d", i))
+		_, _ = storage.Record(context.Background(), "12345", fmt.Sprintf("hash-%d", i))
 	}
 }
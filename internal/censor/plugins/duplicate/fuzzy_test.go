@@ -0,0 +1,137 @@
+package duplicate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/duplicate"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// spamVariants are near-duplicates differing in an emoji, a digit or a letter.
+var spamVariants = []string{
+	"🔥 Earn $500 a day working from home! Write to @richbro for details 💰",
+	"⚡ Earn $500 a day working from home! Write to @richbro for details 💰",
+	"🔥 Earn $600 a day working from home! Write to @richbro for details 💰",
+	"🔥 Earn $500 a day working from home! Write to @richbro for details 💰🚀",
+}
+
+func fuzzyConfig() duplicate.Config {
+	return duplicate.Config{
+		MaxDuplicates: 2,
+		Window:        time.Minute,
+		Mode:          duplicate.ModeFuzzy,
+		MaxDistance:   duplicate.DefaultMaxDistance,
+	}
+}
+
+func TestPlugin_Fuzzy(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	s, err := storage.New(storage.Config{URL: "redis://" + mr.Addr() + "?ttl=1h"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	for name, p := range map[string]plugin.Plugin{
+		"memory": duplicate.New(fuzzyConfig()),
+		"shared": duplicate.NewShared(fuzzyConfig(), storage.NewKV(s)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			evaluate := func(text string) plugin.Result {
+				result, evalErr := p.Evaluate(context.Background(), plugin.Message{Text: text, ChatID: 12345})
+				require.NoError(t, evalErr)
+				return result
+			}
+
+			// Unrelated messages are not near-duplicates
+			require.Equal(t, plugin.ActionSkip, evaluate("Does anyone know a good dentist near the station?").Action)
+			require.Equal(t, plugin.ActionSkip, evaluate("Selling iPhone 13, good condition, 400 usd, DM me").Action)
+
+			expected := []plugin.Action{plugin.ActionSkip, plugin.ActionSkip, plugin.ActionSkip, plugin.ActionBlock}
+			var result plugin.Result
+			for i, text := range spamVariants {
+				result = evaluate(text)
+				require.Equal(t, expected[i], result.Action, text)
+			}
+			require.Equal(t, 4, result.Metadata["count"])
+			require.Positive(t, result.Metadata["distance"])
+		})
+	}
+}
+
+func TestPlugin_ExactIgnoresVariants(t *testing.T) {
+	config := fuzzyConfig()
+	config.Mode = duplicate.ModeExact
+	p := duplicate.New(config)
+
+	for _, text := range spamVariants {
+		result, err := p.Evaluate(context.Background(), plugin.Message{Text: text, ChatID: 12345})
+		require.NoError(t, err)
+		require.Equal(t, plugin.ActionSkip, result.Action)
+	}
+}
+
+func TestStorage_RecordSimilar(t *testing.T) {
+	s := duplicate.NewStorage(100 * time.Millisecond)
+	ctx := context.Background()
+
+	const fingerprint = uint64(0xF0F0_F0F0_0F0F_0F0F)
+
+	stat, err := s.RecordSimilar(ctx, "1", fingerprint, 3)
+	require.NoError(t, err)
+	require.Equal(t, 1, stat.Count)
+
+	// 3 bits differ
+	stat, err = s.RecordSimilar(ctx, "1", fingerprint^0b1011, 3)
+	require.NoError(t, err)
+	require.Equal(t, 2, stat.Count)
+	require.Equal(t, 3, stat.Distance)
+
+	// 4 bits differ, a new cluster
+	stat, err = s.RecordSimilar(ctx, "1", fingerprint^0b1111, 3)
+	require.NoError(t, err)
+	require.Equal(t, 1, stat.Count)
+
+	// Chats are separate
+	stat, err = s.RecordSimilar(ctx, "2", fingerprint, 3)
+	require.NoError(t, err)
+	require.Equal(t, 1, stat.Count)
+
+	time.Sleep(150 * time.Millisecond)
+	s.Cleanup()
+
+	stat, err = s.RecordSimilar(ctx, "1", fingerprint, 3)
+	require.NoError(t, err)
+	require.Equal(t, 1, stat.Count)
+}
+
+func BenchmarkStorage_RecordSimilar(b *testing.B) {
+	for _, size := range []int{1_000, 100_000} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			s := duplicate.NewStorage(time.Hour)
+			ctx := context.Background()
+
+			// Pseudo-random fingerprints of unrelated messages
+			x := uint64(0x9E3779B97F4A7C15)
+			next := func() uint64 {
+				x ^= x << 13
+				x ^= x >> 7
+				x ^= x << 17
+				return x
+			}
+			for range size {
+				_, _ = s.RecordSimilar(ctx, "1", next(), duplicate.DefaultMaxDistance)
+			}
+
+			b.ResetTimer()
+			for range b.N {
+				_, _ = s.RecordSimilar(ctx, "1", next(), duplicate.DefaultMaxDistance)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package duplicate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/duplicate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScope_Key(t *testing.T) {
+	tests := []struct {
+		scope duplicate.Scope
+		want  string
+	}{
+		{scope: "", want: "-100"},
+		{scope: duplicate.ScopeChat, want: "-100"},
+		{scope: duplicate.ScopeUser, want: "user:42"},
+		{scope: duplicate.ScopeUserChat, want: "-100:user:42"},
+		{scope: duplicate.ScopeGlobal, want: "global"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scope), func(t *testing.T) {
+			require.Equal(t, tt.want, tt.scope.Key(-100, 42))
+		})
+	}
+}
+
+func TestPlugin_Scopes(t *testing.T) {
+	// The same text sent by users 1 and 2 to chats 10 and 20, in order
+	messages := []plugin.Message{
+		{Text: "Meet me at the station", ChatID: 10, UserID: 1},
+		{Text: "Meet me at the station", ChatID: 10, UserID: 2},
+		{Text: "Meet me at the station", ChatID: 20, UserID: 1},
+		{Text: "Meet me at the station", ChatID: 20, UserID: 1},
+	}
+
+	tests := []struct {
+		scope duplicate.Scope
+		want  []plugin.Action
+	}{
+		{
+			scope: duplicate.ScopeChat,
+			want:  []plugin.Action{plugin.ActionSkip, plugin.ActionBlock, plugin.ActionSkip, plugin.ActionBlock},
+		},
+		{
+			scope: duplicate.ScopeUser,
+			want:  []plugin.Action{plugin.ActionSkip, plugin.ActionSkip, plugin.ActionBlock, plugin.ActionBlock},
+		},
+		{
+			scope: duplicate.ScopeUserChat,
+			want:  []plugin.Action{plugin.ActionSkip, plugin.ActionSkip, plugin.ActionSkip, plugin.ActionBlock},
+		},
+		{
+			scope: duplicate.ScopeGlobal,
+			want:  []plugin.Action{plugin.ActionSkip, plugin.ActionBlock, plugin.ActionBlock, plugin.ActionBlock},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scope), func(t *testing.T) {
+			p := duplicate.New(duplicate.Config{
+				MaxDuplicates: 0,
+				Window:        time.Minute,
+				Mode:          duplicate.ModeExact,
+				Scope:         tt.scope,
+			})
+
+			for i, msg := range messages {
+				result, err := p.Evaluate(context.Background(), msg)
+				require.NoError(t, err)
+				require.Equal(t, tt.want[i], result.Action, "message %d", i)
+			}
+		})
+	}
+}
+
+func TestPlugin_Whitelist(t *testing.T) {
+	config := duplicate.DefaultConfig()
+	config.MaxDuplicates = 0
+	p := duplicate.New(config)
+
+	for _, text := range []string{"Thanks!", "thanks 🙏", "THANKS", "Спасибо!!!", "Good  morning ☀️"} {
+		for range 2 {
+			result, err := p.Evaluate(context.Background(), plugin.Message{Text: text, ChatID: 1, UserID: 1})
+			require.NoError(t, err)
+			require.Equal(t, plugin.ActionSkip, result.Action, text)
+		}
+	}
+
+	// Phrases are matched as whole messages
+	for _, want := range []plugin.Action{plugin.ActionSkip, plugin.ActionBlock} {
+		result, err := p.Evaluate(context.Background(), plugin.Message{Text: "thanks, send me the link", ChatID: 1})
+		require.NoError(t, err)
+		require.Equal(t, want, result.Action)
+	}
+}
@@ -0,0 +1,76 @@
+package duplicate
+
+import (
+	"hash/fnv"
+	"math/bits"
+)
+
+const (
+	// shingleSize is the number of characters of the features of a fingerprint.
+	shingleSize = 3
+	// fingerprintBits is the size of a fingerprint.
+	fingerprintBits = 64
+)
+
+// simhash returns the SimHash fingerprint of the text over its character shingles.
+// Texts differing in a few characters have fingerprints differing in a few bits.
+func simhash(text string) uint64 {
+	runes := []rune(text)
+
+	var weights [fingerprintBits]int
+	add := func(feature string) {
+		hasher := fnv.New64a()
+		_, _ = hasher.Write([]byte(feature))
+		h := hasher.Sum64()
+
+		for bit := range fingerprintBits {
+			if h>>bit&1 == 1 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	if len(runes) < shingleSize {
+		add(text)
+	}
+	for i := 0; i+shingleSize <= len(runes); i++ {
+		add(string(runes[i : i+shingleSize]))
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+
+	return fingerprint
+}
+
+// hammingDistance returns the number of bits differing between the fingerprints.
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// bands splits the fingerprint into maxDistance+1 bands. By the pigeonhole principle fingerprints
+// within maxDistance bits of each other have at least one equal band, so only fingerprints sharing
+// a band have to be compared.
+func bands(fingerprint uint64, maxDistance int) []uint64 {
+	count := maxDistance + 1
+	result := make([]uint64, count)
+
+	start := 0
+	for i := range count {
+		width := fingerprintBits / count
+		if i < fingerprintBits%count {
+			width++
+		}
+
+		result[i] = (fingerprint >> start) & (1<<width - 1)
+		start += width
+	}
+
+	return result
+}
@@ -1,23 +1,61 @@
 package duplicate
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/storage"
 )
 
+// fingerprintBytes is the size of a fingerprint stored in the shared store.
+const fingerprintBytes = 8
+
 // Storage implements thread-safe duplicate detection storage.
 type Storage struct {
 	window  time.Duration
-	entries map[string]*Entry // Key format: "chatID:messageHash"
-	mu      sync.Mutex
+	entries map[string]*Entry // Key format: "scope:messageHash"
+
+	// Near-duplicates are grouped in clusters around the fingerprint of their first message,
+	// clusters are indexed by the bands of the fingerprint so that only candidates sharing a band are compared
+	clusters      map[uint64]*cluster
+	bandIndex     map[bandKey][]indexEntry
+	lastClusterID uint64
+
+	mu sync.Mutex
 }
 
 // Entry represents a duplicate tracking entry.
 type Entry struct {
-	Count     int       // Number of duplicate messages seen
-	FirstSeen time.Time // Timestamp of first occurrence
-	LastSeen  time.Time // Timestamp of most recent occurrence
+	Count       int       // Number of duplicate messages seen
+	FirstSeen   time.Time // Timestamp of first occurrence
+	LastSeen    time.Time // Timestamp of most recent occurrence
+	Distance    int       // Fingerprint bits differing from the first occurrence, fuzzy mode only
+	Fingerprint uint64    // Fingerprint of the first occurrence, fuzzy mode only
+}
+
+type cluster struct {
+	fingerprint uint64
+	bandKeys    []bandKey
+	entry       Entry
+}
+
+// indexEntry keeps the fingerprint next to the cluster ID, so that candidates are compared without lookups.
+type indexEntry struct {
+	id          uint64
+	fingerprint uint64
+}
+
+type bandKey struct {
+	scope string
+	bands int
+	band  int
+	value uint64
 }
 
 // NewStorage creates a new Storage instance.
@@ -25,22 +63,27 @@ func NewStorage(window time.Duration) *Storage {
 	return &Storage{
 		window:  window,
 		entries: make(map[string]*Entry),
-		mu:      sync.Mutex{},
+
+		clusters:      make(map[uint64]*cluster),
+		bandIndex:     make(map[bandKey][]indexEntry),
+		lastClusterID: 0,
+
+		mu: sync.Mutex{},
 	}
 }
 
-// generateKey creates a unique key from chatID and messageHash.
-func generateKey(chatID int64, messageHash string) string {
-	return strconv.FormatInt(chatID, 10) + ":" + messageHash
+// generateKey creates a unique key from scope and messageHash.
+func generateKey(scope, messageHash string) string {
+	return scope + ":" + messageHash
 }
 
 // Record records a duplicate message and returns the current entry state.
-func (s *Storage) Record(chatID int64, messageHash string) Entry {
+func (s *Storage) Record(_ context.Context, scope, messageHash string) (Entry, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now()
-	key := generateKey(chatID, messageHash)
+	key := generateKey(scope, messageHash)
 	entry, exists := s.entries[key]
 
 	if !exists {
@@ -51,7 +94,7 @@ func (s *Storage) Record(chatID int64, messageHash string) Entry {
 			LastSeen:  now,
 		}
 		s.entries[key] = entry
-		return *entry
+		return *entry, nil
 	}
 
 	// Check if the entry has expired
@@ -60,7 +103,7 @@ func (s *Storage) Record(chatID int64, messageHash string) Entry {
 		entry.Count = 1
 		entry.FirstSeen = now
 		entry.LastSeen = now
-		return *entry
+		return *entry, nil
 	}
 
 	// Increment existing entry
@@ -68,7 +111,57 @@ func (s *Storage) Record(chatID int64, messageHash string) Entry {
 	entry.LastSeen = now
 
 	// Return current entry for limit check by caller
-	return *entry
+	return *entry, nil
+}
+
+// RecordSimilar records a message by its fingerprint and returns the state of the closest cluster
+// within maxDistance bits, a new cluster is created if there is none.
+func (s *Storage) RecordSimilar(_ context.Context, scope string, fingerprint uint64, maxDistance int) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	keys := make([]bandKey, 0, maxDistance+1)
+	for i, value := range bands(fingerprint, maxDistance) {
+		keys = append(keys, bandKey{scope: scope, bands: maxDistance + 1, band: i, value: value})
+	}
+
+	var closest *cluster
+	distance := maxDistance + 1
+	for _, key := range keys {
+		for _, candidate := range s.bandIndex[key] {
+			d := hammingDistance(candidate.fingerprint, fingerprint)
+			if d >= distance {
+				continue
+			}
+
+			if c := s.clusters[candidate.id]; now.Sub(c.entry.FirstSeen) <= s.window {
+				closest, distance = c, d
+			}
+		}
+	}
+
+	if closest == nil {
+		s.lastClusterID++
+		s.clusters[s.lastClusterID] = &cluster{
+			fingerprint: fingerprint,
+			bandKeys:    keys,
+			entry:       Entry{Count: 1, FirstSeen: now, LastSeen: now, Distance: 0, Fingerprint: fingerprint},
+		}
+		for _, key := range keys {
+			s.bandIndex[key] = append(s.bandIndex[key], indexEntry{id: s.lastClusterID, fingerprint: fingerprint})
+		}
+
+		return s.clusters[s.lastClusterID].entry, nil
+	}
+
+	closest.entry.Count++
+	closest.entry.LastSeen = now
+
+	entry := closest.entry
+	entry.Distance = distance
+
+	return entry, nil
 }
 
 // Cleanup removes entries that are older than the specified window.
@@ -82,4 +175,115 @@ func (s *Storage) Cleanup() {
 			delete(s.entries, key)
 		}
 	}
+
+	for id, c := range s.clusters {
+		if now.Sub(c.entry.FirstSeen) <= s.window {
+			continue
+		}
+
+		delete(s.clusters, id)
+		for _, key := range c.bandKeys {
+			entries := slices.DeleteFunc(s.bandIndex[key], func(e indexEntry) bool { return e.id == id })
+			if len(entries) == 0 {
+				delete(s.bandIndex, key)
+			} else {
+				s.bandIndex[key] = entries
+			}
+		}
+	}
+}
+
+// SharedStorage implements duplicate detection storage shared between replicas.
+type SharedStorage struct {
+	window time.Duration
+	kv     storage.KV
+}
+
+// NewSharedStorage creates a new SharedStorage instance.
+func NewSharedStorage(window time.Duration, kv storage.KV) *SharedStorage {
+	return &SharedStorage{
+		window: window,
+		kv:     kv,
+	}
+}
+
+// Record records a duplicate message and returns the current entry state.
+// FirstSeen is not tracked by the shared store and is left zero.
+func (s *SharedStorage) Record(ctx context.Context, scope, messageHash string) (Entry, error) {
+	// Instances with different windows must not share counters
+	key := "duplicate:" + s.window.String() + ":" + generateKey(scope, messageHash)
+
+	count, err := s.kv.Increment(ctx, key, s.window)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to record message: %w", err)
+	}
+
+	return Entry{
+		Count:       count,
+		FirstSeen:   time.Time{},
+		LastSeen:    time.Now(),
+		Distance:    0,
+		Fingerprint: 0,
+	}, nil
+}
+
+// RecordSimilar records a message by its fingerprint and returns the state of the closest cluster
+// within maxDistance bits. Each band of a scope keeps the fingerprint of the first message of a cluster
+// having it, messages are counted by the cluster fingerprint.
+func (s *SharedStorage) RecordSimilar(
+	ctx context.Context,
+	scope string,
+	fingerprint uint64,
+	maxDistance int,
+) (Entry, error) {
+	prefix := "duplicate:fuzzy:" + s.window.String() + ":" + scope + ":"
+
+	found := false
+	closest, distance := fingerprint, 0
+	free := []string{}
+	for i, value := range bands(fingerprint, maxDistance) {
+		key := prefix + strconv.Itoa(maxDistance+1) + ":" + strconv.Itoa(i) + ":" + strconv.FormatUint(value, 16)
+
+		data, err := s.kv.Load(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			free = append(free, key)
+			continue
+		}
+		if err != nil {
+			return Entry{}, fmt.Errorf("failed to load band: %w", err)
+		}
+		if len(data) != fingerprintBytes {
+			continue
+		}
+
+		candidate := binary.BigEndian.Uint64(data)
+		if d := hammingDistance(candidate, fingerprint); d <= maxDistance && (!found || d < distance) {
+			found, closest, distance = true, candidate, d
+		}
+	}
+
+	if !found {
+		data := binary.BigEndian.AppendUint64(nil, fingerprint)
+		for _, key := range free {
+			if err := s.kv.Store(ctx, key, data, s.window); err != nil {
+				return Entry{}, fmt.Errorf("failed to store band: %w", err)
+			}
+		}
+	}
+
+	count, err := s.kv.Increment(ctx, prefix+strconv.FormatUint(closest, 16), s.window)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to record message: %w", err)
+	}
+
+	return Entry{
+		Count:       count,
+		FirstSeen:   time.Time{},
+		LastSeen:    time.Now(),
+		Distance:    distance,
+		Fingerprint: closest,
+	}, nil
 }
+
+// Cleanup is a no-op, entries are expired by the shared store.
+func (s *SharedStorage) Cleanup() {}
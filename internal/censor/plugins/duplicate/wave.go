@@ -0,0 +1,280 @@
+package duplicate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+)
+
+// maxWaveMessages is the maximum number of messages of a wave remembered to be blocked retroactively.
+const maxWaveMessages = 100
+
+// Wave is the state of the same content spreading across chats and users.
+type Wave struct {
+	Detected bool                // The content reached a threshold within the window
+	New      bool                // The wave was detected by this message, false for later messages of the wave
+	Messages []plugin.MessageRef // Earlier messages of the wave, oldest first, set only for a new wave
+	Chats    int                 // Number of chats the content was sent to, set only for a new wave
+	Users    int                 // Number of users who sent the content, set only for a new wave
+}
+
+// WaveTracker tracks the same content sent to multiple chats or by multiple users.
+type WaveTracker interface {
+	// Track records the message with the content identified by key and returns the state of its wave.
+	Track(ctx context.Context, key string, msg plugin.MessageRef) (Wave, error)
+	Cleanup()
+}
+
+// WaveStorage implements thread-safe wave tracking in memory.
+type WaveStorage struct {
+	window time.Duration
+	chats  int
+	users  int
+
+	waves map[string]*waveState
+	mu    sync.Mutex
+}
+
+type waveState struct {
+	firstSeen time.Time
+	messages  []plugin.MessageRef
+	chats     map[int64]struct{}
+	users     map[int64]struct{}
+	detected  bool
+}
+
+// NewWaveStorage creates a new WaveStorage instance detecting a wave once the content is sent
+// to the number of chats or by the number of users within the window, zero disables the threshold.
+func NewWaveStorage(window time.Duration, chats, users int) *WaveStorage {
+	return &WaveStorage{
+		window: window,
+		chats:  chats,
+		users:  users,
+
+		waves: make(map[string]*waveState),
+		mu:    sync.Mutex{},
+	}
+}
+
+// Track records the message and returns the state of its wave.
+func (s *WaveStorage) Track(_ context.Context, key string, msg plugin.MessageRef) (Wave, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	state, exists := s.waves[key]
+	if !exists || now.Sub(state.firstSeen) > s.window {
+		state = &waveState{
+			firstSeen: now,
+			messages:  []plugin.MessageRef{},
+			chats:     make(map[int64]struct{}),
+			users:     make(map[int64]struct{}),
+			detected:  false,
+		}
+		s.waves[key] = state
+	}
+
+	earlier := slices.Clone(state.messages)
+	if len(state.messages) < maxWaveMessages {
+		state.messages = append(state.messages, msg)
+	}
+	state.chats[msg.ChatID] = struct{}{}
+	state.users[msg.UserID] = struct{}{}
+
+	if state.detected {
+		return Wave{Detected: true, New: false, Messages: nil, Chats: 0, Users: 0}, nil
+	}
+
+	if !reached(s.chats, len(state.chats)) && !reached(s.users, len(state.users)) {
+		return Wave{Detected: false, New: false, Messages: nil, Chats: 0, Users: 0}, nil
+	}
+
+	state.detected = true
+
+	return Wave{
+		Detected: true,
+		New:      true,
+		Messages: earlier,
+		Chats:    len(state.chats),
+		Users:    len(state.users),
+	}, nil
+}
+
+// Cleanup removes waves that are older than the window.
+func (s *WaveStorage) Cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, state := range s.waves {
+		if now.Sub(state.firstSeen) > s.window {
+			delete(s.waves, key)
+		}
+	}
+}
+
+// SharedWaveStorage implements wave tracking shared between replicas.
+type SharedWaveStorage struct {
+	window time.Duration
+	chats  int
+	users  int
+
+	kv storage.KV
+}
+
+// NewSharedWaveStorage creates a new SharedWaveStorage instance, see NewWaveStorage.
+func NewSharedWaveStorage(window time.Duration, chats, users int, kv storage.KV) *SharedWaveStorage {
+	return &SharedWaveStorage{
+		window: window,
+		chats:  chats,
+		users:  users,
+
+		kv: kv,
+	}
+}
+
+// Track records the message and returns the state of its wave. Chats and users are counted
+// when they are first seen, as counters of the shared store can not be read without incrementing.
+func (s *SharedWaveStorage) Track(ctx context.Context, key string, msg plugin.MessageRef) (Wave, error) {
+	prefix := "duplicate:wave:" + s.window.String() + ":" + key + ":"
+
+	index, err := s.kv.Increment(ctx, prefix+"messages", s.window)
+	if err != nil {
+		return Wave{}, fmt.Errorf("failed to record message: %w", err)
+	}
+	if index <= maxWaveMessages {
+		key := prefix + "message:" + strconv.Itoa(index)
+		if storeErr := s.kv.Store(ctx, key, encodeRef(msg), s.window); storeErr != nil {
+			return Wave{}, fmt.Errorf("failed to store message: %w", storeErr)
+		}
+	}
+
+	chats, err := s.countDistinct(ctx, prefix+"chat", msg.ChatID)
+	if err != nil {
+		return Wave{}, err
+	}
+
+	users, err := s.countDistinct(ctx, prefix+"user", msg.UserID)
+	if err != nil {
+		return Wave{}, err
+	}
+
+	if !reached(s.chats, chats) && !reached(s.users, users) {
+		if _, loadErr := s.kv.Load(ctx, prefix+"detected"); loadErr == nil {
+			return Wave{Detected: true, New: false, Messages: nil, Chats: 0, Users: 0}, nil
+		} else if !errors.Is(loadErr, storage.ErrNotFound) {
+			return Wave{}, fmt.Errorf("failed to load wave: %w", loadErr)
+		}
+
+		return Wave{Detected: false, New: false, Messages: nil, Chats: 0, Users: 0}, nil
+	}
+
+	// Both thresholds may be reached by different messages, the wave is reported once
+	detections, err := s.kv.Increment(ctx, prefix+"detections", s.window)
+	if err != nil {
+		return Wave{}, fmt.Errorf("failed to record wave: %w", err)
+	}
+	if detections > 1 {
+		return Wave{Detected: true, New: false, Messages: nil, Chats: 0, Users: 0}, nil
+	}
+
+	if storeErr := s.kv.Store(ctx, prefix+"detected", []byte{1}, s.window); storeErr != nil {
+		return Wave{}, fmt.Errorf("failed to store wave: %w", storeErr)
+	}
+
+	return s.load(ctx, prefix, min(index-1, maxWaveMessages), msg)
+}
+
+// countDistinct records the value of the set and returns the size of the set if the value is new, zero otherwise.
+func (s *SharedWaveStorage) countDistinct(ctx context.Context, set string, value int64) (int, error) {
+	seen, err := s.kv.Increment(ctx, set+":"+strconv.FormatInt(value, 10), s.window)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record %s: %w", set, err)
+	}
+	if seen > 1 {
+		return 0, nil
+	}
+
+	size, err := s.kv.Increment(ctx, set+"s", s.window)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", set, err)
+	}
+
+	return size, nil
+}
+
+// load returns the new wave with the earlier messages, messages which expired or could not be decoded are skipped.
+func (s *SharedWaveStorage) load(ctx context.Context, prefix string, count int, msg plugin.MessageRef) (Wave, error) {
+	messages := make([]plugin.MessageRef, 0, count)
+	chats := map[int64]struct{}{msg.ChatID: {}}
+	users := map[int64]struct{}{msg.UserID: {}}
+
+	for i := 1; i <= count; i++ {
+		data, err := s.kv.Load(ctx, prefix+"message:"+strconv.Itoa(i))
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return Wave{}, fmt.Errorf("failed to load message: %w", err)
+		}
+
+		ref, ok := decodeRef(data)
+		if !ok {
+			continue
+		}
+
+		messages = append(messages, ref)
+		chats[ref.ChatID] = struct{}{}
+		users[ref.UserID] = struct{}{}
+	}
+
+	return Wave{
+		Detected: true,
+		New:      true,
+		Messages: messages,
+		Chats:    len(chats),
+		Users:    len(users),
+	}, nil
+}
+
+// Cleanup is a no-op, entries are expired by the shared store.
+func (s *SharedWaveStorage) Cleanup() {}
+
+// reached reports whether the count reached the threshold, a zero threshold is never reached.
+func reached(threshold, count int) bool {
+	return threshold > 0 && count >= threshold
+}
+
+func encodeRef(ref plugin.MessageRef) []byte {
+	return []byte(
+		strconv.FormatInt(ref.ChatID, 10) + ":" +
+			strconv.FormatInt(ref.UserID, 10) + ":" +
+			strconv.Itoa(ref.MessageID),
+	)
+}
+
+func decodeRef(data []byte) (plugin.MessageRef, bool) {
+	const fields = 3
+
+	parts := strings.Split(string(data), ":")
+	if len(parts) != fields {
+		return plugin.MessageRef{}, false
+	}
+
+	chatID, chatErr := strconv.ParseInt(parts[0], 10, 64)
+	userID, userErr := strconv.ParseInt(parts[1], 10, 64)
+	messageID, messageErr := strconv.Atoi(parts[2])
+	if chatErr != nil || userErr != nil || messageErr != nil {
+		return plugin.MessageRef{}, false
+	}
+
+	return plugin.MessageRef{ChatID: chatID, UserID: userID, MessageID: messageID}, true
+}
@@ -0,0 +1,164 @@
+package duplicate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/duplicate"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func waveConfig(mode duplicate.Mode) duplicate.Config {
+	return duplicate.Config{
+		MaxDuplicates: 5,
+		Window:        time.Minute,
+		Mode:          mode,
+		MaxDistance:   duplicate.DefaultMaxDistance,
+		CrossChat:     true,
+		WaveChats:     3,
+		WaveUsers:     0,
+		WaveWindow:    time.Minute,
+	}
+}
+
+func TestPlugin_CrossChat(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	s, err := storage.New(storage.Config{URL: "redis://" + mr.Addr() + "?ttl=1h"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	tests := []struct {
+		name   string
+		plugin plugin.Plugin
+		fuzzy  bool
+	}{
+		{name: "memory", plugin: duplicate.New(waveConfig(duplicate.ModeExact)), fuzzy: false},
+		{name: "shared", plugin: duplicate.NewShared(waveConfig(duplicate.ModeExact), storage.NewKV(s)), fuzzy: false},
+		{name: "memory fuzzy", plugin: duplicate.New(waveConfig(duplicate.ModeFuzzy)), fuzzy: true},
+		{name: "shared fuzzy", plugin: duplicate.NewShared(waveConfig(duplicate.ModeFuzzy), storage.NewKV(s)), fuzzy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.plugin
+			evaluate := func(chatID, userID int64, messageID int, text string) plugin.Result {
+				result, evalErr := p.Evaluate(context.Background(), plugin.Message{
+					Text:      text,
+					ChatID:    chatID,
+					UserID:    userID,
+					MessageID: messageID,
+				})
+				require.NoError(t, evalErr)
+				return result
+			}
+			text := func(i int) string {
+				if tt.fuzzy {
+					return spamVariants[i%len(spamVariants)]
+				}
+				return spamVariants[0]
+			}
+			chat := func(i int64) int64 { return -1000 - i }
+
+			// The same text in a single chat is not a wave
+			require.Equal(t, plugin.ActionSkip, evaluate(chat(1), 1, 10, text(0)).Action)
+			require.Equal(t, plugin.ActionSkip, evaluate(chat(1), 2, 11, text(1)).Action)
+			require.Equal(t, plugin.ActionSkip, evaluate(chat(2), 1, 20, text(2)).Action)
+
+			// The third chat detects the wave and reports the earlier messages
+			result := evaluate(chat(3), 3, 30, text(3))
+			require.Equal(t, plugin.ActionBlock, result.Action)
+			require.Equal(t, 3, result.Metadata["wave_chats"])
+			require.Equal(t, 3, result.Metadata["wave_users"])
+			require.Equal(t, plugin.SeverityHigh, result.Severity())
+			require.ElementsMatch(t, []plugin.MessageRef{
+				{ChatID: chat(1), UserID: 1, MessageID: 10},
+				{ChatID: chat(1), UserID: 2, MessageID: 11},
+				{ChatID: chat(2), UserID: 1, MessageID: 20},
+			}, result.Related())
+
+			// Later messages of the wave are deleted silently
+			result = evaluate(chat(4), 4, 40, text(0))
+			require.Equal(t, plugin.ActionBlock, result.Action)
+			require.Equal(t, plugin.RecommendationDeleteSilently, result.Recommendation())
+			require.Empty(t, result.Related())
+
+			// Other texts are not affected
+			result = evaluate(chat(4), 4, 41, "Does anyone know a good dentist near the station?")
+			require.Equal(t, plugin.ActionSkip, result.Action)
+		})
+	}
+}
+
+func TestPlugin_CrossChatIgnoresShortTexts(t *testing.T) {
+	config := waveConfig(duplicate.ModeExact)
+	config.WaveChats = 2
+	p := duplicate.New(config)
+
+	for chatID := range int64(5) {
+		result, err := p.Evaluate(context.Background(), plugin.Message{Text: "good point", ChatID: chatID, UserID: 1})
+		require.NoError(t, err)
+		require.Equal(t, plugin.ActionSkip, result.Action)
+	}
+}
+
+func TestWaveStorage_Track(t *testing.T) {
+	s := duplicate.NewWaveStorage(100*time.Millisecond, 0, 2)
+	ctx := context.Background()
+
+	wave, err := s.Track(ctx, "key", plugin.MessageRef{ChatID: 1, UserID: 1, MessageID: 1})
+	require.NoError(t, err)
+	require.False(t, wave.Detected)
+
+	// The same user is not counted twice
+	wave, err = s.Track(ctx, "key", plugin.MessageRef{ChatID: 2, UserID: 1, MessageID: 2})
+	require.NoError(t, err)
+	require.False(t, wave.Detected)
+
+	wave, err = s.Track(ctx, "key", plugin.MessageRef{ChatID: 2, UserID: 2, MessageID: 3})
+	require.NoError(t, err)
+	require.True(t, wave.New)
+	require.Equal(t, 2, wave.Chats)
+	require.Equal(t, 2, wave.Users)
+	require.Len(t, wave.Messages, 2)
+
+	// The wave ends with the window
+	time.Sleep(150 * time.Millisecond)
+	s.Cleanup()
+
+	wave, err = s.Track(ctx, "key", plugin.MessageRef{ChatID: 3, UserID: 3, MessageID: 4})
+	require.NoError(t, err)
+	require.False(t, wave.Detected)
+}
+
+func TestPlugin_CrossChatSourceIsolation(t *testing.T) {
+	p := duplicate.New(waveConfig(duplicate.ModeExact))
+	evaluate := func(chatID int64, messageID int, source string) plugin.Result {
+		result, err := p.Evaluate(context.Background(), plugin.Message{
+			Text:      spamVariants[0],
+			ChatID:    chatID,
+			UserID:    chatID,
+			MessageID: messageID,
+			Source:    source,
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	// Messages of the moderation API are not part of the waves of Telegram messages,
+	// so their references are never reported as related Telegram messages
+	require.Equal(t, plugin.ActionSkip, evaluate(-1001, 10, plugin.SourceAPI).Action)
+	require.Equal(t, plugin.ActionSkip, evaluate(-1002, 20, plugin.SourceAPI).Action)
+	require.Equal(t, plugin.ActionSkip, evaluate(-1003, 30, "").Action)
+
+	result := evaluate(-1003, 31, plugin.SourceAPI)
+	require.Equal(t, plugin.ActionBlock, result.Action)
+	require.Equal(t, []plugin.MessageRef{
+		{ChatID: -1001, UserID: -1001, MessageID: 10},
+		{ChatID: -1002, UserID: -1002, MessageID: 20},
+	}, result.Related())
+}
@@ -2,18 +2,37 @@ package keyword
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
 	"github.com/samber/lo"
+	"go.uber.org/zap"
 )
 
 const (
 	pluginName = "keyword"
+
+	// addedKey is the state key of the keywords added by admins to the global instance of the plugin,
+	// instances created for chat overrides keep theirs under the key suffixed with the chat ID.
+	addedKey = "keyword:added"
+	// addedRefreshInterval is how often the added keywords are reloaded to pick up the ones added by other replicas.
+	addedRefreshInterval = time.Minute
+	// addedRetryInterval is how long the cached added keywords are used after a failed reload.
+	addedRetryInterval = 10 * time.Second
 )
 
-func Metadata() plugin.Metadata {
+// Metadata returns the plugin metadata, keywords added at runtime are kept in the state,
+// so they survive restarts and configuration reloads.
+func Metadata(state storage.State, logger *zap.Logger) plugin.Metadata {
 	return plugin.Metadata{
 		Name: pluginName,
 		Factory: func(params map[string]any) (plugin.Plugin, error) {
@@ -22,7 +41,7 @@ func Metadata() plugin.Metadata {
 				return nil, err
 			}
 
-			return New(config), nil
+			return New(config, state, logger), nil
 		},
 	}
 }
@@ -30,9 +49,18 @@ func Metadata() plugin.Metadata {
 type Plugin struct {
 	blacklist []string
 	filter    *regexp.Regexp
+
+	state     storage.State
+	key       string
+	added     []string
+	refreshAt time.Time
+
+	logger *zap.Logger
+
+	mu sync.RWMutex
 }
 
-func New(config Config) plugin.Plugin {
+func New(config Config, state storage.State, logger *zap.Logger) plugin.Plugin {
 	return &Plugin{
 		blacklist: lo.Map(
 			config.Blacklist,
@@ -41,6 +69,28 @@ func New(config Config) plugin.Plugin {
 			},
 		),
 		filter: regexp.MustCompile(`[^\p{Cyrillic}\p{Latin}][:graph:]`),
+
+		state:     state,
+		key:       addedKey,
+		added:     nil,
+		refreshAt: time.Time{},
+
+		logger: logger.Named(pluginName),
+
+		mu: sync.RWMutex{},
+	}
+}
+
+// ScopeToChat keeps the keywords added to the instance apart from those of the global instance
+// and of other chats.
+func (p *Plugin) ScopeToChat(chatID int64) {
+	key := addedKey + ":" + strconv.FormatInt(chatID, 10)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.key != key {
+		p.key, p.added, p.refreshAt = key, nil, time.Time{}
 	}
 }
 
@@ -53,13 +103,14 @@ func (p *Plugin) Priority() int {
 	return priority // Low priority = early execution
 }
 
-func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result, error) {
-	text := p.normalizeText(msg.Text)
-	if text == "" {
-		text = p.normalizeText(msg.Caption)
-	}
+func (p *Plugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Result, error) {
+	// Button labels are checked as well, spam often hides in inline keyboards
+	texts := lo.Compact(append(
+		[]string{p.normalizeText(msg.Content())},
+		lo.Map(msg.Buttons, func(b plugin.Button, _ int) string { return p.normalizeText(b.Text) })...,
+	))
 
-	if text == "" {
+	if len(texts) == 0 {
 		return plugin.Result{
 			Action:   plugin.ActionSkip,
 			Reason:   "empty message",
@@ -68,8 +119,13 @@ func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result,
 		}, nil
 	}
 
-	for _, word := range p.blacklist {
-		if strings.Contains(text, word) {
+	p.refresh(ctx)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, word := range slices.Concat(p.blacklist, p.added) {
+		if lo.ContainsBy(texts, func(text string) bool { return strings.Contains(text, word) }) {
 			return plugin.Result{
 				Action: plugin.ActionBlock,
 				Reason: "Message contains blacklisted keyword",
@@ -89,6 +145,97 @@ func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result,
 	}, nil
 }
 
+// AddKeyword adds the normalized text to the keywords stored in the state.
+// It returns false if the text is empty or already blacklisted.
+func (p *Plugin) AddKeyword(ctx context.Context, keyword string) (bool, error) {
+	keyword = strings.TrimSpace(p.normalizeText(keyword))
+	if keyword == "" || lo.Contains(p.blacklist, keyword) {
+		return false, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		added    []string
+		exists   bool
+		codecErr error
+	)
+	if err := p.state.Update(ctx, p.key, 0, func(data []byte) []byte {
+		added, exists = nil, false
+		if len(data) > 0 {
+			if codecErr = json.Unmarshal(data, &added); codecErr != nil {
+				return data
+			}
+		}
+
+		if lo.Contains(added, keyword) {
+			exists = true
+			return data
+		}
+		added = append(added, keyword)
+
+		updated, err := json.Marshal(added)
+		if err != nil {
+			codecErr = err
+			return data
+		}
+		return updated
+	}); err != nil {
+		return false, fmt.Errorf("failed to store keywords: %w", err)
+	}
+	if codecErr != nil {
+		return false, fmt.Errorf("failed to serialize keywords: %w", codecErr)
+	}
+	p.added, p.refreshAt = added, time.Now().Add(addedRefreshInterval)
+
+	return !exists, nil
+}
+
+// refresh reloads the added keywords if they were loaded too long ago.
+// If the reload fails, the cached keywords are used until the next attempt.
+func (p *Plugin) refresh(ctx context.Context) {
+	p.mu.RLock()
+	fresh := time.Now().Before(p.refreshAt)
+	p.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Now().Before(p.refreshAt) {
+		return
+	}
+
+	added, err := p.load(ctx)
+	if err != nil {
+		p.refreshAt = time.Now().Add(addedRetryInterval)
+		p.logger.Error("failed to reload added keywords", zap.String("key", p.key), zap.Error(err))
+		return
+	}
+	p.added, p.refreshAt = added, time.Now().Add(addedRefreshInterval)
+}
+
+// load returns the added keywords from the state.
+func (p *Plugin) load(ctx context.Context) ([]string, error) {
+	data, err := p.state.Load(ctx, p.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keywords: %w", err)
+	}
+
+	var added []string
+	if unmarshalErr := json.Unmarshal(data, &added); unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to decode keywords: %w", unmarshalErr)
+	}
+
+	return added, nil
+}
+
 func (p *Plugin) Cleanup(_ context.Context) {
 	// no-op
 }
@@ -2,17 +2,31 @@ package keyword_test
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
 
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/keyword"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
+func newState(t *testing.T) storage.State {
+	t.Helper()
+
+	s, err := storage.New(storage.Config{URL: "memory://storage?ttl=1h"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	return storage.NewState(s)
+}
+
 func TestPlugin_Evaluate(t *testing.T) {
 	p := keyword.New(keyword.Config{
 		Blacklist: []string{"spam", "scam"},
-	})
+	}, newState(t), zap.NewNop())
 
 	tests := []struct {
 		name     string
@@ -22,6 +36,12 @@ func TestPlugin_Evaluate(t *testing.T) {
 		{"clean text skips", plugin.Message{Text: "Hello world"}, plugin.ActionSkip},
 		{"blacklisted word in text blocks", plugin.Message{Text: "Buy now! SPAM!"}, plugin.ActionBlock},
 		{"blacklisted word in caption blocks", plugin.Message{Caption: "scam alert"}, plugin.ActionBlock},
+		{
+			"blacklisted word in button blocks",
+			plugin.Message{Text: "Hello", Buttons: []plugin.Button{{Text: "Join SCAM", URL: "https://t.me/x"}}},
+			plugin.ActionBlock,
+		},
+		{"media without text skips", plugin.Message{Media: &plugin.Media{Kind: plugin.MediaKindPhoto}}, plugin.ActionSkip},
 	}
 
 	for _, tt := range tests {
@@ -32,3 +52,130 @@ func TestPlugin_Evaluate(t *testing.T) {
 		})
 	}
 }
+
+func TestPlugin_AddKeyword(t *testing.T) {
+	state := newState(t)
+	p, ok := keyword.New(keyword.Config{Blacklist: []string{"spam"}}, state, zap.NewNop()).(*keyword.Plugin)
+	require.True(t, ok)
+
+	msg := plugin.Message{Text: "Cheap Pills here"}
+
+	result, err := p.Evaluate(context.Background(), msg)
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionSkip, result.Action)
+
+	added, err := p.AddKeyword(context.Background(), "pills")
+	require.NoError(t, err)
+	require.True(t, added)
+
+	for _, keyword := range []string{"Pills", "spam", "  "} {
+		added, err = p.AddKeyword(context.Background(), keyword)
+		require.NoError(t, err)
+		require.False(t, added, keyword)
+	}
+
+	result, err = p.Evaluate(context.Background(), msg)
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionBlock, result.Action)
+
+	// A new instance, e.g. after a restart or a configuration reload, keeps the added keywords
+	result, err = keyword.New(keyword.Config{Blacklist: nil}, state, zap.NewNop()).Evaluate(context.Background(), msg)
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionBlock, result.Action)
+	require.Equal(t, "pills", result.Metadata["keyword"])
+}
+
+func TestPlugin_ScopeToChat(t *testing.T) {
+	state := newState(t)
+	newPlugin := func(chatID int64) *keyword.Plugin {
+		p, ok := keyword.New(keyword.Config{Blacklist: nil}, state, zap.NewNop()).(*keyword.Plugin)
+		require.True(t, ok)
+		if chatID != 0 {
+			p.ScopeToChat(chatID)
+		}
+		return p
+	}
+
+	added, err := newPlugin(1).AddKeyword(context.Background(), "pills")
+	require.NoError(t, err)
+	require.True(t, added)
+
+	// Keywords added in a chat with an override are blocked only there
+	msg := plugin.Message{Text: "Cheap pills"}
+	for chatID, expected := range map[int64]plugin.Action{
+		0: plugin.ActionSkip,
+		1: plugin.ActionBlock,
+		2: plugin.ActionSkip,
+	} {
+		result, evalErr := newPlugin(chatID).Evaluate(context.Background(), msg)
+		require.NoError(t, evalErr)
+		require.Equal(t, expected, result.Action, chatID)
+	}
+}
+
+func TestPlugin_AddKeywordConcurrently(t *testing.T) {
+	state := newState(t)
+
+	// Every replica has an instance of its own
+	wg := sync.WaitGroup{}
+	for _, word := range []string{"pills", "casino", "crypto", "loans", "bonus"} {
+		p, ok := keyword.New(keyword.Config{Blacklist: nil}, state, zap.NewNop()).(*keyword.Plugin)
+		require.True(t, ok)
+
+		wg.Go(func() {
+			added, err := p.AddKeyword(context.Background(), word)
+			require.NoError(t, err)
+			require.True(t, added)
+		})
+	}
+	wg.Wait()
+
+	p := keyword.New(keyword.Config{Blacklist: nil}, state, zap.NewNop())
+	for _, word := range []string{"pills", "casino", "crypto", "loans", "bonus"} {
+		result, err := p.Evaluate(context.Background(), plugin.Message{Text: word})
+		require.NoError(t, err)
+		require.Equal(t, plugin.ActionBlock, result.Action, word)
+	}
+}
+
+// failingState fails to load keys after the failure is enabled.
+type failingState struct {
+	storage.State
+
+	failing bool
+	loads   int
+	mu      sync.Mutex
+}
+
+func (s *failingState) Load(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loads++
+	if s.failing {
+		return nil, errors.New("storage is down")
+	}
+
+	return s.State.Load(ctx, key)
+}
+
+func TestPlugin_StorageFailure(t *testing.T) {
+	state := &failingState{State: newState(t), failing: true, loads: 0, mu: sync.Mutex{}}
+	p := keyword.New(keyword.Config{Blacklist: []string{"spam"}}, state, zap.NewNop())
+
+	// The configured keywords are checked while the storage is down, and the added keywords
+	// are not reloaded for every message
+	for range 10 {
+		result, err := p.Evaluate(context.Background(), plugin.Message{Text: "spam"})
+		require.NoError(t, err)
+		require.Equal(t, plugin.ActionBlock, result.Action)
+
+		result, err = p.Evaluate(context.Background(), plugin.Message{Text: "hello"})
+		require.NoError(t, err)
+		require.Equal(t, plugin.ActionSkip, result.Action)
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	require.Equal(t, 1, state.loads)
+}
@@ -0,0 +1,104 @@
+package links
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+)
+
+// DefaultShorteners returns the domains of well-known URL shorteners.
+func DefaultShorteners() []string {
+	return []string{
+		"bit.ly", "bitly.com", "buff.ly", "clck.ru", "cutt.ly", "goo.gl", "is.gd", "lnkd.in", "ow.ly",
+		"rb.gy", "rebrand.ly", "s.id", "shorturl.at", "t.co", "t.ly", "tiny.cc", "tinyurl.com", "v.gd",
+	}
+}
+
+// Config holds the plugin configuration.
+// Domain patterns match the whole domain, "*" matches any characters, e.g. "*.example.com" matches subdomains.
+type Config struct {
+	AllowedDomains  []string // links to these domains are never blocked
+	BlockedDomains  []string // links to these domains are blocked
+	BlockUnknown    bool     // block links to domains not in AllowedDomains
+	BlockShorteners bool     // block links to URL shorteners
+	Shorteners      []string // additional URL shortener domains
+
+	MinMessages int // block links of users with fewer previous messages which were not blocked, 0 disables
+}
+
+// NewConfig parses the plugin configuration.
+func NewConfig(params map[string]any) (Config, error) {
+	var err error
+	cfg := Config{
+		AllowedDomains:  []string{},
+		BlockedDomains:  []string{},
+		BlockUnknown:    false,
+		BlockShorteners: true,
+		Shorteners:      []string{},
+		MinMessages:     0,
+	}
+
+	if cfg.AllowedDomains, err = plugin.SliceFromAnyOrDefault(params, "allowed_domains", cfg.AllowedDomains); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	if cfg.BlockedDomains, err = plugin.SliceFromAnyOrDefault(params, "blocked_domains", cfg.BlockedDomains); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	if cfg.BlockUnknown, err = plugin.ConfigValue(params, "block_unknown", cfg.BlockUnknown); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	if cfg.BlockShorteners, err = plugin.ConfigValue(params, "block_shorteners", cfg.BlockShorteners); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	if cfg.Shorteners, err = plugin.SliceFromAnyOrDefault(params, "shorteners", cfg.Shorteners); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	if cfg.MinMessages, err = plugin.ConfigValue(params, "min_messages", cfg.MinMessages); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	cfg.AllowedDomains = normalizePatterns(cfg.AllowedDomains)
+	cfg.BlockedDomains = normalizePatterns(cfg.BlockedDomains)
+	cfg.Shorteners = normalizePatterns(cfg.Shorteners)
+
+	if validateErr := cfg.Validate(); validateErr != nil {
+		return Config{}, validateErr
+	}
+
+	return cfg, nil
+}
+
+// Validate checks if the configuration values are valid.
+func (c Config) Validate() error {
+	for _, patterns := range [][]string{c.AllowedDomains, c.BlockedDomains, c.Shorteners} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%w: invalid domain pattern %q: %w", plugin.ErrInvalidConfig, pattern, err)
+			}
+		}
+	}
+
+	if c.MinMessages < 0 {
+		return fmt.Errorf("%w: min_messages must be >= 0, got: %d", plugin.ErrInvalidConfig, c.MinMessages)
+	}
+
+	return nil
+}
+
+func normalizePatterns(patterns []string) []string {
+	normalized := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(p)), "."); p != "" {
+			normalized = append(normalized, p)
+		}
+	}
+
+	return normalized
+}
@@ -0,0 +1,152 @@
+package links
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+)
+
+// Metadata returns the plugin metadata, messages of users are counted in the state of the storage backend.
+func Metadata(state storage.State) plugin.Metadata {
+	return plugin.Metadata{
+		Name: "links",
+		Factory: func(params map[string]any) (plugin.Plugin, error) {
+			config, err := NewConfig(params)
+			if err != nil {
+				return nil, err
+			}
+
+			return New(config, state), nil
+		},
+	}
+}
+
+type Plugin struct {
+	config  Config
+	counter *storage.MessageCounter
+}
+
+// New creates a plugin counting messages of users in the state.
+func New(config Config, state storage.State) plugin.Plugin {
+	if config.BlockShorteners {
+		config.Shorteners = slices.Concat(DefaultShorteners(), config.Shorteners)
+	}
+
+	return &Plugin{
+		config:  config,
+		counter: storage.NewMessageCounter(state, "links"),
+	}
+}
+
+func (p *Plugin) Name() string {
+	return "links"
+}
+
+func (p *Plugin) Priority() int {
+	const priority = 18
+	return priority
+}
+
+func (p *Plugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Result, error) {
+	// Messages which passed the evaluation are counted by Record
+	previous := 0
+	if p.config.MinMessages > 0 {
+		count, err := p.counter.Count(ctx, counterKey(msg))
+		if err != nil {
+			return plugin.Result{}, fmt.Errorf("failed to count messages: %w", err)
+		}
+		previous = count
+	}
+
+	links := slices.Concat(
+		extractLinks(msg.Text, msg.Entities),
+		extractLinks(msg.Caption, msg.CaptionEntities),
+		extractButtonLinks(msg.Buttons),
+	)
+	if len(links) == 0 {
+		return plugin.Result{
+			Action:   plugin.ActionSkip,
+			Reason:   "no links found",
+			Metadata: nil,
+			Plugin:   p.Name(),
+		}, nil
+	}
+
+	for _, l := range links {
+		if matchDomain(p.config.AllowedDomains, l.Domain) {
+			continue
+		}
+
+		if rule, reason := p.check(l, previous); rule != "" {
+			return plugin.Result{
+				Action: plugin.ActionBlock,
+				Reason: reason,
+				Metadata: map[string]any{
+					"url":    l.URL,
+					"domain": l.Domain,
+					"rule":   rule,
+				},
+				Plugin: p.Name(),
+			}, nil
+		}
+	}
+
+	return plugin.Result{
+		Action: plugin.ActionSkip,
+		Reason: "no forbidden links found",
+		Metadata: map[string]any{
+			"links": len(links),
+		},
+		Plugin: p.Name(),
+	}, nil
+}
+
+// check returns the rule blocking the link not in the allowed domains and the reason, or empty strings.
+func (p *Plugin) check(l link, previous int) (string, string) {
+	switch {
+	case matchDomain(p.config.BlockedDomains, l.Domain):
+		return "blocked_domain", "Message contains a link to a blocked domain"
+	case p.config.BlockShorteners && matchDomain(p.config.Shorteners, l.Domain):
+		return "shortener", "Message contains a shortened link"
+	case p.config.BlockUnknown:
+		return "unknown_domain", "Message contains a link to a domain not allowed"
+	case p.config.MinMessages > 0 && previous < p.config.MinMessages:
+		return "new_user", fmt.Sprintf("Links are not allowed before %d messages", p.config.MinMessages)
+	}
+
+	return "", ""
+}
+
+// Record counts the message of the user, whether it contains links or not, edits are not counted.
+func (p *Plugin) Record(ctx context.Context, msg plugin.Message) error {
+	if p.config.MinMessages == 0 || msg.IsEdit {
+		return nil
+	}
+
+	return p.counter.Increment(ctx, counterKey(msg), p.config.MinMessages)
+}
+
+func (p *Plugin) Cleanup(_ context.Context) {
+	// no-op, counters are kept in the state of the storage backend
+}
+
+// counterKey returns the key the messages of the sender are counted under.
+func counterKey(msg plugin.Message) string {
+	return msg.StateKey(strconv.FormatInt(msg.UserID, 10))
+}
+
+// matchDomain reports whether the domain matches any of the patterns.
+func matchDomain(patterns []string, domain string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, domain); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,166 @@
+package links_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/links"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func newConfig(t *testing.T, params map[string]any) links.Config {
+	t.Helper()
+
+	config, err := links.NewConfig(params)
+	require.NoError(t, err)
+
+	return config
+}
+
+func newState(t *testing.T) storage.State {
+	t.Helper()
+
+	s, err := storage.New(storage.Config{URL: "memory://storage?ttl=1h"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	return storage.NewState(s)
+}
+
+func TestPlugin_Evaluate(t *testing.T) {
+	p := links.New(newConfig(t, map[string]any{
+		"allowed_domains": []any{"*.example.com", "example.com"},
+		"blocked_domains": []any{"spam.io", "*.casino.net"},
+		"shorteners":      []any{"my.link"},
+	}), newState(t))
+
+	tests := []struct {
+		name     string
+		message  plugin.Message
+		expected plugin.Action
+		rule     string
+	}{
+		{"no links skips", plugin.Message{Text: "Hello world"}, plugin.ActionSkip, ""},
+		{"unknown domain skips", plugin.Message{Text: "see https://golang.org/doc"}, plugin.ActionSkip, ""},
+		{"allowed subdomain skips", plugin.Message{Text: "docs.example.com/page"}, plugin.ActionSkip, ""},
+		{"blocked domain blocks", plugin.Message{Text: "visit https://SPAM.io/x"}, plugin.ActionBlock, "blocked_domain"},
+		{"blocked www domain blocks", plugin.Message{Text: "www.spam.io"}, plugin.ActionBlock, "blocked_domain"},
+		{
+			"blocked wildcard in caption blocks",
+			plugin.Message{Caption: "win at http://lucky.casino.net"},
+			plugin.ActionBlock,
+			"blocked_domain",
+		},
+		{"default shortener blocks", plugin.Message{Text: "https://bit.ly/abc"}, plugin.ActionBlock, "shortener"},
+		{"extra shortener blocks", plugin.Message{Text: "https://my.link/abc"}, plugin.ActionBlock, "shortener"},
+		{
+			"hidden text link blocks",
+			plugin.Message{
+				Text:     "click here",
+				Entities: []plugin.Entity{{Type: "text_link", Offset: 0, Length: 5, URL: "https://spam.io/promo"}},
+			},
+			plugin.ActionBlock,
+			"blocked_domain",
+		},
+		{
+			"button link blocks",
+			plugin.Message{
+				Text:    "Hello",
+				Buttons: []plugin.Button{{Text: "Open", URL: "https://bit.ly/promo"}, {Text: "Ok", CallbackData: "ok"}},
+			},
+			plugin.ActionBlock,
+			"shortener",
+		},
+		{
+			"url entity blocks",
+			plugin.Message{
+				Text:     "👋 spam.io",
+				Entities: []plugin.Entity{{Type: "url", Offset: 3, Length: 7}},
+			},
+			plugin.ActionBlock,
+			"blocked_domain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := p.Evaluate(context.Background(), tt.message)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result.Action)
+			if tt.rule != "" {
+				require.Equal(t, tt.rule, result.Metadata["rule"])
+			}
+		})
+	}
+}
+
+func TestPlugin_BlockUnknown(t *testing.T) {
+	p := links.New(newConfig(t, map[string]any{
+		"allowed_domains":  []any{"example.com"},
+		"block_unknown":    true,
+		"block_shorteners": false,
+	}), newState(t))
+
+	result, err := p.Evaluate(context.Background(), plugin.Message{Text: "https://example.com and https://bit.ly/x"})
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionBlock, result.Action)
+	require.Equal(t, "unknown_domain", result.Metadata["rule"])
+	require.Equal(t, "bit.ly", result.Metadata["domain"])
+
+	result, err = p.Evaluate(context.Background(), plugin.Message{Text: "https://www.example.com/path"})
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionSkip, result.Action)
+}
+
+func TestPlugin_MinMessages(t *testing.T) {
+	state := newState(t)
+	config := newConfig(t, map[string]any{
+		"allowed_domains": []any{"example.com"},
+		"min_messages":    2,
+	})
+	p, ok := links.New(config, state).(*links.Plugin)
+	require.True(t, ok)
+
+	evaluate := func(msg plugin.Message) plugin.Result {
+		result, err := p.Evaluate(context.Background(), msg)
+		require.NoError(t, err)
+		return result
+	}
+	link := plugin.Message{UserID: 1, Text: "https://golang.org"}
+
+	result := evaluate(link)
+	require.Equal(t, plugin.ActionBlock, result.Action)
+	require.Equal(t, "new_user", result.Metadata["rule"])
+
+	// Allowed domains are not limited for new users, only messages which passed the evaluation are counted
+	require.Equal(t, plugin.ActionSkip, evaluate(plugin.Message{UserID: 1, Text: "https://example.com"}).Action)
+	require.NoError(t, p.Record(context.Background(), plugin.Message{UserID: 1, Text: "https://example.com"}))
+	require.NoError(t, p.Record(context.Background(), plugin.Message{UserID: 1, Text: "edited", IsEdit: true}))
+	require.Equal(t, plugin.ActionBlock, evaluate(link).Action)
+
+	require.NoError(t, p.Record(context.Background(), plugin.Message{UserID: 1, Text: "hello"}))
+	require.Equal(t, plugin.ActionSkip, evaluate(link).Action)
+
+	// Messages are counted per user and the count is kept by new instances
+	require.Equal(t, plugin.ActionBlock, evaluate(plugin.Message{UserID: 2, Text: "https://golang.org"}).Action)
+
+	result, err := links.New(config, state).Evaluate(context.Background(), link)
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionSkip, result.Action)
+}
+
+func TestNewConfig(t *testing.T) {
+	config := newConfig(t, nil)
+	require.True(t, config.BlockShorteners)
+
+	config = newConfig(t, map[string]any{"blocked_domains": []any{" Spam.IO. "}})
+	require.Equal(t, []string{"spam.io"}, config.BlockedDomains)
+
+	_, err := links.NewConfig(map[string]any{"blocked_domains": []any{"[spam"}})
+	require.ErrorIs(t, err, plugin.ErrInvalidConfig)
+
+	_, err = links.NewConfig(map[string]any{"min_messages": -1})
+	require.ErrorIs(t, err, plugin.ErrInvalidConfig)
+}
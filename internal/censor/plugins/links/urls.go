@@ -0,0 +1,100 @@
+package links
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"golang.org/x/net/idna"
+)
+
+// urlPattern finds links with a scheme or "www." in texts without entities, e.g. messages of the HTTP API.
+var urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+
+// link is a normalized URL found in a message.
+type link struct {
+	URL    string
+	Domain string
+}
+
+// extractLinks returns the links of the text and its entities, including hidden text links.
+func extractLinks(text string, entities []plugin.Entity) []link {
+	raw := []string{}
+	for _, e := range entities {
+		switch e.Type {
+		case "url":
+			raw = append(raw, e.Text(text))
+		case "text_link":
+			raw = append(raw, e.URL)
+		}
+	}
+	raw = append(raw, urlPattern.FindAllString(text, -1)...)
+
+	seen := map[string]struct{}{}
+	links := make([]link, 0, len(raw))
+	for _, r := range raw {
+		l, ok := normalizeURL(r)
+		if !ok {
+			continue
+		}
+		if _, dup := seen[l.URL]; dup {
+			continue
+		}
+
+		seen[l.URL] = struct{}{}
+		links = append(links, l)
+	}
+
+	return links
+}
+
+// extractButtonLinks returns the links opened by inline keyboard buttons.
+func extractButtonLinks(buttons []plugin.Button) []link {
+	links := make([]link, 0, len(buttons))
+	for _, b := range buttons {
+		if l, ok := normalizeURL(b.URL); ok {
+			links = append(links, l)
+		}
+	}
+
+	return links
+}
+
+// normalizeURL adds a missing scheme and converts the domain to lower-case ASCII without "www.".
+func normalizeURL(raw string) (link, bool) {
+	raw = strings.TrimRight(strings.TrimSpace(raw), ".,;:!?)")
+	if raw == "" {
+		return link{}, false
+	}
+
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return link{}, false
+	}
+
+	// tg:// and other non-web links have no domain to check
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return link{}, false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if ascii, idnaErr := idna.Lookup.ToASCII(host); idnaErr == nil {
+		host = ascii
+	}
+
+	domain := strings.TrimPrefix(host, "www.")
+	if _, port, splitErr := net.SplitHostPort(u.Host); splitErr == nil && port != "" {
+		u.Host = host + ":" + port
+	} else {
+		u.Host = host
+	}
+	u.Fragment = ""
+
+	return link{URL: u.String(), Domain: domain}, true
+}
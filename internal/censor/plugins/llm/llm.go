@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
 	"github.com/invopop/jsonschema"
 	openai "github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -20,12 +22,13 @@ type Response struct {
 }
 
 type Cache interface {
-	SetBy(text, model, prompt string, resp *Response)
-	GetBy(text, model, prompt string) (*Response, bool)
+	SetBy(ctx context.Context, text, model, prompt string, resp *Response)
+	GetBy(ctx context.Context, text, model, prompt string) (*Response, bool)
 	Cleanup()
 }
 
-func Metadata() plugin.Metadata {
+// Metadata returns the plugin metadata, responses are cached in the shared store if kv is not nil.
+func Metadata(kv storage.KV) plugin.Metadata {
 	return plugin.Metadata{
 		Name: "llm",
 		Factory: func(params map[string]any) (plugin.Plugin, error) {
@@ -34,6 +37,10 @@ func Metadata() plugin.Metadata {
 				return nil, err
 			}
 
+			if kv != nil {
+				return NewShared(config, kv)
+			}
+
 			return New(config)
 		},
 	}
@@ -42,11 +49,22 @@ func Metadata() plugin.Metadata {
 type Plugin struct {
 	config         Config
 	client         openai.Client
+	httpClient     *http.Client // own client of the instance, its connections are closed with the plugin
 	responseSchema map[string]any
 	cache          Cache
 }
 
+// New creates a plugin caching responses in memory.
 func New(config Config) (plugin.Plugin, error) {
+	return newPlugin(config, NewStorage(config.CacheTTL, config.CacheMaxSize))
+}
+
+// NewShared creates a plugin caching responses in the shared store.
+func NewShared(config Config, kv storage.KV) (plugin.Plugin, error) {
+	return newPlugin(config, NewSharedStorage(config.CacheTTL, kv))
+}
+
+func newPlugin(config Config, cache Cache) (plugin.Plugin, error) {
 	reflector := jsonschema.Reflector{
 		AllowAdditionalProperties: false,
 		DoNotReference:            true,
@@ -67,7 +85,11 @@ func New(config Config) (plugin.Plugin, error) {
 		baseURL = strings.TrimRight(config.BaseURL, "/")
 	}
 
+	httpClient := &http.Client{ //nolint:exhaustruct // defaults
+		Transport: http.DefaultTransport.(*http.Transport).Clone(), //nolint:forcetypeassert // standard library type
+	}
 	client := openai.NewClient(
+		option.WithHTTPClient(httpClient),
 		option.WithAPIKey(config.APIKey),
 		option.WithBaseURL(baseURL),
 		option.WithHeader("HTTP-Referer", "https://t.me/NeoCensorBot"),
@@ -77,8 +99,9 @@ func New(config Config) (plugin.Plugin, error) {
 	return &Plugin{
 		config:         config,
 		client:         client,
+		httpClient:     httpClient,
 		responseSchema: responseSchema,
-		cache:          NewStorage(config.CacheTTL, config.CacheMaxSize),
+		cache:          cache,
 	}, nil
 }
 
@@ -92,10 +115,7 @@ func (p *Plugin) Priority() int {
 }
 
 func (p *Plugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Result, error) {
-	text := msg.Text
-	if text == "" {
-		text = msg.Caption
-	}
+	text := msg.Content()
 
 	if text == "" {
 		return plugin.Result{
@@ -111,7 +131,7 @@ func (p *Plugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Resul
 
 	// Check cache first
 	if p.config.CacheEnabled {
-		if cachedResp, found := p.cache.GetBy(text, p.config.Model, p.config.Prompt); found {
+		if cachedResp, found := p.cache.GetBy(ctx, text, p.config.Model, p.config.Prompt); found {
 			result := p.evaluateResponse(cachedResp)
 			result.Metadata["cached"] = true
 			return result, nil
@@ -126,7 +146,7 @@ func (p *Plugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Resul
 
 	// Store in cache
 	if p.config.CacheEnabled {
-		p.cache.SetBy(text, p.config.Model, p.config.Prompt, llmResponse)
+		p.cache.SetBy(ctx, text, p.config.Model, p.config.Prompt, llmResponse)
 	}
 
 	result := p.evaluateResponse(llmResponse)
@@ -136,12 +156,19 @@ func (p *Plugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Resul
 }
 
 func (p *Plugin) evaluateResponse(response *Response) plugin.Result {
+	// Report low-confidence findings as a partial score for the scoring strategy
+	score := 0.0
+	if response.Inappropriate {
+		score = response.Confidence
+	}
+
 	if response.Inappropriate && response.Confidence >= p.config.ConfidenceThreshold {
 		return plugin.Result{
 			Action: plugin.ActionBlock,
 			Reason: response.Reason,
 			Metadata: map[string]any{
-				"confidence": response.Confidence,
+				"confidence":            response.Confidence,
+				plugin.MetadataKeyScore: score,
 			},
 			Plugin: p.Name(),
 		}
@@ -151,7 +178,8 @@ func (p *Plugin) evaluateResponse(response *Response) plugin.Result {
 		Action: plugin.ActionSkip,
 		Reason: "message appears appropriate",
 		Metadata: map[string]any{
-			"confidence": response.Confidence,
+			"confidence":            response.Confidence,
+			plugin.MetadataKeyScore: score,
 		},
 		Plugin: p.Name(),
 	}
@@ -210,3 +238,9 @@ func (p *Plugin) buildPrompt(text string) string {
 func (p *Plugin) Cleanup(_ context.Context) {
 	p.cache.Cleanup()
 }
+
+// Close closes the idle connections to the LLM API.
+func (p *Plugin) Close() error {
+	p.httpClient.CloseIdleConnections()
+	return nil
+}
@@ -1,10 +1,14 @@
 package llm
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"sync"
 	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/storage"
 )
 
 // Storage implements an in-memory cache for LLM responses
@@ -37,7 +41,7 @@ func NewStorage(ttl time.Duration, maxSize int) *Storage {
 
 // generateCacheKey creates a stable hash key from message text, model, and prompt
 // This ensures identical content with same configuration gets cache hits.
-func (s *Storage) generateCacheKey(text string, model string, prompt string) string {
+func generateCacheKey(text string, model string, prompt string) string {
 	// Include text, model, and prompt in hash to ensure cache validity
 	data := text + "\x00" + model + "\x00" + prompt
 	hash := sha256.Sum256([]byte(data))
@@ -68,8 +72,8 @@ func (s *Storage) Get(key string) (*Response, bool) {
 	return entry.Response, true
 }
 
-func (s *Storage) GetBy(text, model, prompt string) (*Response, bool) {
-	key := s.generateCacheKey(text, model, prompt)
+func (s *Storage) GetBy(_ context.Context, text, model, prompt string) (*Response, bool) {
+	key := generateCacheKey(text, model, prompt)
 	return s.Get(key)
 }
 
@@ -91,8 +95,8 @@ func (s *Storage) Set(key string, resp *Response) {
 	}
 }
 
-func (s *Storage) SetBy(text, model, prompt string, resp *Response) {
-	key := s.generateCacheKey(text, model, prompt)
+func (s *Storage) SetBy(_ context.Context, text, model, prompt string, resp *Response) {
+	key := generateCacheKey(text, model, prompt)
 	s.Set(key, resp)
 }
 
@@ -127,3 +131,45 @@ func (s *Storage) Cleanup() {
 		}
 	}
 }
+
+// SharedStorage implements a cache for LLM responses shared between replicas.
+// Entries expire after the TTL, the size is limited by the eviction policy of the shared store.
+// Errors of the shared store are treated as cache misses.
+type SharedStorage struct {
+	ttl time.Duration
+	kv  storage.KV
+}
+
+// NewSharedStorage creates a new shared cache storage with specified TTL.
+func NewSharedStorage(ttl time.Duration, kv storage.KV) *SharedStorage {
+	return &SharedStorage{
+		ttl: ttl,
+		kv:  kv,
+	}
+}
+
+func (s *SharedStorage) GetBy(ctx context.Context, text, model, prompt string) (*Response, bool) {
+	data, err := s.kv.Load(ctx, "llm:"+generateCacheKey(text, model, prompt))
+	if err != nil {
+		return nil, false
+	}
+
+	resp := new(Response)
+	if unmarshalErr := json.Unmarshal(data, resp); unmarshalErr != nil {
+		return nil, false
+	}
+
+	return resp, true
+}
+
+func (s *SharedStorage) SetBy(ctx context.Context, text, model, prompt string, resp *Response) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+
+	_ = s.kv.Store(ctx, "llm:"+generateCacheKey(text, model, prompt), data, s.ttl)
+}
+
+// Cleanup is a no-op, entries are expired by the shared store.
+func (s *SharedStorage) Cleanup() {}
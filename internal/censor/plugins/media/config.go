@@ -0,0 +1,131 @@
+package media
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+)
+
+// Content kinds in addition to plugin.MediaKind values.
+const (
+	KindGIF             = "gif"              // animation, alias of "animation"
+	KindAnimatedSticker = "animated_sticker" // animated sticker, also matched by "sticker"
+)
+
+// Kinds returns all content kinds accepted by the configuration.
+func Kinds() []string {
+	return []string{
+		string(plugin.MediaKindPhoto),
+		string(plugin.MediaKindVideo),
+		string(plugin.MediaKindAnimation),
+		KindGIF,
+		string(plugin.MediaKindDocument),
+		string(plugin.MediaKindAudio),
+		string(plugin.MediaKindVoice),
+		string(plugin.MediaKindVideoNote),
+		string(plugin.MediaKindSticker),
+		KindAnimatedSticker,
+		string(plugin.MediaKindContact),
+		string(plugin.MediaKindLocation),
+		string(plugin.MediaKindVenue),
+		string(plugin.MediaKindPoll),
+		string(plugin.MediaKindDice),
+		string(plugin.MediaKindGame),
+		string(plugin.MediaKindStory),
+	}
+}
+
+// Config holds the plugin configuration.
+type Config struct {
+	BlockedKinds []string // content kinds to block
+	AllowedKinds []string // if not empty, content kinds not listed are blocked
+
+	BlockedMimeTypes  []string // MIME type patterns of documents to block, e.g. "application/vnd.android.*"
+	BlockedExtensions []string // file extensions of documents to block, without the dot
+
+	MinMessages int // apply the rules only to users with fewer previous messages which were not blocked, 0 applies them to everyone
+}
+
+// NewConfig parses the plugin configuration.
+func NewConfig(params map[string]any) (Config, error) {
+	var err error
+	cfg := Config{
+		BlockedKinds:      []string{},
+		AllowedKinds:      []string{},
+		BlockedMimeTypes:  []string{},
+		BlockedExtensions: []string{},
+		MinMessages:       0,
+	}
+
+	if cfg.BlockedKinds, err = plugin.SliceFromAnyOrDefault(params, "blocked_kinds", cfg.BlockedKinds); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	if cfg.AllowedKinds, err = plugin.SliceFromAnyOrDefault(params, "allowed_kinds", cfg.AllowedKinds); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	if cfg.BlockedMimeTypes, err = plugin.SliceFromAnyOrDefault(
+		params, "blocked_mime_types", cfg.BlockedMimeTypes,
+	); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	if cfg.BlockedExtensions, err = plugin.SliceFromAnyOrDefault(
+		params, "blocked_extensions", cfg.BlockedExtensions,
+	); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	if cfg.MinMessages, err = plugin.ConfigValue(params, "min_messages", cfg.MinMessages); err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+
+	cfg.BlockedKinds = normalize(cfg.BlockedKinds, "")
+	cfg.AllowedKinds = normalize(cfg.AllowedKinds, "")
+	cfg.BlockedMimeTypes = normalize(cfg.BlockedMimeTypes, "")
+	cfg.BlockedExtensions = normalize(cfg.BlockedExtensions, ".")
+
+	if validateErr := cfg.Validate(); validateErr != nil {
+		return Config{}, validateErr
+	}
+
+	return cfg, nil
+}
+
+// Validate checks if the configuration values are valid.
+func (c Config) Validate() error {
+	kinds := Kinds()
+	for _, kind := range slices.Concat(c.BlockedKinds, c.AllowedKinds) {
+		if !slices.Contains(kinds, kind) {
+			return fmt.Errorf("%w: unknown content kind %q", plugin.ErrInvalidConfig, kind)
+		}
+	}
+
+	for _, pattern := range c.BlockedMimeTypes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: invalid MIME type pattern %q: %w", plugin.ErrInvalidConfig, pattern, err)
+		}
+	}
+
+	if c.MinMessages < 0 {
+		return fmt.Errorf("%w: min_messages must be >= 0, got: %d", plugin.ErrInvalidConfig, c.MinMessages)
+	}
+
+	return nil
+}
+
+// normalize lowercases the values and trims spaces and the prefix.
+func normalize(values []string, prefix string) []string {
+	normalized := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(v)), prefix); v != "" {
+			normalized = append(normalized, v)
+		}
+	}
+
+	return normalized
+}
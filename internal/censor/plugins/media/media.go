@@ -0,0 +1,175 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+)
+
+// Metadata returns the plugin metadata, messages of users are counted in the state of the storage backend.
+func Metadata(state storage.State) plugin.Metadata {
+	return plugin.Metadata{
+		Name: "media",
+		Factory: func(params map[string]any) (plugin.Plugin, error) {
+			config, err := NewConfig(params)
+			if err != nil {
+				return nil, err
+			}
+
+			return New(config, state), nil
+		},
+	}
+}
+
+type Plugin struct {
+	config  Config
+	counter *storage.MessageCounter
+}
+
+// New creates a plugin counting messages of users in the state.
+func New(config Config, state storage.State) plugin.Plugin {
+	return &Plugin{
+		config:  config,
+		counter: storage.NewMessageCounter(state, "media"),
+	}
+}
+
+func (p *Plugin) Name() string {
+	return "media"
+}
+
+func (p *Plugin) Priority() int {
+	const priority = 12
+	return priority
+}
+
+func (p *Plugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Result, error) {
+	// Messages which passed the evaluation are counted by Record
+	if p.config.MinMessages > 0 {
+		count, err := p.counter.Count(ctx, counterKey(msg))
+		if err != nil {
+			return plugin.Result{}, fmt.Errorf("failed to count messages: %w", err)
+		}
+
+		if count >= p.config.MinMessages {
+			return plugin.Result{
+				Action:   plugin.ActionSkip,
+				Reason:   "user is not a newcomer",
+				Metadata: nil,
+				Plugin:   p.Name(),
+			}, nil
+		}
+	}
+
+	if msg.Media == nil {
+		return plugin.Result{
+			Action:   plugin.ActionSkip,
+			Reason:   "no media found",
+			Metadata: nil,
+			Plugin:   p.Name(),
+		}, nil
+	}
+
+	if rule, reason := p.check(*msg.Media); rule != "" {
+		return plugin.Result{
+			Action: plugin.ActionBlock,
+			Reason: reason,
+			Metadata: map[string]any{
+				"kind":      string(msg.Media.Kind),
+				"mime_type": msg.Media.MimeType,
+				"file_name": msg.Media.FileName,
+				"rule":      rule,
+			},
+			Plugin: p.Name(),
+		}, nil
+	}
+
+	return plugin.Result{
+		Action: plugin.ActionSkip,
+		Reason: "media allowed",
+		Metadata: map[string]any{
+			"kind": string(msg.Media.Kind),
+		},
+		Plugin: p.Name(),
+	}, nil
+}
+
+// check returns the rule blocking the media and the reason, or empty strings.
+func (p *Plugin) check(media plugin.Media) (string, string) {
+	kinds := mediaKinds(media)
+	matches := func(list []string) bool {
+		return slices.ContainsFunc(kinds, func(kind string) bool { return slices.Contains(list, kind) })
+	}
+
+	switch {
+	case matches(p.config.BlockedKinds):
+		return "blocked_kind", fmt.Sprintf("Messages with %s are not allowed", kinds[0])
+	case len(p.config.AllowedKinds) > 0 && !matches(p.config.AllowedKinds):
+		return "not_allowed_kind", fmt.Sprintf("Messages with %s are not allowed", kinds[0])
+	case media.Kind != plugin.MediaKindDocument:
+		return "", ""
+	case matchMimeType(p.config.BlockedMimeTypes, media.MimeType):
+		return "blocked_mime_type", "Documents of this type are not allowed"
+	case slices.Contains(p.config.BlockedExtensions, extension(media.FileName)):
+		return "blocked_extension", "Documents of this type are not allowed"
+	}
+
+	return "", ""
+}
+
+// Record counts the message of the user, whether it contains media or not, edits are not counted.
+func (p *Plugin) Record(ctx context.Context, msg plugin.Message) error {
+	if p.config.MinMessages == 0 || msg.IsEdit {
+		return nil
+	}
+
+	return p.counter.Increment(ctx, counterKey(msg), p.config.MinMessages)
+}
+
+func (p *Plugin) Cleanup(_ context.Context) {
+	// no-op, counters are kept in the state of the storage backend
+}
+
+// counterKey returns the key the messages of the sender are counted under.
+func counterKey(msg plugin.Message) string {
+	return msg.StateKey(strconv.FormatInt(msg.UserID, 10))
+}
+
+// mediaKinds returns the content kinds matching the media, the most specific first.
+func mediaKinds(media plugin.Media) []string {
+	switch {
+	case media.Kind == plugin.MediaKindSticker && media.IsAnimated:
+		return []string{KindAnimatedSticker, string(plugin.MediaKindSticker)}
+	case media.Kind == plugin.MediaKindAnimation:
+		return []string{KindGIF, string(plugin.MediaKindAnimation)}
+	}
+
+	return []string{string(media.Kind)}
+}
+
+// matchMimeType reports whether the MIME type matches any of the patterns.
+func matchMimeType(patterns []string, mimeType string) bool {
+	if mimeType == "" {
+		return false
+	}
+
+	mimeType = strings.ToLower(mimeType)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, mimeType); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extension returns the lowercased extension of the file name without the dot.
+func extension(fileName string) string {
+	return strings.TrimPrefix(strings.ToLower(path.Ext(fileName)), ".")
+}
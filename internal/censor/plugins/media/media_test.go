@@ -0,0 +1,144 @@
+package media_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/media"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func newPlugin(t *testing.T, params map[string]any) plugin.Plugin {
+	t.Helper()
+
+	config, err := media.NewConfig(params)
+	require.NoError(t, err)
+
+	s, err := storage.New(storage.Config{URL: "memory://storage?ttl=1h"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	return media.New(config, storage.NewState(s))
+}
+
+func withMedia(m plugin.Media) plugin.Message {
+	return plugin.Message{UserID: 1, Media: &m}
+}
+
+func TestPlugin_Evaluate(t *testing.T) {
+	p := newPlugin(t, map[string]any{
+		"blocked_kinds":      []any{"contact", "animated_sticker", "gif"},
+		"blocked_mime_types": []any{"application/vnd.android.*"},
+		"blocked_extensions": []any{".APK", "exe"},
+	})
+
+	tests := []struct {
+		name     string
+		message  plugin.Message
+		expected plugin.Action
+		rule     string
+	}{
+		{"text skips", plugin.Message{Text: "Hello"}, plugin.ActionSkip, ""},
+		{"photo skips", withMedia(plugin.Media{Kind: plugin.MediaKindPhoto}), plugin.ActionSkip, ""},
+		{"contact blocks", withMedia(plugin.Media{Kind: plugin.MediaKindContact}), plugin.ActionBlock, "blocked_kind"},
+		{"static sticker skips", withMedia(plugin.Media{Kind: plugin.MediaKindSticker}), plugin.ActionSkip, ""},
+		{
+			"animated sticker blocks",
+			withMedia(plugin.Media{Kind: plugin.MediaKindSticker, IsAnimated: true}),
+			plugin.ActionBlock,
+			"blocked_kind",
+		},
+		{"gif blocks", withMedia(plugin.Media{Kind: plugin.MediaKindAnimation}), plugin.ActionBlock, "blocked_kind"},
+		{
+			"apk by mime type blocks",
+			withMedia(plugin.Media{
+				Kind:     plugin.MediaKindDocument,
+				FileName: "app",
+				MimeType: "application/vnd.android.package-archive",
+			}),
+			plugin.ActionBlock,
+			"blocked_mime_type",
+		},
+		{
+			"apk by extension blocks",
+			withMedia(plugin.Media{Kind: plugin.MediaKindDocument, FileName: "Free-VPN.apk"}),
+			plugin.ActionBlock,
+			"blocked_extension",
+		},
+		{
+			"pdf document skips",
+			withMedia(plugin.Media{Kind: plugin.MediaKindDocument, FileName: "rules.pdf", MimeType: "application/pdf"}),
+			plugin.ActionSkip,
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := p.Evaluate(context.Background(), tt.message)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result.Action)
+			if tt.rule != "" {
+				require.Equal(t, tt.rule, result.Metadata["rule"])
+			}
+		})
+	}
+}
+
+func TestPlugin_AllowedKinds(t *testing.T) {
+	p := newPlugin(t, map[string]any{
+		"allowed_kinds": []any{"photo", "sticker"},
+	})
+
+	for kind, expected := range map[plugin.MediaKind]plugin.Action{
+		plugin.MediaKindPhoto:    plugin.ActionSkip,
+		plugin.MediaKindSticker:  plugin.ActionSkip,
+		plugin.MediaKindVoice:    plugin.ActionBlock,
+		plugin.MediaKindPoll:     plugin.ActionBlock,
+		plugin.MediaKindDocument: plugin.ActionBlock,
+	} {
+		result, err := p.Evaluate(context.Background(), withMedia(plugin.Media{Kind: kind}))
+		require.NoError(t, err)
+		require.Equal(t, expected, result.Action, kind)
+	}
+}
+
+func TestPlugin_Newcomers(t *testing.T) {
+	p := newPlugin(t, map[string]any{
+		"blocked_kinds": []any{"document"},
+		"min_messages":  1,
+	})
+
+	document := withMedia(plugin.Media{Kind: plugin.MediaKindDocument, FileName: "file.zip"})
+
+	result, err := p.Evaluate(context.Background(), document)
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionBlock, result.Action)
+
+	// Only messages which passed the evaluation are counted
+	recorder, ok := p.(plugin.Recorder)
+	require.True(t, ok)
+	require.NoError(t, recorder.Record(context.Background(), plugin.Message{UserID: 1, Text: "hello"}))
+
+	result, err = p.Evaluate(context.Background(), document)
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionSkip, result.Action)
+	require.Equal(t, "user is not a newcomer", result.Reason)
+}
+
+func TestNewConfig(t *testing.T) {
+	config, err := media.NewConfig(map[string]any{"blocked_kinds": []any{" Contact "}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"contact"}, config.BlockedKinds)
+
+	_, err = media.NewConfig(map[string]any{"blocked_kinds": []any{"hologram"}})
+	require.ErrorIs(t, err, plugin.ErrInvalidConfig)
+
+	_, err = media.NewConfig(map[string]any{"blocked_mime_types": []any{"application/["}})
+	require.ErrorIs(t, err, plugin.ErrInvalidConfig)
+
+	_, err = media.NewConfig(map[string]any{"min_messages": -1})
+	require.ErrorIs(t, err, plugin.ErrInvalidConfig)
+}
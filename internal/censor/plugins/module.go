@@ -4,7 +4,10 @@ import (
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/duplicate"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/forwarded"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/keyword"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/links"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/llm"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/media"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/raid"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/ratelimit"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/regex"
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/users"
@@ -24,6 +27,9 @@ func Module() fx.Option {
 			fx.Annotate(duplicate.Metadata, fx.ResultTags(`group:"metadata"`)),
 			fx.Annotate(llm.Metadata, fx.ResultTags(`group:"metadata"`)),
 			fx.Annotate(users.Metadata, fx.ResultTags(`group:"metadata"`)),
+			fx.Annotate(links.Metadata, fx.ResultTags(`group:"metadata"`)),
+			fx.Annotate(media.Metadata, fx.ResultTags(`group:"metadata"`)),
+			fx.Annotate(raid.Metadata, fx.ResultTags(`group:"metadata"`)),
 		),
 	)
 }
@@ -0,0 +1,152 @@
+package raid
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
+)
+
+const (
+	DefaultWindow        = time.Minute
+	DefaultMaxMessages   = 30
+	DefaultMaxJoins      = 10
+	DefaultCalmPeriod    = 5 * time.Minute
+	DefaultNewUserWindow = 10 * time.Minute
+
+	MinWindow        = 10 * time.Second
+	MaxWindow        = time.Hour
+	MaxNewUserWindow = 24 * time.Hour
+)
+
+// Config represents the configuration for the raid detection plugin.
+type Config struct {
+	Window        time.Duration        // Time window messages and joins of a chat are counted in
+	MaxMessages   int                  // Maximum number of messages in the window, 0 to disable
+	MaxJoins      int                  // Maximum number of joins in the window, 0 to disable
+	CalmPeriod    time.Duration        // Lockdown is lifted after this period without spikes
+	NewUserWindow time.Duration        // Messages of users who joined within this period are blocked during lockdown
+	Restrict      lockdown.Restriction // Restriction of member permissions during lockdown
+}
+
+// NewConfig creates a new configuration from the provided map.
+func NewConfig(config map[string]any) (Config, error) {
+	c := DefaultConfig()
+
+	var err error
+	if c.Window, err = durationValue(config, "window", c.Window); err != nil {
+		return Config{}, err
+	}
+
+	if c.MaxMessages, err = plugin.ConfigValue(config, "max_messages", c.MaxMessages); err != nil {
+		return Config{}, err
+	}
+
+	if c.MaxJoins, err = plugin.ConfigValue(config, "max_joins", c.MaxJoins); err != nil {
+		return Config{}, err
+	}
+
+	if c.CalmPeriod, err = durationValue(config, "calm_period", c.CalmPeriod); err != nil {
+		return Config{}, err
+	}
+
+	if c.NewUserWindow, err = durationValue(config, "new_user_window", c.NewUserWindow); err != nil {
+		return Config{}, err
+	}
+
+	if restrict, ok := config["restrict"]; ok {
+		str, strOk := restrict.(string)
+		if !strOk {
+			return Config{}, fmt.Errorf(
+				"%w: failed to parse restrict: expected string, got %T",
+				plugin.ErrInvalidConfig,
+				restrict,
+			)
+		}
+		c.Restrict = lockdown.Restriction(str)
+	}
+
+	if err = c.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+// DefaultConfig returns a configuration with sensible defaults.
+func DefaultConfig() Config {
+	return Config{
+		Window:        DefaultWindow,
+		MaxMessages:   DefaultMaxMessages,
+		MaxJoins:      DefaultMaxJoins,
+		CalmPeriod:    DefaultCalmPeriod,
+		NewUserWindow: DefaultNewUserWindow,
+		Restrict:      lockdown.RestrictionNone,
+	}
+}
+
+// Validate checks if the configuration values are valid.
+func (c Config) Validate() error {
+	if c.Window < MinWindow || c.Window > MaxWindow {
+		return fmt.Errorf(
+			"%w: window must be between %s and %s, got: %s",
+			plugin.ErrInvalidConfig,
+			MinWindow,
+			MaxWindow,
+			c.Window,
+		)
+	}
+
+	if c.MaxMessages < 0 || c.MaxJoins < 0 {
+		return fmt.Errorf("%w: max_messages and max_joins must be >= 0", plugin.ErrInvalidConfig)
+	}
+
+	if c.MaxMessages == 0 && c.MaxJoins == 0 {
+		return fmt.Errorf("%w: max_messages and max_joins must not both be 0", plugin.ErrInvalidConfig)
+	}
+
+	if c.CalmPeriod < c.Window {
+		return fmt.Errorf(
+			"%w: calm_period must be at least the window %s, got: %s",
+			plugin.ErrInvalidConfig,
+			c.Window,
+			c.CalmPeriod,
+		)
+	}
+
+	if c.NewUserWindow <= 0 || c.NewUserWindow > MaxNewUserWindow {
+		return fmt.Errorf(
+			"%w: new_user_window must be positive and not exceed %s, got: %s",
+			plugin.ErrInvalidConfig,
+			MaxNewUserWindow,
+			c.NewUserWindow,
+		)
+	}
+
+	if !c.Restrict.IsValid() {
+		return fmt.Errorf("%w: invalid restrict: %s", plugin.ErrInvalidConfig, c.Restrict)
+	}
+
+	return nil
+}
+
+// durationValue returns the duration string of the key parsed, defaultValue if the key is missing.
+func durationValue(config map[string]any, key string, defaultValue time.Duration) (time.Duration, error) {
+	value, ok := config[key]
+	if !ok {
+		return defaultValue, nil
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("%w: failed to parse %s: expected string, got %T", plugin.ErrInvalidConfig, key, value)
+	}
+
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to parse %s: %w", plugin.ErrInvalidConfig, key, err)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,65 @@
+package raid_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/raid"
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_NewConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]any
+		want    raid.Config
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			config:  map[string]any{},
+			want:    raid.DefaultConfig(),
+			wantErr: false,
+		},
+		{
+			name: "all fields",
+			config: map[string]any{
+				"window":          "30s",
+				"max_messages":    0,
+				"max_joins":       5,
+				"calm_period":     "10m",
+				"new_user_window": "1h",
+				"restrict":        "read_only",
+			},
+			want: raid.Config{
+				Window:        30 * time.Second,
+				MaxMessages:   0,
+				MaxJoins:      5,
+				CalmPeriod:    10 * time.Minute,
+				NewUserWindow: time.Hour,
+				Restrict:      lockdown.RestrictionReadOnly,
+			},
+			wantErr: false,
+		},
+		{name: "both thresholds disabled", config: map[string]any{"max_messages": 0, "max_joins": 0}, wantErr: true},
+		{name: "negative max_joins", config: map[string]any{"max_joins": -1}, wantErr: true},
+		{name: "short window", config: map[string]any{"window": "1s"}, wantErr: true},
+		{name: "invalid window type", config: map[string]any{"window": 60}, wantErr: true},
+		{name: "calm_period shorter than window", config: map[string]any{"calm_period": "30s"}, wantErr: true},
+		{name: "zero new_user_window", config: map[string]any{"new_user_window": "0s"}, wantErr: true},
+		{name: "invalid restrict", config: map[string]any{"restrict": "slow_mode"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := raid.NewConfig(tt.config)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package raid
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
+)
+
+// Metadata returns the plugin metadata, lockdowns are looked up in the registry shared with the bot.
+func Metadata(registry *lockdown.Registry) plugin.Metadata {
+	return plugin.Metadata{
+		Name: "raid",
+		Factory: func(params map[string]any) (plugin.Plugin, error) {
+			config, err := NewConfig(params)
+			if err != nil {
+				return nil, err
+			}
+
+			return New(config, registry), nil
+		},
+	}
+}
+
+// Plugin detects raids by the aggregate message and join rate of a chat and requests a lockdown
+// of the chat, blocking messages of newcomers until calm returns.
+type Plugin struct {
+	config   Config
+	registry *lockdown.Registry
+
+	messages map[string][]time.Time // chat state key -> times of the latest messages within the window
+	mu       sync.Mutex
+}
+
+func New(config Config, registry *lockdown.Registry) plugin.Plugin {
+	return &Plugin{
+		config:   config,
+		registry: registry,
+
+		messages: make(map[string][]time.Time),
+		mu:       sync.Mutex{},
+	}
+}
+
+func (p *Plugin) Name() string {
+	return "raid"
+}
+
+func (p *Plugin) Priority() int {
+	const priority = 3
+	return priority // Before any plugin which may stop the evaluation, every message must be counted
+}
+
+func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result, error) {
+	now := time.Now()
+
+	// The lockdown is only requested, it is started by the bot if the result is acted upon,
+	// so that shadow evaluations and messages of the moderation API never put a chat into lockdown
+	metadata := map[string]any{}
+	active, ok := p.registry.Active(msg.ChatID)
+	if reason, spike := p.spike(msg, now); spike {
+		requested := lockdown.Lockdown{
+			ChatID:        msg.ChatID,
+			Since:         now,
+			Until:         now.Add(p.config.CalmPeriod),
+			Reason:        reason,
+			Restriction:   p.config.Restrict,
+			NewUserWindow: p.config.NewUserWindow,
+		}
+		metadata[lockdown.MetadataKey] = requested
+
+		if !ok {
+			active, ok = requested, true
+		}
+	}
+
+	if !ok {
+		return skip("chat is not in lockdown", metadata), nil
+	}
+
+	joinedAt, ok := p.registry.JoinedAt(msg.ChatID, msg.UserID)
+	if !ok || now.Sub(joinedAt) > active.NewUserWindow {
+		return skip("user is not a newcomer", metadata), nil
+	}
+
+	metadata["lockdown_reason"] = active.Reason
+	metadata["lockdown_since"] = active.Since
+	metadata["joined_at"] = joinedAt
+	// The newcomer may be a legitimate user, the message is removed without punishment
+	metadata[plugin.MetadataKeyRecommendation] = plugin.RecommendationDeleteSilently
+
+	return plugin.Result{
+		Action:   plugin.ActionBlock,
+		Reason:   "Message from a newcomer during raid lockdown",
+		Metadata: metadata,
+		Plugin:   p.Name(),
+	}, nil
+}
+
+// spike counts the message and returns the description of the spike if the message or join rate
+// of the chat exceeds its limit. Edits are not counted.
+func (p *Plugin) spike(msg plugin.Message, now time.Time) (string, bool) {
+	since := now.Add(-p.config.Window)
+
+	if p.config.MaxMessages > 0 && !msg.IsEdit {
+		if count := p.record(msg.StateKey(strconv.FormatInt(msg.ChatID, 10)), now, since); count > p.config.MaxMessages {
+			return fmt.Sprintf("%d messages within %s", count, p.config.Window), true
+		}
+	}
+
+	if p.config.MaxJoins > 0 {
+		if joins := p.registry.Joins(msg.ChatID, since); joins > p.config.MaxJoins {
+			return fmt.Sprintf("%d joins within %s", joins, p.config.Window), true
+		}
+	}
+
+	return "", false
+}
+
+// record adds the message to the log of the chat and returns the number of messages since the time,
+// counting up to one above the limit.
+func (p *Plugin) record(key string, now, since time.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	times := append(trim(p.messages[key], since), now)
+	if len(times) > p.config.MaxMessages+1 {
+		times = times[len(times)-p.config.MaxMessages-1:]
+	}
+	p.messages[key] = times
+
+	return len(times)
+}
+
+func (p *Plugin) Cleanup(_ context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	since := time.Now().Add(-p.config.Window)
+	for key, times := range p.messages {
+		if times = trim(times, since); len(times) == 0 {
+			delete(p.messages, key)
+		} else {
+			p.messages[key] = times
+		}
+	}
+}
+
+// trim removes the times before since from the sorted times.
+func trim(times []time.Time, since time.Time) []time.Time {
+	for i, t := range times {
+		if t.After(since) {
+			return times[i:]
+		}
+	}
+
+	return times[:0]
+}
+
+// skip returns a skip result with the metadata, nil if it is empty.
+func skip(reason string, metadata map[string]any) plugin.Result {
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+
+	return plugin.Result{
+		Action:   plugin.ActionSkip,
+		Reason:   reason,
+		Metadata: metadata,
+		Plugin:   "raid",
+	}
+}
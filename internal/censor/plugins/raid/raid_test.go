@@ -0,0 +1,111 @@
+package raid_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/raid"
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
+	"github.com/stretchr/testify/require"
+)
+
+func evaluate(t *testing.T, p plugin.Plugin, msg plugin.Message) plugin.Result {
+	t.Helper()
+
+	result, err := p.Evaluate(context.Background(), msg)
+	require.NoError(t, err)
+	return result
+}
+
+// requested returns the lockdown requested with the result.
+func requested(result plugin.Result) (lockdown.Lockdown, bool) {
+	l, ok := result.Metadata[lockdown.MetadataKey].(lockdown.Lockdown)
+	return l, ok
+}
+
+func newConfig() raid.Config {
+	config := raid.DefaultConfig()
+	config.MaxMessages = 3
+	config.MaxJoins = 2
+	return config
+}
+
+func TestPlugin_MessageSpike(t *testing.T) {
+	registry := lockdown.NewRegistry()
+	p := raid.New(newConfig(), registry)
+
+	// Each user posts once, so a per-user limit never triggers
+	registry.Join(1, 100)
+	for userID := range int64(3) {
+		result := evaluate(t, p, plugin.Message{ChatID: 1, UserID: userID})
+		require.Equal(t, plugin.ActionSkip, result.Action)
+		_, ok := requested(result)
+		require.False(t, ok)
+	}
+
+	// The message exceeding the limit requests the lockdown and is blocked as the sender joined recently
+	result := evaluate(t, p, plugin.Message{ChatID: 1, UserID: 100})
+	require.Equal(t, plugin.ActionBlock, result.Action)
+	require.Equal(t, plugin.RecommendationDeleteSilently, result.Recommendation())
+
+	l, ok := requested(result)
+	require.True(t, ok)
+	require.Equal(t, "4 messages within 1m0s", l.Reason)
+
+	// The lockdown is started by the bot
+	_, ok = registry.Active(1)
+	require.False(t, ok)
+	require.True(t, registry.Start(l))
+
+	// Members who did not join recently can write, other chats are not affected
+	require.Equal(t, plugin.ActionSkip, evaluate(t, p, plugin.Message{ChatID: 1, UserID: 1}).Action)
+	registry.Join(2, 100)
+	require.Equal(t, plugin.ActionSkip, evaluate(t, p, plugin.Message{ChatID: 2, UserID: 100}).Action)
+}
+
+func TestPlugin_JoinSpike(t *testing.T) {
+	registry := lockdown.NewRegistry()
+	p := raid.New(newConfig(), registry)
+
+	registry.Join(1, 100, 101)
+	require.Equal(t, plugin.ActionSkip, evaluate(t, p, plugin.Message{ChatID: 1, UserID: 100}).Action)
+
+	registry.Join(1, 102)
+	result := evaluate(t, p, plugin.Message{ChatID: 1, UserID: 101})
+	require.Equal(t, plugin.ActionBlock, result.Action)
+
+	l, ok := requested(result)
+	require.True(t, ok)
+	require.Equal(t, "3 joins within 1m0s", l.Reason)
+	require.Equal(t, raid.DefaultCalmPeriod, l.Until.Sub(l.Since))
+}
+
+func TestPlugin_EditsNotCounted(t *testing.T) {
+	registry := lockdown.NewRegistry()
+	config := newConfig()
+	config.MaxJoins = 0
+	p := raid.New(config, registry)
+
+	for range 10 {
+		_, ok := requested(evaluate(t, p, plugin.Message{ChatID: 1, UserID: 1, IsEdit: true}))
+		require.False(t, ok)
+	}
+}
+
+func TestPlugin_APIMessagesCountedSeparately(t *testing.T) {
+	registry := lockdown.NewRegistry()
+	config := newConfig()
+	config.MaxJoins = 0
+	p := raid.New(config, registry)
+
+	for userID := range int64(3) {
+		evaluate(t, p, plugin.Message{ChatID: 1, UserID: userID, Source: plugin.SourceAPI})
+	}
+
+	// Messages of the API are not counted towards the message rate of the Telegram chat
+	for userID := range int64(3) {
+		_, ok := requested(evaluate(t, p, plugin.Message{ChatID: 1, UserID: userID}))
+		require.False(t, ok)
+	}
+}
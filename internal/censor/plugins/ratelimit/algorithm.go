@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Algorithm is the way messages are counted against the limit.
+type Algorithm string
+
+const (
+	// AlgorithmFixedWindow counts messages in consecutive windows starting with the first message,
+	// allowing up to twice the limit across a window boundary. Also used when the algorithm is empty.
+	AlgorithmFixedWindow Algorithm = "fixed_window"
+	// AlgorithmSlidingLog remembers the times of the latest messages and counts those within the last window.
+	AlgorithmSlidingLog Algorithm = "sliding_log"
+	// AlgorithmSlidingWindow estimates the count within the last window from the counters
+	// of the current and the previous fixed windows, weighted by their overlap with it.
+	AlgorithmSlidingWindow Algorithm = "sliding_window"
+	// AlgorithmTokenBucket allows bursts of up to Burst messages, refilled at MaxMessages per window.
+	AlgorithmTokenBucket Algorithm = "token_bucket"
+)
+
+// tokenScale is the fixed-point scale of tokens kept in the state of a token bucket.
+const tokenScale = 1_000_000
+
+// Limit is the number of messages allowed within a window.
+type Limit struct {
+	MaxMessages int
+	Window      time.Duration
+	Burst       int // capacity of a token bucket
+}
+
+// Decision is the outcome of counting a message against a limit.
+type Decision struct {
+	Allowed bool
+	Count   int // messages counted within the window, tokens used for a token bucket
+	Limit   int // maximum count, the capacity for a token bucket
+}
+
+// step counts the message sent at now against the limit and returns the new state of the key,
+// the state is nil for a key without messages.
+func (a Algorithm) step(state []int64, now time.Time, limit Limit) ([]int64, Decision) {
+	switch a {
+	case AlgorithmSlidingLog:
+		return slidingLog(state, now, limit)
+	case AlgorithmSlidingWindow:
+		return slidingWindow(state, now, limit)
+	case AlgorithmTokenBucket:
+		return tokenBucket(state, now, limit)
+	case AlgorithmFixedWindow:
+	}
+
+	return fixedWindow(state, now, limit)
+}
+
+// ttl returns how long the state of a key is needed after its last message.
+func (a Algorithm) ttl(limit Limit) time.Duration {
+	switch a {
+	case AlgorithmSlidingWindow:
+		// The previous window is needed for the estimate
+		return 2 * limit.Window //nolint:mnd // current and previous windows
+	case AlgorithmTokenBucket:
+		// The bucket is full again after this time
+		refill := time.Duration(float64(limit.Window) * float64(limit.Burst) / float64(limit.MaxMessages))
+		return max(limit.Window, refill)
+	case AlgorithmFixedWindow, AlgorithmSlidingLog:
+	}
+
+	return limit.Window
+}
+
+// fixedWindow keeps the start of the window and the count: [start, count].
+func fixedWindow(state []int64, now time.Time, limit Limit) ([]int64, Decision) {
+	const fields = 2
+
+	if len(state) != fields || now.Sub(time.Unix(0, state[0])) >= limit.Window {
+		state = []int64{now.UnixNano(), 0}
+	}
+	state[1]++
+
+	count := int(state[1])
+	return state, Decision{Allowed: count <= limit.MaxMessages, Count: count, Limit: limit.MaxMessages}
+}
+
+// slidingLog keeps the times of up to MaxMessages+1 latest messages within the window, which is enough
+// to tell whether the limit is exceeded.
+func slidingLog(state []int64, now time.Time, limit Limit) ([]int64, Decision) {
+	since := now.Add(-limit.Window).UnixNano()
+
+	log := make([]int64, 0, min(len(state)+1, limit.MaxMessages+1))
+	for _, t := range state {
+		if t > since {
+			log = append(log, t)
+		}
+	}
+	log = append(log, now.UnixNano())
+	if len(log) > limit.MaxMessages+1 {
+		log = log[len(log)-limit.MaxMessages-1:]
+	}
+
+	count := len(log)
+	return log, Decision{Allowed: count <= limit.MaxMessages, Count: count, Limit: limit.MaxMessages}
+}
+
+// slidingWindow keeps the start of the current window and the counts of the current and the previous
+// windows: [start, current, previous].
+func slidingWindow(state []int64, now time.Time, limit Limit) ([]int64, Decision) {
+	const fields = 3
+
+	if len(state) != fields {
+		state = []int64{now.UnixNano(), 0, 0}
+	}
+
+	if elapsed := now.Sub(time.Unix(0, state[0])); elapsed >= limit.Window {
+		windows := int64(elapsed / limit.Window)
+		state[0] += windows * int64(limit.Window)
+		if windows == 1 {
+			state[2] = state[1]
+		} else {
+			state[2] = 0
+		}
+		state[1] = 0
+	}
+	state[1]++
+
+	// The part of the previous window still within the last window
+	overlap := 1 - float64(now.Sub(time.Unix(0, state[0])))/float64(limit.Window)
+	estimate := float64(state[2])*overlap + float64(state[1])
+
+	return state, Decision{
+		Allowed: estimate <= float64(limit.MaxMessages),
+		Count:   int(math.Ceil(estimate)),
+		Limit:   limit.MaxMessages,
+	}
+}
+
+// tokenBucket keeps the time of the last refill and the tokens left: [refilled, tokens * tokenScale].
+// Blocked messages do not take tokens.
+func tokenBucket(state []int64, now time.Time, limit Limit) ([]int64, Decision) {
+	const fields = 2
+
+	capacity := int64(limit.Burst) * tokenScale
+	if len(state) != fields {
+		state = []int64{now.UnixNano(), capacity}
+	}
+
+	elapsed := now.Sub(time.Unix(0, state[0]))
+	refill := float64(elapsed) / float64(limit.Window) * float64(limit.MaxMessages) * tokenScale
+	state[0] = now.UnixNano()
+	state[1] = min(capacity, state[1]+int64(refill))
+
+	allowed := state[1] >= tokenScale
+	if allowed {
+		state[1] -= tokenScale
+	}
+
+	used := limit.Burst - int(state[1]/tokenScale)
+	if !allowed {
+		// The blocked message is counted above the capacity
+		used++
+	}
+
+	return state, Decision{Allowed: allowed, Count: used, Limit: limit.Burst}
+}
@@ -2,6 +2,7 @@ package ratelimit
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
@@ -12,15 +13,74 @@ const (
 	DefaultWindow      = time.Minute
 )
 
+// Scope is the way messages are grouped for counting.
+type Scope string
+
+const (
+	ScopeUser     Scope = "user"      // per sender across all chats, also used when the scope is empty
+	ScopeUserChat Scope = "user+chat" // per sender in each chat
+	ScopeChat     Scope = "chat"      // per chat regardless of the sender
+)
+
+// Key returns the key messages sent by the user to the chat are counted under.
+func (s Scope) Key(chatID, userID int64) string {
+	switch s {
+	case ScopeUserChat:
+		return strconv.FormatInt(chatID, 10) + ":user:" + strconv.FormatInt(userID, 10)
+	case ScopeChat:
+		return "chat:" + strconv.FormatInt(chatID, 10)
+	case ScopeUser:
+	}
+
+	return strconv.FormatInt(userID, 10)
+}
+
 type Config struct {
+	Algorithm   Algorithm
+	Scope       Scope
 	MaxMessages int
 	Window      time.Duration
+	Burst       int // token bucket capacity, MaxMessages if zero
+
+	// Separate limit of messages with media within the same window, media messages are counted
+	// with text messages if MediaMaxMessages is zero
+	MediaMaxMessages int
+	MediaBurst       int // token bucket capacity for media, MediaMaxMessages if zero
 }
 
 func NewConfig(config map[string]any) (Config, error) {
 	c := Config{
-		MaxMessages: DefaultMaxMessages,
-		Window:      DefaultWindow,
+		Algorithm:        AlgorithmFixedWindow,
+		Scope:            ScopeUser,
+		MaxMessages:      DefaultMaxMessages,
+		Window:           DefaultWindow,
+		Burst:            0,
+		MediaMaxMessages: 0,
+		MediaBurst:       0,
+	}
+
+	if algorithm, ok := config["algorithm"]; ok {
+		str, strOk := algorithm.(string)
+		if !strOk {
+			return Config{}, fmt.Errorf(
+				"%w: failed to parse algorithm: expected string, got %T",
+				plugin.ErrInvalidConfig,
+				algorithm,
+			)
+		}
+		c.Algorithm = Algorithm(str)
+	}
+
+	if scope, ok := config["scope"]; ok {
+		str, strOk := scope.(string)
+		if !strOk {
+			return Config{}, fmt.Errorf(
+				"%w: failed to parse scope: expected string, got %T",
+				plugin.ErrInvalidConfig,
+				scope,
+			)
+		}
+		c.Scope = Scope(str)
 	}
 
 	if maxMessages, ok := config["max_messages"]; ok {
@@ -49,5 +109,81 @@ func NewConfig(config map[string]any) (Config, error) {
 		}
 	}
 
+	var err error
+	if c.Burst, err = plugin.ConfigValue(config, "burst", c.Burst); err != nil {
+		return Config{}, err
+	}
+
+	if c.MediaMaxMessages, err = plugin.ConfigValue(config, "media_max_messages", c.MediaMaxMessages); err != nil {
+		return Config{}, err
+	}
+
+	if c.MediaBurst, err = plugin.ConfigValue(config, "media_burst", c.MediaBurst); err != nil {
+		return Config{}, err
+	}
+
+	if err = c.Validate(); err != nil {
+		return Config{}, err
+	}
+
 	return c, nil
 }
+
+// Validate checks if the configuration values are valid.
+func (c Config) Validate() error {
+	switch c.Algorithm {
+	case "", AlgorithmFixedWindow, AlgorithmSlidingLog, AlgorithmSlidingWindow, AlgorithmTokenBucket:
+	default:
+		return fmt.Errorf("%w: invalid algorithm: %s", plugin.ErrInvalidConfig, c.Algorithm)
+	}
+
+	switch c.Scope {
+	case "", ScopeUser, ScopeUserChat, ScopeChat:
+	default:
+		return fmt.Errorf("%w: invalid scope: %s", plugin.ErrInvalidConfig, c.Scope)
+	}
+
+	if c.MaxMessages < 1 {
+		return fmt.Errorf("%w: max_messages must be at least 1, got: %d", plugin.ErrInvalidConfig, c.MaxMessages)
+	}
+
+	if c.Window <= 0 {
+		return fmt.Errorf("%w: window must be positive, got: %s", plugin.ErrInvalidConfig, c.Window)
+	}
+
+	if c.Burst < 0 || c.MediaMaxMessages < 0 || c.MediaBurst < 0 {
+		return fmt.Errorf("%w: burst, media_max_messages and media_burst must be >= 0", plugin.ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// TextLimit returns the limit of text messages, and of media messages without a separate limit.
+func (c Config) TextLimit() Limit {
+	return Limit{
+		MaxMessages: c.MaxMessages,
+		Window:      c.Window,
+		Burst:       orDefault(c.Burst, c.MaxMessages),
+	}
+}
+
+// MediaLimit returns the separate limit of media messages, false if media messages are counted with text.
+func (c Config) MediaLimit() (Limit, bool) {
+	if c.MediaMaxMessages == 0 {
+		return Limit{}, false
+	}
+
+	return Limit{
+		MaxMessages: c.MediaMaxMessages,
+		Window:      c.Window,
+		Burst:       orDefault(c.MediaBurst, c.MediaMaxMessages),
+	}, true
+}
+
+func orDefault(value, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+
+	return value
+}
@@ -0,0 +1,86 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_NewConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]any
+		want    ratelimit.Config
+		wantErr bool
+	}{
+		{
+			name:   "defaults",
+			config: map[string]any{},
+			want: ratelimit.Config{
+				Algorithm:        ratelimit.AlgorithmFixedWindow,
+				Scope:            ratelimit.ScopeUser,
+				MaxMessages:      ratelimit.DefaultMaxMessages,
+				Window:           ratelimit.DefaultWindow,
+				Burst:            0,
+				MediaMaxMessages: 0,
+				MediaBurst:       0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "all fields",
+			config: map[string]any{
+				"algorithm":          "token_bucket",
+				"scope":              "user+chat",
+				"max_messages":       10,
+				"window":             "30s",
+				"burst":              20,
+				"media_max_messages": 2,
+				"media_burst":        4,
+			},
+			want: ratelimit.Config{
+				Algorithm:        ratelimit.AlgorithmTokenBucket,
+				Scope:            ratelimit.ScopeUserChat,
+				MaxMessages:      10,
+				Window:           30 * time.Second,
+				Burst:            20,
+				MediaMaxMessages: 2,
+				MediaBurst:       4,
+			},
+			wantErr: false,
+		},
+		{name: "invalid algorithm", config: map[string]any{"algorithm": "leaky_bucket"}, wantErr: true},
+		{name: "invalid scope", config: map[string]any{"scope": "global"}, wantErr: true},
+		{name: "zero max_messages", config: map[string]any{"max_messages": 0}, wantErr: true},
+		{name: "negative burst", config: map[string]any{"burst": -1}, wantErr: true},
+		{name: "invalid media_max_messages type", config: map[string]any{"media_max_messages": "2"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ratelimit.NewConfig(tt.config)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConfig_Limits(t *testing.T) {
+	config := ratelimit.Config{MaxMessages: 5, Window: time.Minute}
+
+	require.Equal(t, ratelimit.Limit{MaxMessages: 5, Window: time.Minute, Burst: 5}, config.TextLimit())
+
+	_, ok := config.MediaLimit()
+	require.False(t, ok)
+
+	config.MediaMaxMessages = 2
+	limit, ok := config.MediaLimit()
+	require.True(t, ok)
+	require.Equal(t, ratelimit.Limit{MaxMessages: 2, Window: time.Minute, Burst: 2}, limit)
+}
@@ -2,12 +2,20 @@ package ratelimit
 
 import (
 	"context"
-	"time"
+	"fmt"
 
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
 )
 
-func Metadata() plugin.Metadata {
+// Limiter counts messages against limits, messages are grouped by keys.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+	Cleanup()
+}
+
+// Metadata returns the plugin metadata, counters are shared between replicas if kv is not nil.
+func Metadata(kv storage.KV) plugin.Metadata {
 	return plugin.Metadata{
 		Name: "ratelimit",
 		Factory: func(params map[string]any) (plugin.Plugin, error) {
@@ -16,22 +24,33 @@ func Metadata() plugin.Metadata {
 				return nil, err
 			}
 
+			if kv != nil {
+				return NewShared(config, kv), nil
+			}
+
 			return New(config), nil
 		},
 	}
 }
 
 type Plugin struct {
-	maxMessages int
-	window      time.Duration
-	storage     *Storage
+	config  Config
+	storage Limiter
 }
 
+// New creates a plugin counting messages in memory.
 func New(config Config) plugin.Plugin {
 	return &Plugin{
-		maxMessages: config.MaxMessages,
-		window:      config.Window,
-		storage:     NewStorage(),
+		config:  config,
+		storage: NewStorage(config.Algorithm),
+	}
+}
+
+// NewShared creates a plugin counting messages in the shared store.
+func NewShared(config Config, kv storage.KV) plugin.Plugin {
+	return &Plugin{
+		config:  config,
+		storage: NewSharedStorage(config.Algorithm, kv),
 	}
 }
 
@@ -44,19 +63,24 @@ func (p *Plugin) Priority() int {
 	return priority // Very high priority (early execution)
 }
 
-func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result, error) {
-	count, err := p.storage.IncrementAndGet(msg.UserID, p.window)
+func (p *Plugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Result, error) {
+	key, limit, media := p.limitFor(msg)
+
+	decision, err := p.storage.Allow(ctx, key, limit)
 	if err != nil {
-		return plugin.Result{}, err
+		return plugin.Result{}, fmt.Errorf("failed to count messages: %w", err)
 	}
 
-	if count > p.maxMessages {
+	if !decision.Allowed {
 		return plugin.Result{
 			Action: plugin.ActionBlock,
 			Reason: "Rate limit exceeded",
 			Metadata: map[string]any{
-				"count": count,
-				"limit": p.maxMessages,
+				"count":     decision.Count,
+				"limit":     decision.Limit,
+				"algorithm": string(p.config.Algorithm),
+				"scope":     string(p.config.Scope),
+				"media":     media,
 			},
 			Plugin: p.Name(),
 		}, nil
@@ -70,6 +94,24 @@ func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result,
 	}, nil
 }
 
+// limitFor returns the key the message is counted under, its limit and whether the separate limit
+// of media messages applies. Messages sent on behalf of a chat are attributed to the chat.
+func (p *Plugin) limitFor(msg plugin.Message) (string, Limit, bool) {
+	userID := msg.UserID
+	if msg.SenderChatID != nil {
+		userID = *msg.SenderChatID
+	}
+	key := msg.StateKey(p.config.Scope.Key(msg.ChatID, userID))
+
+	if msg.Media != nil {
+		if limit, ok := p.config.MediaLimit(); ok {
+			return key + ":media", limit, true
+		}
+	}
+
+	return key, p.config.TextLimit(), false
+}
+
 func (p *Plugin) Cleanup(_ context.Context) {
 	p.storage.Cleanup()
 }
@@ -0,0 +1,252 @@
+package ratelimit_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugins/ratelimit"
+	"github.com/capcom6/censor-tg-bot/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// newPlugins returns the plugin counting in memory and in the shared store, and a function
+// waiting for the duration, which is also applied to the expiration of keys in the shared store.
+func newPlugins(t *testing.T, config ratelimit.Config) (map[string]plugin.Plugin, func(time.Duration)) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	s, err := storage.New(storage.Config{URL: "redis://" + mr.Addr() + "?ttl=1h"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	wait := func(d time.Duration) {
+		time.Sleep(d)
+		mr.FastForward(d)
+	}
+
+	return map[string]plugin.Plugin{
+		"memory": ratelimit.New(config),
+		"shared": ratelimit.NewShared(config, storage.NewKV(s)),
+	}, wait
+}
+
+func evaluate(t *testing.T, p plugin.Plugin, msg plugin.Message) plugin.Action {
+	t.Helper()
+
+	result, err := p.Evaluate(context.Background(), msg)
+	require.NoError(t, err)
+	return result.Action
+}
+
+func TestPlugin_WindowBoundary(t *testing.T) {
+	const window = 400 * time.Millisecond
+
+	// 1 message, 3/4 of the window later 2 messages, and 2 more right after the end of the first window
+	tests := []struct {
+		algorithm ratelimit.Algorithm
+		want      []plugin.Action
+	}{
+		{
+			algorithm: ratelimit.AlgorithmFixedWindow,
+			want:      []plugin.Action{plugin.ActionSkip, plugin.ActionSkip},
+		},
+		{
+			algorithm: ratelimit.AlgorithmSlidingLog,
+			want:      []plugin.Action{plugin.ActionSkip, plugin.ActionBlock},
+		},
+		{
+			algorithm: ratelimit.AlgorithmSlidingWindow,
+			want:      []plugin.Action{plugin.ActionBlock, plugin.ActionBlock},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.algorithm), func(t *testing.T) {
+			t.Parallel()
+
+			plugins, wait := newPlugins(t, ratelimit.Config{
+				Algorithm:   tt.algorithm,
+				Scope:       ratelimit.ScopeUser,
+				MaxMessages: 3,
+				Window:      window,
+			})
+			msg := plugin.Message{Text: "hello", ChatID: 1, UserID: 1}
+
+			for _, p := range plugins {
+				require.Equal(t, plugin.ActionSkip, evaluate(t, p, msg))
+			}
+
+			wait(window * 3 / 4)
+			for _, p := range plugins {
+				require.Equal(t, plugin.ActionSkip, evaluate(t, p, msg))
+				require.Equal(t, plugin.ActionSkip, evaluate(t, p, msg))
+			}
+
+			wait(window / 2)
+			for name, p := range plugins {
+				for i, want := range tt.want {
+					require.Equal(t, want, evaluate(t, p, msg), "%s: message %d", name, i)
+				}
+			}
+		})
+	}
+}
+
+func TestPlugin_TokenBucket(t *testing.T) {
+	const window = 400 * time.Millisecond
+
+	plugins, wait := newPlugins(t, ratelimit.Config{
+		Algorithm:   ratelimit.AlgorithmTokenBucket,
+		Scope:       ratelimit.ScopeUser,
+		MaxMessages: 2,
+		Window:      window,
+		Burst:       4,
+	})
+	msg := plugin.Message{Text: "hello", ChatID: 1, UserID: 1}
+
+	// The burst is allowed at once
+	for name, p := range plugins {
+		for range 4 {
+			require.Equal(t, plugin.ActionSkip, evaluate(t, p, msg), name)
+		}
+		require.Equal(t, plugin.ActionBlock, evaluate(t, p, msg), name)
+	}
+
+	// A token is refilled every half of the window
+	wait(window * 5 / 8)
+	for name, p := range plugins {
+		require.Equal(t, plugin.ActionSkip, evaluate(t, p, msg), name)
+		require.Equal(t, plugin.ActionBlock, evaluate(t, p, msg), name)
+	}
+}
+
+func TestPlugin_Scopes(t *testing.T) {
+	// Messages of users 1 and 2 to chats 10 and 20, in order
+	messages := []plugin.Message{
+		{Text: "hello", ChatID: 10, UserID: 1},
+		{Text: "hello", ChatID: 10, UserID: 2},
+		{Text: "hello", ChatID: 20, UserID: 1},
+		{Text: "hello", ChatID: 20, UserID: 1},
+	}
+
+	tests := []struct {
+		scope ratelimit.Scope
+		want  []plugin.Action
+	}{
+		{
+			scope: ratelimit.ScopeUser,
+			want:  []plugin.Action{plugin.ActionSkip, plugin.ActionSkip, plugin.ActionBlock, plugin.ActionBlock},
+		},
+		{
+			scope: ratelimit.ScopeUserChat,
+			want:  []plugin.Action{plugin.ActionSkip, plugin.ActionSkip, plugin.ActionSkip, plugin.ActionBlock},
+		},
+		{
+			scope: ratelimit.ScopeChat,
+			want:  []plugin.Action{plugin.ActionSkip, plugin.ActionBlock, plugin.ActionSkip, plugin.ActionBlock},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scope), func(t *testing.T) {
+			p := ratelimit.New(ratelimit.Config{
+				Algorithm:   ratelimit.AlgorithmSlidingLog,
+				Scope:       tt.scope,
+				MaxMessages: 1,
+				Window:      time.Minute,
+			})
+
+			for i, msg := range messages {
+				require.Equal(t, tt.want[i], evaluate(t, p, msg), "message %d", i)
+			}
+		})
+	}
+}
+
+func TestPlugin_MediaLimit(t *testing.T) {
+	p := ratelimit.New(ratelimit.Config{
+		Algorithm:        ratelimit.AlgorithmFixedWindow,
+		Scope:            ratelimit.ScopeUser,
+		MaxMessages:      3,
+		Window:           time.Minute,
+		MediaMaxMessages: 1,
+	})
+	text := plugin.Message{Text: "hello", ChatID: 1, UserID: 1}
+	photo := plugin.Message{ChatID: 1, UserID: 1, Media: &plugin.Media{Kind: plugin.MediaKindPhoto}}
+
+	require.Equal(t, plugin.ActionSkip, evaluate(t, p, photo))
+
+	result, err := p.Evaluate(context.Background(), photo)
+	require.NoError(t, err)
+	require.Equal(t, plugin.ActionBlock, result.Action)
+	require.Equal(t, true, result.Metadata["media"])
+	require.Equal(t, 1, result.Metadata["limit"])
+
+	// Text messages have their own limit
+	for range 3 {
+		require.Equal(t, plugin.ActionSkip, evaluate(t, p, text))
+	}
+	require.Equal(t, plugin.ActionBlock, evaluate(t, p, text))
+}
+
+func TestPlugin_SourceIsolation(t *testing.T) {
+	plugins, _ := newPlugins(t, ratelimit.Config{
+		Algorithm:   ratelimit.AlgorithmFixedWindow,
+		Scope:       ratelimit.ScopeUser,
+		MaxMessages: 1,
+		Window:      time.Minute,
+	})
+	msg := plugin.Message{Text: "hello", ChatID: 1, UserID: 1}
+	api := plugin.Message{Text: "hello", ChatID: 1, UserID: 1, Source: plugin.SourceAPI}
+
+	// Messages of the moderation API are counted separately from Telegram messages of the same user
+	for name, p := range plugins {
+		require.Equal(t, plugin.ActionSkip, evaluate(t, p, api), name)
+		require.Equal(t, plugin.ActionBlock, evaluate(t, p, api), name)
+		require.Equal(t, plugin.ActionSkip, evaluate(t, p, msg), name)
+	}
+}
+
+func TestPlugin_SharedConcurrency(t *testing.T) {
+	const replicas, messages = 2, 10
+
+	mr := miniredis.RunT(t)
+	s, err := storage.New(storage.Config{URL: "redis://" + mr.Addr() + "?ttl=1h"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	for _, algorithm := range []ratelimit.Algorithm{
+		ratelimit.AlgorithmSlidingLog,
+		ratelimit.AlgorithmSlidingWindow,
+		ratelimit.AlgorithmTokenBucket,
+	} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			config := ratelimit.Config{
+				Algorithm:   algorithm,
+				Scope:       ratelimit.ScopeUser,
+				MaxMessages: replicas * messages,
+				Window:      time.Hour,
+				Burst:       replicas * messages,
+			}
+			msg := plugin.Message{Text: "hello", ChatID: 1, UserID: 1}
+
+			// Concurrent messages of the same user on all replicas are counted
+			wg := sync.WaitGroup{}
+			for range replicas {
+				p := ratelimit.NewShared(config, storage.NewKV(s))
+				for range messages {
+					wg.Go(func() {
+						require.Equal(t, plugin.ActionSkip, evaluate(t, p, msg))
+					})
+				}
+			}
+			wg.Wait()
+
+			require.Equal(t, plugin.ActionBlock, evaluate(t, ratelimit.NewShared(config, storage.NewKV(s)), msg))
+		})
+	}
+}
@@ -1,48 +1,57 @@
 package ratelimit
 
 import (
+	"context"
+	"encoding/binary"
+	"fmt"
 	"sync"
 	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/storage"
 )
 
 // Storage implements a simple in-memory rate limiter.
 type Storage struct {
-	entries map[int64]*Entry
-	mu      sync.Mutex
+	algorithm Algorithm
+	entries   map[string]*Entry
+	mu        sync.Mutex
 }
 
 type Entry struct {
-	Count   int
-	ResetAt time.Time
+	State     []int64 // state of the algorithm
+	ExpiresAt time.Time
 }
 
-func NewStorage() *Storage {
+func NewStorage(algorithm Algorithm) *Storage {
 	return &Storage{
-		entries: make(map[int64]*Entry),
-		mu:      sync.Mutex{},
+		algorithm: algorithm,
+		entries:   make(map[string]*Entry),
+		mu:        sync.Mutex{},
 	}
 }
 
-func (s *Storage) IncrementAndGet(userID int64, window time.Duration) (int, error) {
+// Allow counts a message of the key against the limit.
+func (s *Storage) Allow(_ context.Context, key string, limit Limit) (Decision, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now()
-	entry, exists := s.entries[userID]
+	entry, exists := s.entries[key]
 
-	if !exists || now.After(entry.ResetAt) {
+	if !exists || now.After(entry.ExpiresAt) {
 		// Create new entry or reset expired entry
 		entry = &Entry{
-			Count:   1,
-			ResetAt: now.Add(window),
+			State:     nil,
+			ExpiresAt: time.Time{},
 		}
-		s.entries[userID] = entry
-		return entry.Count, nil
+		s.entries[key] = entry
 	}
 
-	// Increment existing entry
-	entry.Count++
-	return entry.Count, nil
+	var decision Decision
+	entry.State, decision = s.algorithm.step(entry.State, now, limit)
+	entry.ExpiresAt = now.Add(s.algorithm.ttl(limit))
+
+	return decision, nil
 }
 
 // Cleanup removes expired entries to prevent memory leaks.
@@ -51,9 +60,90 @@ func (s *Storage) Cleanup() {
 	defer s.mu.Unlock()
 
 	now := time.Now()
-	for userID, entry := range s.entries {
-		if now.After(entry.ResetAt) {
-			delete(s.entries, userID)
+	for key, entry := range s.entries {
+		if now.After(entry.ExpiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// SharedStorage implements a rate limiter shared between replicas.
+// The fixed window is counted by the shared store, the states of the other algorithms are updated
+// atomically, so concurrent messages of the same key on different replicas are all counted.
+type SharedStorage struct {
+	algorithm Algorithm
+	kv        storage.KV
+}
+
+func NewSharedStorage(algorithm Algorithm, kv storage.KV) *SharedStorage {
+	return &SharedStorage{
+		algorithm: algorithm,
+		kv:        kv,
+	}
+}
+
+// Allow counts a message of the key against the limit.
+func (s *SharedStorage) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	if s.algorithm == "" || s.algorithm == AlgorithmFixedWindow {
+		return s.increment(ctx, key, limit)
+	}
+
+	// Instances with different algorithms or windows must not share states
+	key = "ratelimit:" + string(s.algorithm) + ":" + limit.Window.String() + ":" + key
+
+	var decision Decision
+	if err := s.kv.Update(ctx, key, s.algorithm.ttl(limit), func(data []byte) []byte {
+		var state []int64
+		state, decision = s.algorithm.step(decodeState(data), time.Now(), limit)
+		return encodeState(state)
+	}); err != nil {
+		return Decision{}, fmt.Errorf("failed to update state: %w", err)
+	}
+
+	return decision, nil
+}
+
+// increment counts the message in the fixed window of the key.
+func (s *SharedStorage) increment(ctx context.Context, key string, limit Limit) (Decision, error) {
+	// Instances with different windows must not share counters
+	key = "ratelimit:" + limit.Window.String() + ":" + key
+
+	count, err := s.kv.Increment(ctx, key, limit.Window)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to increment counter: %w", err)
+	}
+
+	return Decision{Allowed: count <= limit.MaxMessages, Count: count, Limit: limit.MaxMessages}, nil
+}
+
+// Cleanup is a no-op, entries are expired by the shared store.
+func (s *SharedStorage) Cleanup() {}
+
+func encodeState(state []int64) []byte {
+	data := make([]byte, 0, len(state)*binary.MaxVarintLen64)
+	for _, v := range state {
+		data = binary.AppendVarint(data, v)
+	}
+
+	return data
+}
+
+// decodeState returns the state encoded in data, nil if data is malformed.
+func decodeState(data []byte) []int64 {
+	state := []int64{}
+	for len(data) > 0 {
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			return nil
 		}
+
+		state = append(state, v)
+		data = data[n:]
+	}
+
+	if len(state) == 0 {
+		return nil
 	}
+
+	return state
 }
@@ -5,6 +5,7 @@ import (
 	"regexp"
 
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/samber/lo"
 )
 
 func Metadata() plugin.Metadata {
@@ -41,12 +42,10 @@ func (p *Plugin) Priority() int {
 }
 
 func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result, error) {
-	text := msg.Text
-	if text == "" {
-		text = msg.Caption
-	}
+	// Hidden URLs are matched as well, so that URL patterns can't be evaded with text links or buttons
+	texts := lo.Compact(append([]string{msg.Content()}, msg.HiddenURLs()...))
 
-	if text == "" {
+	if len(texts) == 0 {
 		return plugin.Result{
 			Action:   plugin.ActionSkip,
 			Reason:   "empty message",
@@ -56,7 +55,7 @@ func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result,
 	}
 
 	for _, pattern := range p.patterns {
-		if pattern.MatchString(text) {
+		if lo.ContainsBy(texts, pattern.MatchString) {
 			return plugin.Result{
 				Action: plugin.ActionBlock,
 				Reason: "Message matches forbidden pattern",
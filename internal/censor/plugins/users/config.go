@@ -1,6 +1,8 @@
 package users
 
 import (
+	"fmt"
+
 	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
 )
 
@@ -8,14 +10,18 @@ import (
 type Config struct {
 	Blacklist []int
 	Whitelist []int
+
+	// BlacklistAction is the moderation action recommended for blacklisted users.
+	BlacklistAction plugin.Recommendation
 }
 
 // NewConfig parses the plugin configuration.
 func NewConfig(params map[string]any) (Config, error) {
 	var err error
 	cfg := Config{
-		Blacklist: []int{},
-		Whitelist: []int{},
+		Blacklist:       []int{},
+		Whitelist:       []int{},
+		BlacklistAction: plugin.RecommendationBanImmediately,
 	}
 
 	if cfg.Blacklist, err = plugin.SliceFromAnyOrDefault(params, "blacklist", []int{}); err != nil {
@@ -26,5 +32,14 @@ func NewConfig(params map[string]any) (Config, error) {
 		return Config{}, err //nolint:wrapcheck // no need
 	}
 
+	action, err := plugin.ConfigValue(params, "blacklist_action", string(cfg.BlacklistAction))
+	if err != nil {
+		return Config{}, err //nolint:wrapcheck // no need
+	}
+	cfg.BlacklistAction = plugin.Recommendation(action)
+	if !cfg.BlacklistAction.IsValid() {
+		return Config{}, fmt.Errorf("%w: invalid blacklist_action: %s", plugin.ErrInvalidConfig, action)
+	}
+
 	return cfg, nil
 }
@@ -63,7 +63,11 @@ func (p *Plugin) Priority() int {
 
 // Evaluate checks if the message sender is in the blacklist or whitelist.
 func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result, error) {
+	// Messages sent on behalf of a channel or an anonymous admin are listed by the chat ID
 	userID := msg.UserID
+	if msg.SenderChatID != nil {
+		userID = *msg.SenderChatID
+	}
 
 	// Check whitelist first
 	if _, ok := p.whitelist[userID]; ok {
@@ -83,7 +87,9 @@ func (p *Plugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result,
 			Action: plugin.ActionBlock,
 			Reason: "User is blacklisted",
 			Metadata: map[string]any{
-				"list": "blacklist",
+				"list":                           "blacklist",
+				plugin.MetadataKeyRecommendation: p.config.BlacklistAction,
+				plugin.MetadataKeySeverity:       plugin.SeverityCritical,
 			},
 			Plugin: p.Name(),
 		}, nil
@@ -3,6 +3,9 @@ package censor
 import (
 	"context"
 	"fmt"
+	"maps"
+	"reflect"
+	"slices"
 	"sort"
 	"sync"
 	"time"
@@ -20,11 +23,15 @@ const (
 
 	StrategySequential ExecutionStrategy = "sequential" // execute plugins in priority order, Allow takes precedence over Block
 	StrategyParallel   ExecutionStrategy = "parallel"   // execute all plugins concurrently, aggregate results
+	StrategyScoring    ExecutionStrategy = "scoring"    // execute plugins in priority order, block when the weighted score reaches the threshold
+
+	// DefaultPluginWeight is the weight of a plugin's score when none is configured.
+	DefaultPluginWeight = 1.0
 )
 
 func (s ExecutionStrategy) IsValid() bool {
 	switch s {
-	case StrategySequential, StrategyParallel:
+	case StrategySequential, StrategyParallel, StrategyScoring:
 		return true
 	default:
 		return false
@@ -33,8 +40,10 @@ func (s ExecutionStrategy) IsValid() bool {
 
 // Service orchestrates plugin execution.
 type Service struct {
-	config  Config
-	plugins []plugin.Plugin
+	config   Config
+	metadata []plugin.Metadata
+	plugins  []plugin.Plugin
+	chats    map[int64]map[string]plugin.Plugin // chat-specific instances replacing the global ones
 
 	metrics *Metrics
 	logger  *zap.Logger
@@ -43,16 +52,103 @@ type Service struct {
 }
 
 // New creates a new plugin manager.
-func New(plugins []plugin.Plugin, config Config, metrics *Metrics, logger *zap.Logger) *Service {
-	return &Service{
-		config:  config,
-		plugins: plugins,
+func New(metadata []plugin.Metadata, config Config, metrics *Metrics, logger *zap.Logger) (*Service, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Service{
+		config:   config,
+		metadata: metadata,
+		plugins:  nil,
+		chats:    nil,
 
 		metrics: metrics,
 		logger:  logger,
 
 		mu: sync.RWMutex{},
 	}
+
+	plugins, chats, err := s.buildPlugins(config)
+	if err != nil {
+		return nil, err
+	}
+
+	s.plugins = plugins
+	s.chats = chats
+
+	return s, nil
+}
+
+// Reload validates the configuration and atomically replaces the current one, rebuilding plugins.
+// Plugins whose configuration did not change keep their instances and state.
+// On error the current configuration stays in effect.
+func (s *Service) Reload(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plugins, chats, err := s.buildPlugins(config)
+	if err != nil {
+		return err
+	}
+
+	replaced := s.instances()
+
+	s.config = config
+	s.plugins = plugins
+	s.chats = chats
+
+	// Instances kept by the new configuration stay open
+	for p := range s.instances() {
+		delete(replaced, p)
+	}
+	s.closePlugins(replaced)
+
+	s.logger.Info("configuration reloaded", zap.Int("plugins", len(plugins)), zap.Int("chats", len(chats)))
+
+	return nil
+}
+
+// Close releases the resources of all plugin instances.
+func (s *Service) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closePlugins(s.instances())
+}
+
+// instances returns the set of the current global and chat-specific plugin instances,
+// the caller must hold the lock.
+func (s *Service) instances() map[plugin.Plugin]struct{} {
+	instances := make(map[plugin.Plugin]struct{}, len(s.plugins))
+	for _, p := range s.plugins {
+		instances[p] = struct{}{}
+	}
+	for _, chatPlugins := range s.chats {
+		for _, p := range chatPlugins {
+			instances[p] = struct{}{}
+		}
+	}
+
+	return instances
+}
+
+// closePlugins closes the instances holding resources, errors are logged.
+func (s *Service) closePlugins(instances map[plugin.Plugin]struct{}) {
+	for p := range instances {
+		closer, ok := p.(plugin.Closer)
+		if !ok {
+			continue
+		}
+
+		if err := closer.Close(); err != nil {
+			s.logger.Warn("error closing plugin", zap.String("plugin", p.Name()), zap.Error(err))
+		}
+	}
 }
 
 // Register adds a plugin to the manager.
@@ -77,80 +173,144 @@ func (s *Service) Register(p plugin.Plugin) error {
 	return nil
 }
 
+// GetConfig returns the current configuration.
+func (s *Service) GetConfig() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.config
+}
+
 // GetPlugins returns a copy of the current plugins list (sorted by priority).
 func (s *Service) GetPlugins() []plugin.Plugin {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	plugins := lo.Filter(
-		s.plugins,
-		func(p plugin.Plugin, _ int) bool {
-			if !s.config.EnabledOnly {
-				return true
-			}
+	return s.filterPlugins(s.config, s.plugins)
+}
 
-			if c, ok := s.config.Plugins[p.Name()]; ok {
-				return c.Enabled
-			}
+// GetChatPlugins returns the configuration and plugins list (sorted by priority) effective for the chat.
+func (s *Service) GetChatPlugins(chatID int64) (Config, []plugin.Plugin) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-			return false
-		},
-	)
+	chatPlugins, ok := s.chats[chatID]
+	if !ok {
+		return s.config, s.filterPlugins(s.config, s.plugins)
+	}
 
-	// Sort by priority (lower number = higher priority)
-	sort.Slice(plugins, func(i, j int) bool {
-		return s.getPluginPriority(plugins[i]) < s.getPluginPriority(plugins[j])
-	})
+	config := s.config.ForChat(chatID)
+	plugins := make([]plugin.Plugin, 0, len(s.plugins))
+	for _, p := range s.plugins {
+		if chatPlugin, replaced := chatPlugins[p.Name()]; replaced {
+			p = chatPlugin
+		}
+		plugins = append(plugins, p)
+	}
 
-	return plugins
+	// Plugins configured only for the chat keep their default priority unless overridden
+	for name, override := range s.config.Chats[chatID].Plugins {
+		if _, global := s.config.Plugins[name]; global || override.Priority != nil {
+			continue
+		}
+
+		if p, found := lo.Find(plugins, func(p plugin.Plugin) bool { return p.Name() == name }); found {
+			c := config.Plugins[name]
+			c.Priority = p.Priority()
+			config.Plugins[name] = c
+		}
+	}
+
+	return config, s.filterPlugins(config, plugins)
 }
 
 // Evaluate runs plugins according to the configured strategy.
 func (s *Service) Evaluate(ctx context.Context, msg plugin.Message) plugin.Result {
-	plugins := s.GetPlugins()
+	config, plugins := s.GetChatPlugins(msg.ChatID)
 
 	if len(plugins) == 0 {
 		// No plugins registered, use configured skip action
 		return plugin.Result{
-			Action:   s.config.SkipAction,
+			Action:   config.SkipAction,
 			Reason:   "no plugins registered",
 			Metadata: nil,
 			Plugin:   pluginName,
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	// Trace all plugins, and wrap shadow plugins so that their blocks are collected instead of being acted upon
+	shadow := newCollector[plugin.Result]()
+	trace := newCollector[TraceEntry]()
+	recorders := plugins
+	plugins = lo.Map(plugins, func(p plugin.Plugin, _ int) plugin.Plugin {
+		isShadow := config.Plugins[p.Name()].Shadow
+		p = &tracePlugin{Plugin: p, shadow: isShadow, collector: trace}
+		if !isShadow {
+			return p
+		}
+		return &shadowPlugin{Plugin: p, collector: shadow, logger: s.logger}
+	})
+
+	evalCtx, cancel := context.WithTimeout(ctx, config.Timeout)
 	defer cancel()
 
 	var result plugin.Result
 	var err error
-	switch s.config.Strategy {
+	switch config.Strategy {
 	case StrategySequential:
-		result, err = s.evaluateSequential(ctx, msg, plugins)
+		result, err = s.evaluateSequential(evalCtx, msg, plugins)
 	case StrategyParallel:
-		result, err = s.evaluateParallel(ctx, msg, plugins)
+		result, err = s.evaluateParallel(evalCtx, msg, plugins)
+	case StrategyScoring:
+		result, err = s.evaluateScoring(evalCtx, msg, config, plugins)
 	default:
-		err = fmt.Errorf("%w: %s", ErrInvalidStrategy, s.config.Strategy)
+		err = fmt.Errorf("%w: %s", ErrInvalidStrategy, config.Strategy)
 	}
 
 	if err != nil {
-		result.Action = s.config.ErrorAction
+		result.Action = config.ErrorAction
 		result.Reason = err.Error()
 		result.Plugin = pluginName
 	} else if result.Action == plugin.ActionSkip {
-		result.Action = s.config.SkipAction
+		result.Action = config.SkipAction
 		result.Plugin = pluginName
 	}
 
+	if shadowResults := shadow.get(); len(shadowResults) > 0 {
+		result.Metadata = lo.Assign(result.Metadata, map[string]any{MetadataKeyShadow: shadowResults})
+	}
+	result.Metadata = lo.Assign(result.Metadata, map[string]any{MetadataKeyTrace: trace.get()})
+
+	if result.Action != plugin.ActionBlock {
+		s.record(ctx, msg, recorders)
+	}
+
 	s.metrics.RecordTotalEvaluation(result)
 
 	return result
 }
 
+// record passes the message which was not blocked to the plugins keeping track of such messages.
+func (s *Service) record(ctx context.Context, msg plugin.Message, plugins []plugin.Plugin) {
+	for _, p := range plugins {
+		recorder, ok := p.(plugin.Recorder)
+		if !ok {
+			continue
+		}
+
+		if err := recorder.Record(ctx, msg); err != nil {
+			s.logger.Warn("failed to record message", zap.String("plugin", p.Name()), zap.Error(err))
+		}
+	}
+}
+
 func (s *Service) Cleanup(ctx context.Context) {
 	s.mu.RLock()
 	plugins := make([]plugin.Plugin, len(s.plugins))
 	copy(plugins, s.plugins)
+	for _, chatPlugins := range s.chats {
+		plugins = slices.AppendSeq(plugins, maps.Values(chatPlugins))
+	}
 	s.mu.RUnlock()
 
 	for _, p := range plugins {
@@ -158,14 +318,116 @@ func (s *Service) Cleanup(ctx context.Context) {
 	}
 }
 
+// buildPlugins creates global and chat-specific plugin instances for the configuration,
+// reusing the current instances whose plugin configuration did not change.
+func (s *Service) buildPlugins(config Config) ([]plugin.Plugin, map[int64]map[string]plugin.Plugin, error) {
+	build := func(m plugin.Metadata, current plugin.Plugin, currentConfig, newConfig map[string]any) (plugin.Plugin, error) {
+		if current != nil && reflect.DeepEqual(currentConfig, newConfig) {
+			return current, nil
+		}
+
+		p, err := m.Factory(newConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create plugin %s: %w", m.Name, err)
+		}
+
+		if current != nil {
+			s.logger.Info("plugin recreated", zap.String("plugin", m.Name))
+		}
+
+		return p, nil
+	}
+
+	current := lo.KeyBy(s.plugins, plugin.Plugin.Name)
+	plugins := make([]plugin.Plugin, 0, len(s.metadata))
+	for _, m := range s.metadata {
+		p, err := build(m, current[m.Name], s.config.pluginConfig(m.Name), config.pluginConfig(m.Name))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		plugins = append(plugins, p)
+		delete(current, m.Name)
+	}
+
+	// Keep plugins added with Register
+	for _, p := range s.plugins {
+		if _, ok := current[p.Name()]; ok {
+			plugins = append(plugins, p)
+		}
+	}
+
+	chats := make(map[int64]map[string]plugin.Plugin, len(config.Chats))
+	for chatID, chat := range config.Chats {
+		chatPlugins := map[string]plugin.Plugin{}
+		for _, m := range s.metadata {
+			override, ok := chat.Plugins[m.Name]
+			if !ok || override.Config == nil {
+				continue
+			}
+
+			p, err := build(
+				m,
+				s.chats[chatID][m.Name],
+				s.config.Chats[chatID].Plugins[m.Name].Config,
+				override.Config,
+			)
+			if err != nil {
+				return nil, nil, fmt.Errorf("chat %d: %w", chatID, err)
+			}
+			if scoped, isScoped := p.(plugin.ChatScoped); isScoped {
+				scoped.ScopeToChat(chatID)
+			}
+
+			chatPlugins[m.Name] = p
+		}
+		chats[chatID] = chatPlugins
+	}
+
+	return plugins, chats, nil
+}
+
+// filterPlugins returns enabled plugins sorted by priority according to the configuration.
+func (s *Service) filterPlugins(config Config, plugins []plugin.Plugin) []plugin.Plugin {
+	plugins = lo.Filter(
+		plugins,
+		func(p plugin.Plugin, _ int) bool {
+			if !config.EnabledOnly {
+				return true
+			}
+
+			if c, ok := config.Plugins[p.Name()]; ok {
+				return c.Enabled
+			}
+
+			return false
+		},
+	)
+
+	// Sort by priority (lower number = higher priority)
+	sort.Slice(plugins, func(i, j int) bool {
+		return getPluginPriority(config, plugins[i]) < getPluginPriority(config, plugins[j])
+	})
+
+	return plugins
+}
+
 // getPluginPriority returns the priority of a plugin.
-func (s *Service) getPluginPriority(p plugin.Plugin) int {
-	if c, ok := s.config.Plugins[p.Name()]; ok {
+func getPluginPriority(config Config, p plugin.Plugin) int {
+	if c, ok := config.Plugins[p.Name()]; ok {
 		return c.Priority
 	}
 	return p.Priority()
 }
 
+// getPluginWeight returns the weight of a plugin's score.
+func getPluginWeight(config Config, p plugin.Plugin) float64 {
+	if c, ok := config.Plugins[p.Name()]; ok {
+		return c.Weight
+	}
+	return DefaultPluginWeight
+}
+
 // evaluateSequential executes plugins in priority order.
 func (s *Service) evaluateSequential(
 	ctx context.Context,
@@ -314,3 +576,103 @@ func (s *Service) evaluateParallel(
 		Plugin:   pluginName,
 	}, nil
 }
+
+// evaluateScoring executes plugins in priority order and sums their weighted scores.
+func (s *Service) evaluateScoring(
+	ctx context.Context,
+	msg plugin.Message,
+	config Config,
+	plugins []plugin.Plugin,
+) (plugin.Result, error) {
+	var (
+		total     float64
+		top       plugin.Result
+		topScore  float64
+		breakdown = make(map[string]any, len(plugins))
+	)
+
+	for _, p := range plugins {
+		select {
+		case <-ctx.Done():
+			return plugin.Result{}, ErrTimeout
+		default:
+		}
+
+		start := time.Now()
+		result, err := p.Evaluate(ctx, msg)
+		duration := time.Since(start)
+
+		// Record metrics
+		s.metrics.RecordEvaluation(p.Name(), result.Action, duration, err)
+
+		if err != nil {
+			s.logger.Error("plugin evaluation error",
+				zap.String("plugin", p.Name()),
+				zap.Error(err),
+			)
+			return plugin.Result{}, fmt.Errorf("%w: %s: %w", ErrPluginError, p.Name(), err)
+		}
+
+		// Allow is final, as in the other strategies
+		if result.Action == plugin.ActionAllow {
+			s.logger.Debug("plugin allowed message", zap.String("plugin", p.Name()))
+			return result, nil
+		}
+
+		weight := getPluginWeight(config, p)
+		score := result.Score() * weight
+		total += score
+
+		s.logger.Debug("plugin scored message",
+			zap.String("plugin", p.Name()),
+			zap.String("action", string(result.Action)),
+			zap.Float64("score", score),
+		)
+
+		breakdown[p.Name()] = map[string]any{
+			"action": string(result.Action),
+			"reason": result.Reason,
+			"weight": weight,
+			"score":  score,
+		}
+
+		if score > topScore {
+			top = result
+			top.Plugin = p.Name()
+			topScore = score
+		}
+	}
+
+	metadata := map[string]any{
+		"score":     total,
+		"threshold": config.ScoreThreshold,
+		"scores":    breakdown,
+	}
+
+	if total >= config.ScoreThreshold {
+		// The top contributor decides the moderation action
+		if rec := top.Recommendation(); rec != plugin.RecommendationNone {
+			metadata[plugin.MetadataKeyRecommendation] = rec
+		}
+		if severity := top.Severity(); severity != plugin.SeverityNone {
+			metadata[plugin.MetadataKeySeverity] = severity
+		}
+		if related := top.Related(); len(related) > 0 {
+			metadata[plugin.MetadataKeyRelated] = related
+		}
+
+		return plugin.Result{
+			Action:   plugin.ActionBlock,
+			Reason:   fmt.Sprintf("score %.2f reached threshold %.2f", total, config.ScoreThreshold),
+			Metadata: metadata,
+			Plugin:   top.Plugin,
+		}, nil
+	}
+
+	return plugin.Result{
+		Action:   plugin.ActionSkip,
+		Reason:   fmt.Sprintf("score %.2f below threshold %.2f", total, config.ScoreThreshold),
+		Metadata: metadata,
+		Plugin:   pluginName,
+	}, nil
+}
@@ -0,0 +1,462 @@
+package censor_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// metrics are registered in the global Prometheus registry, so they can be created only once.
+var testMetrics = sync.OnceValue(censor.NewMetrics) //nolint:gochecknoglobals // see above
+
+type fakePlugin struct {
+	name   string
+	result plugin.Result
+	closed bool
+	chatID int64
+}
+
+// fakeMetadata returns metadata of a fake plugin, the "action" config key replaces the action of the result.
+func fakeMetadata(name string, result plugin.Result) plugin.Metadata {
+	return plugin.Metadata{
+		Name: name,
+		Factory: func(params map[string]any) (plugin.Plugin, error) {
+			if action, ok := params["action"].(string); ok {
+				result.Action = plugin.Action(action)
+			}
+			return &fakePlugin{name: name, result: result}, nil
+		},
+	}
+}
+
+func (p *fakePlugin) Name() string {
+	return p.name
+}
+
+func (p *fakePlugin) Evaluate(_ context.Context, _ plugin.Message) (plugin.Result, error) {
+	result := p.result
+	result.Plugin = p.name
+	return result, nil
+}
+
+func (p *fakePlugin) Priority() int {
+	return 0
+}
+
+func (p *fakePlugin) Cleanup(_ context.Context) {}
+
+func (p *fakePlugin) ScopeToChat(chatID int64) {
+	p.chatID = chatID
+}
+
+func (p *fakePlugin) Close() error {
+	p.closed = true
+	return nil
+}
+
+func withDefaults(config censor.Config) censor.Config {
+	config.Timeout = time.Second
+	config.EnabledOnly = true
+	config.ScoreThreshold = 1
+	config.ErrorAction = plugin.ActionBlock
+	config.SkipAction = plugin.ActionAllow
+	return config
+}
+
+func newService(
+	t *testing.T,
+	config censor.Config,
+	metadata ...plugin.Metadata,
+) *censor.Service {
+	t.Helper()
+
+	svc, err := censor.New(metadata, withDefaults(config), testMetrics(), zap.NewNop())
+	require.NoError(t, err)
+
+	return svc
+}
+
+func TestService_EvaluateScoring(t *testing.T) {
+	keyword := fakeMetadata("keyword", plugin.Result{Action: plugin.ActionBlock})
+	duplicate := fakeMetadata("duplicate", plugin.Result{Action: plugin.ActionBlock})
+	llm := fakeMetadata(
+		"llm",
+		plugin.Result{Action: plugin.ActionSkip, Metadata: map[string]any{plugin.MetadataKeyScore: 0.5}},
+	)
+	users := fakeMetadata("users", plugin.Result{Action: plugin.ActionAllow})
+
+	tests := []struct {
+		name     string
+		plugins  map[string]censor.PluginConfig
+		metadata []plugin.Metadata
+		expected plugin.Action
+		plugin   string
+		score    float64
+	}{
+		{
+			name: "single weak block is allowed",
+			plugins: map[string]censor.PluginConfig{
+				"keyword": {Enabled: true, Priority: 1, Weight: 0.4},
+			},
+			metadata: []plugin.Metadata{keyword},
+			expected: plugin.ActionAllow,
+			plugin:   "manager",
+			score:    0.4,
+		},
+		{
+			name: "combined scores block",
+			plugins: map[string]censor.PluginConfig{
+				"keyword":   {Enabled: true, Priority: 1, Weight: 0.4},
+				"duplicate": {Enabled: true, Priority: 2, Weight: 0.3},
+				"llm":       {Enabled: true, Priority: 3, Weight: 0.8},
+			},
+			metadata: []plugin.Metadata{keyword, duplicate, llm},
+			expected: plugin.ActionBlock,
+			plugin:   "keyword",
+			score:    1.1,
+		},
+		{
+			name: "allow is final",
+			plugins: map[string]censor.PluginConfig{
+				"keyword": {Enabled: true, Priority: 2, Weight: 1},
+				"users":   {Enabled: true, Priority: 1, Weight: 1},
+			},
+			metadata: []plugin.Metadata{keyword, users},
+			expected: plugin.ActionAllow,
+			plugin:   "users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newService(
+				t,
+				censor.Config{Strategy: censor.StrategyScoring, Plugins: tt.plugins},
+				tt.metadata...,
+			)
+
+			result := svc.Evaluate(context.Background(), plugin.Message{Text: "test"})
+			require.Equal(t, tt.expected, result.Action)
+			require.Equal(t, tt.plugin, result.Plugin)
+			if tt.score > 0 {
+				require.InDelta(t, tt.score, result.Metadata["score"], 0.0001)
+				require.Contains(t, result.Metadata, "scores")
+			}
+		})
+	}
+}
+
+func TestService_EvaluateScoringRecommendation(t *testing.T) {
+	svc := newService(
+		t,
+		censor.Config{
+			Strategy: censor.StrategyScoring,
+			Plugins: map[string]censor.PluginConfig{
+				"keyword": {Enabled: true, Priority: 1, Weight: 0.4},
+				"users":   {Enabled: true, Priority: 2, Weight: 1},
+			},
+		},
+		fakeMetadata("keyword", plugin.Result{Action: plugin.ActionBlock}),
+		fakeMetadata("users", plugin.Result{
+			Action: plugin.ActionBlock,
+			Metadata: map[string]any{
+				plugin.MetadataKeyRecommendation: plugin.RecommendationBanImmediately,
+				plugin.MetadataKeySeverity:       plugin.SeverityCritical,
+				plugin.MetadataKeyRelated:        []plugin.MessageRef{{ChatID: 1, UserID: 2, MessageID: 3}},
+			},
+		}),
+	)
+
+	result := svc.Evaluate(context.Background(), plugin.Message{Text: "test"})
+	require.Equal(t, plugin.ActionBlock, result.Action)
+	require.Equal(t, "users", result.Plugin)
+	require.Equal(t, plugin.RecommendationBanImmediately, result.Recommendation())
+	require.Equal(t, plugin.SeverityCritical, result.Severity())
+	require.Equal(t, []plugin.MessageRef{{ChatID: 1, UserID: 2, MessageID: 3}}, result.Related())
+}
+
+func TestService_EvaluateChatOverrides(t *testing.T) {
+	svc := newService(
+		t,
+		censor.Config{
+			Strategy: censor.StrategySequential,
+			Plugins: map[string]censor.PluginConfig{
+				"keyword": {Enabled: true, Priority: 1, Weight: 1, Config: map[string]any{}},
+			},
+			Chats: map[int64]censor.ChatConfig{
+				1: {Plugins: map[string]censor.PluginOverride{
+					"keyword": {Enabled: lo.ToPtr(false)},
+					"users":   {Enabled: lo.ToPtr(true)},
+				}},
+				2: {Plugins: map[string]censor.PluginOverride{
+					"keyword": {Config: map[string]any{"action": "skip"}},
+				}},
+			},
+		},
+		fakeMetadata("keyword", plugin.Result{Action: plugin.ActionBlock}),
+		fakeMetadata("users", plugin.Result{Action: plugin.ActionAllow}),
+	)
+
+	tests := []struct {
+		name     string
+		chatID   int64
+		expected plugin.Action
+		plugin   string
+	}{
+		{"global configuration", 0, plugin.ActionBlock, "keyword"},
+		{"plugins enabled and disabled", 1, plugin.ActionAllow, "users"},
+		{"plugin config replaced", 2, plugin.ActionAllow, "manager"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := svc.Evaluate(context.Background(), plugin.Message{Text: "test", ChatID: tt.chatID})
+			require.Equal(t, tt.expected, result.Action)
+			require.Equal(t, tt.plugin, result.Plugin)
+		})
+	}
+}
+
+func TestService_EvaluateShadow(t *testing.T) {
+	svc := newService(
+		t,
+		censor.Config{
+			Strategy: censor.StrategySequential,
+			Plugins: map[string]censor.PluginConfig{
+				"keyword": {Enabled: true, Priority: 1, Weight: 1},
+				"llm":     {Enabled: true, Priority: 2, Weight: 1, Shadow: true},
+			},
+		},
+		fakeMetadata("keyword", plugin.Result{Action: plugin.ActionSkip}),
+		fakeMetadata("llm", plugin.Result{Action: plugin.ActionBlock, Reason: "spam"}),
+	)
+
+	result := svc.Evaluate(context.Background(), plugin.Message{Text: "test"})
+	require.Equal(t, plugin.ActionAllow, result.Action)
+	require.Equal(t, "manager", result.Plugin)
+
+	shadow, ok := result.Metadata[censor.MetadataKeyShadow].([]plugin.Result)
+	require.True(t, ok)
+	require.Len(t, shadow, 1)
+	require.Equal(t, "llm", shadow[0].Plugin)
+	require.Equal(t, plugin.ActionBlock, shadow[0].Action)
+	require.Equal(t, "spam", shadow[0].Reason)
+}
+
+// recordingPlugin records the messages passed to it after the evaluation.
+type recordingPlugin struct {
+	fakePlugin
+
+	recorded []plugin.Message
+}
+
+func (p *recordingPlugin) Record(_ context.Context, msg plugin.Message) error {
+	p.recorded = append(p.recorded, msg)
+	return nil
+}
+
+func TestService_EvaluateRecord(t *testing.T) {
+	recorder := &recordingPlugin{
+		fakePlugin: fakePlugin{name: "links", result: plugin.Result{Action: plugin.ActionSkip}},
+	}
+	svc := newService(
+		t,
+		censor.Config{
+			Strategy: censor.StrategySequential,
+			Plugins: map[string]censor.PluginConfig{
+				"keyword": {Enabled: true, Priority: 1, Weight: 1},
+				"links":   {Enabled: true, Priority: 2, Weight: 1},
+			},
+			Chats: map[int64]censor.ChatConfig{
+				2: {Plugins: map[string]censor.PluginOverride{
+					"keyword": {Config: map[string]any{"action": "skip"}},
+				}},
+			},
+		},
+		fakeMetadata("keyword", plugin.Result{Action: plugin.ActionBlock}),
+		plugin.Metadata{
+			Name:    "links",
+			Factory: func(_ map[string]any) (plugin.Plugin, error) { return recorder, nil },
+		},
+	)
+
+	// Only messages which were not blocked are recorded
+	require.Equal(t, plugin.ActionBlock, svc.Evaluate(context.Background(), plugin.Message{Text: "spam", ChatID: 1}).Action)
+	require.Equal(t, plugin.ActionAllow, svc.Evaluate(context.Background(), plugin.Message{Text: "ham", ChatID: 2}).Action)
+
+	require.Len(t, recorder.recorded, 1)
+	require.Equal(t, "ham", recorder.recorded[0].Text)
+}
+
+func TestService_EvaluateTrace(t *testing.T) {
+	svc := newService(
+		t,
+		censor.Config{
+			Strategy: censor.StrategySequential,
+			Plugins: map[string]censor.PluginConfig{
+				"keyword": {Enabled: true, Priority: 1, Weight: 1},
+				"llm":     {Enabled: true, Priority: 2, Weight: 1, Shadow: true},
+				"users":   {Enabled: true, Priority: 3, Weight: 1},
+			},
+		},
+		fakeMetadata("keyword", plugin.Result{Action: plugin.ActionSkip, Reason: "no keywords"}),
+		fakeMetadata("llm", plugin.Result{Action: plugin.ActionBlock, Reason: "spam"}),
+		fakeMetadata("users", plugin.Result{Action: plugin.ActionAllow, Reason: "whitelisted"}),
+	)
+
+	result := svc.Evaluate(context.Background(), plugin.Message{Text: "test"})
+	require.Equal(t, plugin.ActionAllow, result.Action)
+
+	trace := censor.Trace(result)
+	require.Equal(
+		t,
+		[]string{"keyword", "llm", "users"},
+		lo.Map(trace, func(e censor.TraceEntry, _ int) string { return e.Plugin }),
+	)
+	require.Equal(t, "no keywords", trace[0].Reason)
+	require.False(t, trace[0].Shadow)
+
+	// Shadow plugins are traced with their own decision
+	require.Equal(t, plugin.ActionBlock, trace[1].Action)
+	require.True(t, trace[1].Shadow)
+
+	require.Equal(t, plugin.ActionAllow, trace[2].Action)
+	require.Empty(t, trace[2].Error)
+}
+
+func TestService_Reload(t *testing.T) {
+	created := map[string]int{}
+	instances := map[string][]*fakePlugin{}
+	counting := func(m plugin.Metadata) plugin.Metadata {
+		factory := m.Factory
+		m.Factory = func(params map[string]any) (plugin.Plugin, error) {
+			created[m.Name]++
+			p, err := factory(params)
+			instances[m.Name] = append(instances[m.Name], p.(*fakePlugin))
+			return p, err
+		}
+		return m
+	}
+
+	config := withDefaults(censor.Config{
+		Strategy: censor.StrategySequential,
+		Plugins: map[string]censor.PluginConfig{
+			"keyword": {Enabled: true, Priority: 1, Weight: 1, Config: map[string]any{"action": "skip"}},
+			"users":   {Enabled: true, Priority: 2, Weight: 1, Config: map[string]any{"action": "skip"}},
+		},
+	})
+	svc := newService(
+		t,
+		config,
+		counting(fakeMetadata("keyword", plugin.Result{})),
+		counting(fakeMetadata("users", plugin.Result{})),
+	)
+	require.Equal(t, map[string]int{"keyword": 1, "users": 1}, created)
+
+	// Only the plugin with changed configuration is recreated
+	reloaded := config
+	reloaded.Plugins = map[string]censor.PluginConfig{
+		"keyword": config.Plugins["keyword"],
+		"users":   {Enabled: true, Priority: 2, Weight: 1, Config: map[string]any{"action": "block"}},
+	}
+	require.NoError(t, svc.Reload(reloaded))
+	require.Equal(t, map[string]int{"keyword": 1, "users": 2}, created)
+
+	// The replaced instance is closed, the kept and new ones are not
+	require.True(t, instances["users"][0].closed)
+	require.False(t, instances["users"][1].closed)
+	require.False(t, instances["keyword"][0].closed)
+
+	result := svc.Evaluate(context.Background(), plugin.Message{Text: "test"})
+	require.Equal(t, plugin.ActionBlock, result.Action)
+	require.Equal(t, "users", result.Plugin)
+
+	// Invalid configuration is rejected and the current one is kept
+	invalid := reloaded
+	invalid.Strategy = "unknown"
+	require.ErrorIs(t, svc.Reload(invalid), censor.ErrInvalidConfig)
+
+	result = svc.Evaluate(context.Background(), plugin.Message{Text: "test"})
+	require.Equal(t, plugin.ActionBlock, result.Action)
+
+	svc.Close()
+	require.True(t, instances["users"][1].closed)
+	require.True(t, instances["keyword"][0].closed)
+}
+
+func TestService_ChatScoped(t *testing.T) {
+	svc := newService(
+		t,
+		withDefaults(censor.Config{
+			Strategy: censor.StrategySequential,
+			Plugins: map[string]censor.PluginConfig{
+				"keyword": {Enabled: true, Priority: 1, Weight: 1, Config: map[string]any{"action": "skip"}},
+			},
+			Chats: map[int64]censor.ChatConfig{
+				1: {Plugins: map[string]censor.PluginOverride{
+					"keyword": {Config: map[string]any{"action": "block"}},
+				}},
+			},
+		}),
+		fakeMetadata("keyword", plugin.Result{}),
+	)
+
+	// Instances created for chat overrides are scoped to their chats, the global one is not
+	for chatID, expected := range map[int64]int64{1: 1, 2: 0} {
+		_, plugins := svc.GetChatPlugins(chatID)
+		require.Len(t, plugins, 1)
+		p, ok := plugins[0].(*fakePlugin)
+		require.True(t, ok)
+		require.Equal(t, expected, p.chatID)
+	}
+}
+
+func TestConfig_ForChat(t *testing.T) {
+	config := censor.Config{
+		Plugins: map[string]censor.PluginConfig{
+			"keyword": {Enabled: true, Priority: 10, Weight: 1, Config: map[string]any{"blacklist": []any{"spam"}}},
+		},
+		Chats: map[int64]censor.ChatConfig{
+			1: {Plugins: map[string]censor.PluginOverride{
+				"keyword": {Priority: lo.ToPtr(20)},
+			}},
+		},
+	}
+
+	chat := config.ForChat(1)
+	require.Equal(t, 20, chat.Plugins["keyword"].Priority)
+	require.True(t, chat.Plugins["keyword"].Enabled)
+	require.Equal(t, config.Plugins["keyword"].Config, chat.Plugins["keyword"].Config)
+	require.Equal(t, 10, config.Plugins["keyword"].Priority)
+
+	require.Equal(t, config, config.ForChat(2))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	config := censor.Config{
+		Strategy:    censor.StrategyScoring,
+		Timeout:     time.Second,
+		ErrorAction: plugin.ActionBlock,
+		SkipAction:  plugin.ActionAllow,
+	}
+	require.Error(t, config.Validate())
+
+	config.ScoreThreshold = 1
+	require.NoError(t, config.Validate())
+
+	config.Plugins = map[string]censor.PluginConfig{"keyword": {Weight: -1}}
+	require.Error(t, config.Validate())
+
+	config.Plugins = nil
+	config.Chats = map[int64]censor.ChatConfig{
+		1: {Plugins: map[string]censor.PluginOverride{"keyword": {Priority: lo.ToPtr(-1)}}},
+	}
+	require.Error(t, config.Validate())
+}
@@ -0,0 +1,70 @@
+package censor
+
+import (
+	"context"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"go.uber.org/zap"
+)
+
+// MetadataKeyShadow is the result metadata key holding the blocks of shadow plugins ([]plugin.Result).
+const MetadataKeyShadow = "shadow"
+
+// shadowPlugin runs the wrapped plugin but reports all its decisions and errors as skips,
+// blocks are logged and collected instead of being acted upon.
+type shadowPlugin struct {
+	plugin.Plugin
+
+	collector *collector[plugin.Result]
+	logger    *zap.Logger
+}
+
+func (p *shadowPlugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Result, error) {
+	result, err := p.Plugin.Evaluate(ctx, msg)
+	if err != nil {
+		p.logger.Warn("shadow plugin evaluation error",
+			zap.String("plugin", p.Name()),
+			zap.Error(err),
+		)
+		return plugin.Result{
+			Action:   plugin.ActionSkip,
+			Reason:   "shadow plugin error: " + err.Error(),
+			Metadata: nil,
+			Plugin:   p.Name(),
+		}, nil
+	}
+
+	switch result.Action {
+	case plugin.ActionSkip:
+		// Drop metadata so that shadow plugins do not contribute to the scoring strategy
+		return plugin.Result{
+			Action:   plugin.ActionSkip,
+			Reason:   result.Reason,
+			Metadata: nil,
+			Plugin:   p.Name(),
+		}, nil
+	case plugin.ActionAllow:
+		p.logger.Debug("shadow plugin allowed message", zap.String("plugin", p.Name()))
+		return plugin.Result{
+			Action:   plugin.ActionSkip,
+			Reason:   "shadow plugin allowed message: " + result.Reason,
+			Metadata: nil,
+			Plugin:   p.Name(),
+		}, nil
+	case plugin.ActionBlock:
+	}
+
+	p.logger.Info("shadow plugin blocked message",
+		zap.String("plugin", p.Name()),
+		zap.String("reason", result.Reason),
+		zap.Any("metadata", result.Metadata),
+	)
+	p.collector.add(result)
+
+	return plugin.Result{
+		Action:   plugin.ActionSkip,
+		Reason:   "shadow plugin blocked message: " + result.Reason,
+		Metadata: nil,
+		Plugin:   p.Name(),
+	}, nil
+}
@@ -0,0 +1,86 @@
+package censor
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+)
+
+// MetadataKeyTrace is the result metadata key holding the trace of the evaluation ([]TraceEntry).
+const MetadataKeyTrace = "trace"
+
+// TraceEntry is the outcome of a single plugin during an evaluation.
+type TraceEntry struct {
+	Plugin   string
+	Action   plugin.Action // empty if the plugin failed
+	Reason   string
+	Metadata map[string]any
+	Duration time.Duration
+	Error    string // evaluation error, if any
+	Shadow   bool   // the decision of the shadow plugin was not acted upon
+}
+
+// Trace returns the outcomes of all evaluated plugins in the order of completion.
+// Plugins not evaluated because of an earlier Allow or a timeout are missing.
+func Trace(result plugin.Result) []TraceEntry {
+	trace, _ := result.Metadata[MetadataKeyTrace].([]TraceEntry)
+	return trace
+}
+
+// collector gathers values during a single evaluation.
+type collector[T any] struct {
+	items []T
+	mu    sync.Mutex
+}
+
+func newCollector[T any]() *collector[T] {
+	return &collector[T]{items: nil, mu: sync.Mutex{}}
+}
+
+func (c *collector[T]) add(item T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = append(c.items, item)
+}
+
+func (c *collector[T]) get() []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Plugins of the parallel strategy may still complete after a timeout
+	return slices.Clone(c.items)
+}
+
+// tracePlugin records the outcomes of the wrapped plugin.
+type tracePlugin struct {
+	plugin.Plugin
+
+	shadow    bool
+	collector *collector[TraceEntry]
+}
+
+func (p *tracePlugin) Evaluate(ctx context.Context, msg plugin.Message) (plugin.Result, error) {
+	start := time.Now()
+	result, err := p.Plugin.Evaluate(ctx, msg)
+
+	entry := TraceEntry{
+		Plugin:   p.Name(),
+		Action:   result.Action,
+		Reason:   result.Reason,
+		Metadata: result.Metadata,
+		Duration: time.Since(start),
+		Error:    "",
+		Shadow:   p.shadow,
+	}
+	if err != nil {
+		entry.Action = ""
+		entry.Error = err.Error()
+	}
+	p.collector.add(entry)
+
+	return result, err //nolint:wrapcheck // transparent wrapper
+}
@@ -10,29 +10,79 @@ import (
 	"github.com/go-core-fx/config"
 )
 
+type escalationStep struct {
+	Violations int           `koanf:"violations"`
+	Action     string        `koanf:"action"`
+	Duration   time.Duration `koanf:"duration"`
+	Silent     bool          `koanf:"silent"`
+}
+
 type Bot struct {
-	AdminID      int64 `koanf:"admin_id"`
-	BanThreshold uint8 `koanf:"ban_threshold"`
+	AdminID      int64            `koanf:"admin_id"`
+	BanThreshold uint8            `koanf:"ban_threshold"`
+	DryRun       bool             `koanf:"dry_run"`
+	Escalation   []escalationStep `koanf:"escalation"`
+	MuteDuration time.Duration    `koanf:"mute_duration"`
+}
+
+type webhook struct {
+	URL          string `koanf:"url"`
+	Secret       string `koanf:"secret"`
+	DeleteOnStop bool   `koanf:"delete_on_stop"`
+}
+
+type retry struct {
+	MaxRetries int           `koanf:"max_retries"`
+	MaxDelay   time.Duration `koanf:"max_delay"`
+}
+
+type quota struct {
+	Global  int `koanf:"global"`
+	PerChat int `koanf:"per_chat"`
 }
 
 type telegram struct {
 	Token    string        `koanf:"token"`
 	ProxyURL string        `koanf:"proxy_url"`
 	Timeout  time.Duration `koanf:"timeout"`
+	Webhook  webhook       `koanf:"webhook"`
+
+	Workers   int `koanf:"workers"`
+	QueueSize int `koanf:"queue_size"`
+
+	Retry retry `koanf:"retry"`
+	Quota quota `koanf:"quota"`
 }
 
 type plugin struct {
 	Enabled  bool           `koanf:"enabled"`
 	Priority int            `koanf:"priority"`
+	Weight   *float64       `koanf:"weight"`
+	Shadow   bool           `koanf:"shadow"`
 	Config   map[string]any `koanf:"config"`
 }
 
+type pluginOverride struct {
+	Enabled  *bool          `koanf:"enabled"`
+	Priority *int           `koanf:"priority"`
+	Weight   *float64       `koanf:"weight"`
+	Shadow   *bool          `koanf:"shadow"`
+	Config   map[string]any `koanf:"config"`
+}
+
+type chat struct {
+	Plugins map[string]pluginOverride `koanf:"plugins"`
+}
+
 type Censor struct {
 	Strategy    censor.ExecutionStrategy `koanf:"strategy"`
 	Plugins     map[string]plugin        `koanf:"plugins"`
+	Chats       map[string]chat          `koanf:"chats"`
 	Timeout     time.Duration            `koanf:"timeout"`
 	EnabledOnly bool                     `koanf:"enabled_only"`
 
+	ScoreThreshold float64 `koanf:"score_threshold"`
+
 	ErrorAction plug.Action `koanf:"error_action"`
 	SkipAction  plug.Action `koanf:"skip_action"`
 
@@ -50,12 +100,30 @@ type http struct {
 	Proxies     []string `koanf:"proxies"`
 }
 
+type api struct {
+	Token string `koanf:"token"`
+}
+
+type auditLog struct {
+	Path      string        `koanf:"path"`
+	Retention time.Duration `koanf:"retention"`
+}
+
+type captchaConfig struct {
+	Kind    string        `koanf:"kind"`
+	Timeout time.Duration `koanf:"timeout"`
+}
+
 type Config struct {
 	Bot      Bot      `koanf:"bot"`
 	Telegram telegram `koanf:"telegram"`
 	Censor   Censor   `koanf:"censor"`
 	Storage  Storage  `koanf:"storage"`
 	HTTP     http     `koanf:"http"`
+	API      api      `koanf:"api"`
+	Audit    auditLog `koanf:"audit"`
+
+	Captcha captchaConfig `koanf:"captcha"`
 }
 
 func Default() Config {
@@ -63,16 +131,35 @@ func Default() Config {
 	return Config{
 		Bot: Bot{
 			BanThreshold: 3,
+			MuteDuration: time.Hour,
 		},
 		Telegram: telegram{
 			Token:    "",
 			ProxyURL: "",
 			Timeout:  time.Minute,
+			Webhook: webhook{
+				URL:          "",
+				Secret:       "",
+				DeleteOnStop: false,
+			},
+			Workers:   4,
+			QueueSize: 100,
+			Retry: retry{
+				MaxRetries: 3,
+				MaxDelay:   30 * time.Second,
+			},
+			Quota: quota{
+				Global:  30,
+				PerChat: 1,
+			},
 		},
 		Censor: Censor{
 			Strategy:    censor.StrategySequential,
 			Timeout:     30 * time.Second,
 			EnabledOnly: true,
+
+			ScoreThreshold: 1,
+
 			Plugins: map[string]plugin{
 				"keyword": {
 					Enabled:  true,
@@ -93,6 +180,12 @@ func Default() Config {
 			ProxyHeader: "X-Forwarded-For",
 			Proxies:     []string{},
 		},
+		Audit: auditLog{
+			Retention: 90 * 24 * time.Hour,
+		},
+		Captcha: captchaConfig{
+			Timeout: 2 * time.Minute,
+		},
 	}
 }
 
@@ -1,21 +1,30 @@
 package config
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/capcom6/censor-tg-bot/internal/audit"
 	"github.com/capcom6/censor-tg-bot/internal/bot"
+	"github.com/capcom6/censor-tg-bot/internal/captcha"
 	"github.com/capcom6/censor-tg-bot/internal/censor"
+	"github.com/capcom6/censor-tg-bot/internal/server"
 	"github.com/capcom6/censor-tg-bot/internal/storage"
 	"github.com/capcom6/censor-tg-bot/pkg/tgbotapifx"
 	"github.com/go-core-fx/fiberfx"
+	"github.com/go-core-fx/logger"
 	"github.com/samber/lo"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
 )
 
 func Module() fx.Option {
 	return fx.Module(
 		"config",
+		logger.WithNamedLogger("config"),
 		fx.Provide(New),
 		fx.Provide(func(cfg Config) tgbotapifx.Config {
 			return tgbotapifx.Config{
@@ -24,50 +33,66 @@ func Module() fx.Option {
 				Debug:           os.Getenv("DEBUG") != "",
 				ProxyURL:        cfg.Telegram.ProxyURL,
 				Timeout:         max(cfg.Telegram.Timeout, time.Minute+10*time.Second),
+				Workers:         cfg.Telegram.Workers,
+				QueueSize:       cfg.Telegram.QueueSize,
+				AllowedUpdates:  allowedUpdates(cfg),
+				Webhook: tgbotapifx.WebhookConfig{
+					URL:          cfg.Telegram.Webhook.URL,
+					Secret:       cfg.Telegram.Webhook.Secret,
+					DeleteOnStop: cfg.Telegram.Webhook.DeleteOnStop,
+				},
+				Retry: tgbotapifx.RetryConfig{
+					MaxRetries: cfg.Telegram.Retry.MaxRetries,
+					MaxDelay:   cfg.Telegram.Retry.MaxDelay,
+				},
+				Quota: tgbotapifx.QuotaConfig{
+					Global:  cfg.Telegram.Quota.Global,
+					PerChat: cfg.Telegram.Quota.PerChat,
+				},
 			}
 		}),
 		fx.Provide(func(cfg Config) bot.Config {
 			return bot.Config{
 				AdminID:      cfg.Bot.AdminID,
 				BanThreshold: cfg.Bot.BanThreshold,
-			}
-		}),
-		fx.Provide(func(cfg Config) censor.Config {
-			if len(cfg.Censor.Plugins) == 0 {
-				cfg.Censor.Plugins = map[string]plugin{
-					"keyword": {
-						Enabled:  true,
-						Priority: 1,
-						Config: map[string]any{
-							"blacklist": cfg.Censor.Blacklist,
-						},
-					},
-				}
-			}
-
-			return censor.Config{
-				Strategy:    cfg.Censor.Strategy,
-				Timeout:     cfg.Censor.Timeout,
-				EnabledOnly: cfg.Censor.EnabledOnly,
-				Plugins: lo.MapValues(
-					cfg.Censor.Plugins,
-					func(p plugin, _ string) censor.PluginConfig {
-						return censor.PluginConfig{
-							Enabled:  p.Enabled,
-							Priority: p.Priority,
-							Config:   p.Config,
+				DryRun:       cfg.Bot.DryRun,
+				Escalation: lo.Map(
+					cfg.Bot.Escalation,
+					func(s escalationStep, _ int) bot.Step {
+						return bot.Step{
+							Violations: s.Violations,
+							Action:     bot.Action(s.Action),
+							Duration:   s.Duration,
+							Silent:     s.Silent,
 						}
 					},
 				),
-				ErrorAction: cfg.Censor.ErrorAction,
-				SkipAction:  cfg.Censor.SkipAction,
+				MuteDuration: cfg.Bot.MuteDuration,
 			}
 		}),
+		fx.Provide(newCensorConfig),
 		fx.Provide(func(cfg Config) storage.Config {
 			return storage.Config{
 				URL: cfg.Storage.URL,
 			}
 		}),
+		fx.Provide(func(cfg Config) audit.Config {
+			return audit.Config{
+				Path:      cfg.Audit.Path,
+				Retention: cfg.Audit.Retention,
+			}
+		}),
+		fx.Provide(func(cfg Config) captcha.Config {
+			return captcha.Config{
+				Kind:    captcha.Kind(cfg.Captcha.Kind),
+				Timeout: cfg.Captcha.Timeout,
+			}
+		}),
+		fx.Provide(func(cfg Config) server.Config {
+			return server.Config{
+				APIToken: cfg.API.Token,
+			}
+		}),
 		fx.Provide(func(cfg Config) fiberfx.Config {
 			return fiberfx.Config{
 				Address:     cfg.HTTP.Address,
@@ -75,5 +100,112 @@ func Module() fx.Option {
 				Proxies:     cfg.HTTP.Proxies,
 			}
 		}),
+
+		// Reload the censor configuration on changes
+		fx.Provide(NewWatcher, fx.Private),
+		fx.Invoke(func(lc fx.Lifecycle, watcher *Watcher, logger *zap.Logger) {
+			ctx, cancel := context.WithCancel(context.Background())
+			waitCh := make(chan struct{})
+			lc.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					go func() {
+						defer close(waitCh)
+
+						if err := watcher.Run(ctx); err != nil {
+							logger.Error("configuration watcher failed", zap.Error(err))
+						}
+					}()
+					return nil
+				},
+				OnStop: func(ctx context.Context) error {
+					cancel()
+					select {
+					case <-waitCh:
+					case <-ctx.Done():
+					}
+					return nil
+				},
+			})
+		}),
 	)
 }
+
+// allowedUpdates returns the update types to receive, chat member updates report joins
+// without service messages to the captcha but are not delivered by default.
+func allowedUpdates(cfg Config) []string {
+	if cfg.Captcha.Kind == "" {
+		return nil
+	}
+
+	return []string{
+		"message",
+		"edited_message",
+		"channel_post",
+		"edited_channel_post",
+		"callback_query",
+		"chat_member",
+	}
+}
+
+func newCensorConfig(cfg Config) (censor.Config, error) {
+	if len(cfg.Censor.Plugins) == 0 {
+		cfg.Censor.Plugins = map[string]plugin{
+			"keyword": {
+				Enabled:  true,
+				Priority: 1,
+				Weight:   nil,
+				Shadow:   false,
+				Config: map[string]any{
+					"blacklist": cfg.Censor.Blacklist,
+				},
+			},
+		}
+	}
+
+	chats := make(map[int64]censor.ChatConfig, len(cfg.Censor.Chats))
+	for key, c := range cfg.Censor.Chats {
+		chatID, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return censor.Config{}, fmt.Errorf("invalid chat id %q: %w", key, err)
+		}
+
+		chats[chatID] = censor.ChatConfig{
+			Plugins: lo.MapValues(
+				c.Plugins,
+				func(p pluginOverride, _ string) censor.PluginOverride {
+					return censor.PluginOverride{
+						Enabled:  p.Enabled,
+						Priority: p.Priority,
+						Weight:   p.Weight,
+						Shadow:   p.Shadow,
+						Config:   p.Config,
+					}
+				},
+			),
+		}
+	}
+
+	return censor.Config{
+		Strategy:    cfg.Censor.Strategy,
+		Timeout:     cfg.Censor.Timeout,
+		EnabledOnly: cfg.Censor.EnabledOnly,
+
+		ScoreThreshold: cfg.Censor.ScoreThreshold,
+
+		Plugins: lo.MapValues(
+			cfg.Censor.Plugins,
+			func(p plugin, _ string) censor.PluginConfig {
+				return censor.PluginConfig{
+					Enabled:  p.Enabled,
+					Priority: p.Priority,
+					Weight:   lo.FromPtrOr(p.Weight, censor.DefaultPluginWeight),
+					Shadow:   p.Shadow,
+					Config:   p.Config,
+				}
+			},
+		),
+		Chats:       chats,
+		ErrorAction: cfg.Censor.ErrorAction,
+		SkipAction:  cfg.Censor.SkipAction,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// reloadDelay debounces bursts of file events produced by a single save.
+const reloadDelay = time.Second
+
+// Watcher reloads the censor configuration when the file pointed to by CONFIG_PATH
+// changes, including when a symlink in its path is switched to another target, or SIGHUP is received.
+type Watcher struct {
+	path string
+
+	censor *censor.Service
+	logger *zap.Logger
+}
+
+func NewWatcher(censor *censor.Service, logger *zap.Logger) *Watcher {
+	return &Watcher{
+		path: os.Getenv("CONFIG_PATH"),
+
+		censor: censor,
+		logger: logger,
+	}
+}
+
+// Run watches for configuration changes until the context is canceled.
+func (w *Watcher) Run(ctx context.Context) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	var (
+		events <-chan fsnotify.Event
+		errs   <-chan error
+	)
+	if w.path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to create file watcher: %w", err)
+		}
+		defer watcher.Close()
+
+		// Watch the directory, as editors and deployment tools usually replace the file instead of writing to it
+		if addErr := watcher.Add(filepath.Dir(w.path)); addErr != nil {
+			return fmt.Errorf("failed to watch %s: %w", w.path, addErr)
+		}
+
+		events = watcher.Events
+		errs = watcher.Errors
+	}
+
+	// The file may be a symlink replaced atomically, e.g. by the ..data swap of a Kubernetes ConfigMap
+	target := resolve(w.path)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-signals:
+			w.logger.Info("SIGHUP received")
+			w.reload()
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			current := resolve(w.path)
+			if filepath.Clean(event.Name) != filepath.Clean(w.path) && current == target {
+				continue
+			}
+			target = current
+			debounce = time.After(reloadDelay)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			w.logger.Warn("file watcher error", zap.Error(err))
+		case <-debounce:
+			debounce = nil
+			w.logger.Info("configuration file changed", zap.String("path", w.path))
+			w.reload()
+		}
+	}
+}
+
+// resolve returns the path with symlinks resolved, the path itself if it can not be resolved.
+func resolve(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
+	}
+
+	return resolved
+}
+
+// reload loads the configuration and applies it, keeping the current one if it is invalid.
+func (w *Watcher) reload() {
+	cfg, err := New()
+	if err != nil {
+		w.logger.Error("failed to reload configuration", zap.Error(err))
+		return
+	}
+
+	censorCfg, err := newCensorConfig(cfg)
+	if err != nil {
+		w.logger.Error("failed to reload configuration", zap.Error(err))
+		return
+	}
+
+	if reloadErr := w.censor.Reload(censorCfg); reloadErr != nil {
+		w.logger.Error("invalid configuration, keeping the current one", zap.Error(reloadErr))
+		return
+	}
+}
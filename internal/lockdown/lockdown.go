@@ -0,0 +1,232 @@
+package lockdown
+
+import (
+	"sync"
+	"time"
+)
+
+// joinRetention is how long joins are remembered, the maximum window of newcomers.
+const joinRetention = 24 * time.Hour
+
+// MetadataKey is the metadata key a plugin may use to request a lockdown (Lockdown) of the chat
+// of the evaluated message. The bot starts it unless the plugin is in shadow mode or the message
+// was allowed by a plugin.
+const MetadataKey = "lockdown"
+
+// Restriction is the restriction of chat member permissions during a lockdown.
+type Restriction string
+
+const (
+	RestrictionNone     Restriction = "none"      // permissions are kept, also used when empty
+	RestrictionTextOnly Restriction = "text_only" // members can send text messages only
+	RestrictionReadOnly Restriction = "read_only" // members can not send messages
+)
+
+func (r Restriction) IsValid() bool {
+	switch r {
+	case "", RestrictionNone, RestrictionTextOnly, RestrictionReadOnly:
+		return true
+	default:
+		return false
+	}
+}
+
+// Lockdown is a state of a chat under a raid, messages of newcomers are blocked.
+type Lockdown struct {
+	ChatID        int64
+	Since         time.Time
+	Until         time.Time     // the lockdown is lifted at this time unless extended
+	Reason        string        // spike which started the lockdown
+	Restriction   Restriction   // restriction of member permissions
+	NewUserWindow time.Duration // users who joined within this period are newcomers
+}
+
+// Event is a lockdown started or lifted.
+type Event struct {
+	Lockdown Lockdown
+	Active   bool // the lockdown was started, lifted otherwise
+}
+
+// Registry keeps recent joins and lockdowns of chats, shared by the raid plugin detecting raids
+// and the bot enforcing lockdowns.
+type Registry struct {
+	joins     map[int64]map[int64]time.Time // chat ID -> user ID -> join time
+	lockdowns map[int64]Lockdown
+	handlers  []func(Event)
+
+	// events not delivered to the handlers yet, in order, and whether they are being delivered
+	pending    []Event
+	delivering bool
+
+	mu sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		joins:     make(map[int64]map[int64]time.Time),
+		lockdowns: make(map[int64]Lockdown),
+		handlers:  nil,
+
+		pending:    nil,
+		delivering: false,
+
+		mu: sync.Mutex{},
+	}
+}
+
+// OnChange adds the handler of started and lifted lockdowns. Handlers are called asynchronously,
+// one event at a time in the order of the changes.
+func (r *Registry) OnChange(handler func(Event)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers = append(r.handlers, handler)
+}
+
+// Join records the users who joined the chat.
+func (r *Registry) Join(chatID int64, userIDs ...int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chat, ok := r.joins[chatID]
+	if !ok {
+		chat = make(map[int64]time.Time)
+		r.joins[chatID] = chat
+	}
+
+	now := time.Now()
+	for _, userID := range userIDs {
+		chat[userID] = now
+	}
+}
+
+// JoinedAt returns the time the user joined the chat, false if the join is unknown or too old.
+func (r *Registry) JoinedAt(chatID, userID int64) (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	joinedAt, ok := r.joins[chatID][userID]
+	if !ok || time.Since(joinedAt) > joinRetention {
+		return time.Time{}, false
+	}
+
+	return joinedAt, true
+}
+
+// Joins returns the number of users who joined the chat since the time.
+func (r *Registry) Joins(chatID int64, since time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for _, joinedAt := range r.joins[chatID] {
+		if joinedAt.After(since) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Start puts the chat into lockdown, or extends the current lockdown until the later time.
+// It returns true if the lockdown was started.
+func (r *Registry) Start(lockdown Lockdown) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, ok := r.lockdowns[lockdown.ChatID]; ok {
+		if lockdown.Until.After(current.Until) {
+			current.Until = lockdown.Until
+			r.lockdowns[lockdown.ChatID] = current
+		}
+
+		return false
+	}
+
+	r.lockdowns[lockdown.ChatID] = lockdown
+	r.notify(Event{Lockdown: lockdown, Active: true})
+
+	return true
+}
+
+// Active returns the lockdown of the chat, false if the chat is not in lockdown.
+func (r *Registry) Active(chatID int64) (Lockdown, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lockdown, ok := r.lockdowns[chatID]
+	return lockdown, ok
+}
+
+// Lift lifts the lockdown of the chat, it returns false if the chat is not in lockdown.
+func (r *Registry) Lift(chatID int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lockdown, ok := r.lockdowns[chatID]
+	if !ok {
+		return false
+	}
+
+	delete(r.lockdowns, chatID)
+	r.notify(Event{Lockdown: lockdown, Active: false})
+
+	return true
+}
+
+// Expire lifts the lockdowns which were not extended in time and removes old joins.
+func (r *Registry) Expire() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for chatID, lockdown := range r.lockdowns {
+		if now.After(lockdown.Until) {
+			delete(r.lockdowns, chatID)
+			r.notify(Event{Lockdown: lockdown, Active: false})
+		}
+	}
+
+	for chatID, chat := range r.joins {
+		for userID, joinedAt := range chat {
+			if now.Sub(joinedAt) > joinRetention {
+				delete(chat, userID)
+			}
+		}
+		if len(chat) == 0 {
+			delete(r.joins, chatID)
+		}
+	}
+}
+
+// notify queues the event for the handlers, the caller must hold the lock.
+func (r *Registry) notify(event Event) {
+	r.pending = append(r.pending, event)
+	if r.delivering {
+		return
+	}
+
+	r.delivering = true
+	go r.deliver()
+}
+
+// deliver calls the handlers with the queued events until the queue is empty.
+func (r *Registry) deliver() {
+	for {
+		r.mu.Lock()
+		if len(r.pending) == 0 {
+			r.pending = nil
+			r.delivering = false
+			r.mu.Unlock()
+			return
+		}
+		event := r.pending[0]
+		r.pending = r.pending[1:]
+		handlers := r.handlers
+		r.mu.Unlock()
+
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package lockdown_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Joins(t *testing.T) {
+	r := lockdown.NewRegistry()
+	before := time.Now()
+
+	r.Join(1, 10, 11)
+	r.Join(2, 10)
+
+	require.Equal(t, 2, r.Joins(1, before.Add(-time.Second)))
+	require.Equal(t, 1, r.Joins(2, before.Add(-time.Second)))
+	require.Equal(t, 0, r.Joins(1, time.Now().Add(time.Second)))
+
+	joinedAt, ok := r.JoinedAt(1, 11)
+	require.True(t, ok)
+	require.False(t, joinedAt.Before(before))
+
+	_, ok = r.JoinedAt(2, 11)
+	require.False(t, ok)
+}
+
+func TestRegistry_Lockdown(t *testing.T) {
+	r := lockdown.NewRegistry()
+	events := make(chan lockdown.Event, 2)
+	r.OnChange(func(e lockdown.Event) { events <- e })
+
+	now := time.Now()
+	l := lockdown.Lockdown{
+		ChatID:        1,
+		Since:         now,
+		Until:         now.Add(100 * time.Millisecond),
+		Reason:        "spike",
+		Restriction:   lockdown.RestrictionNone,
+		NewUserWindow: time.Minute,
+	}
+
+	require.True(t, r.Start(l))
+	require.True(t, (<-events).Active)
+
+	// A spike during the lockdown extends it
+	extended := l
+	extended.Until = now.Add(300 * time.Millisecond)
+	require.False(t, r.Start(extended))
+
+	active, ok := r.Active(1)
+	require.True(t, ok)
+	require.Equal(t, extended.Until, active.Until)
+	require.Equal(t, l.Since, active.Since)
+
+	time.Sleep(200 * time.Millisecond)
+	r.Expire()
+	_, ok = r.Active(1)
+	require.True(t, ok)
+
+	time.Sleep(150 * time.Millisecond)
+	r.Expire()
+	_, ok = r.Active(1)
+	require.False(t, ok)
+
+	event := <-events
+	require.False(t, event.Active)
+	require.Equal(t, int64(1), event.Lockdown.ChatID)
+}
+
+func TestRegistry_EventsInOrder(t *testing.T) {
+	r := lockdown.NewRegistry()
+	events := make(chan lockdown.Event, 3)
+	r.OnChange(func(e lockdown.Event) {
+		// A slow handler must not let later events overtake earlier ones
+		time.Sleep(10 * time.Millisecond)
+		events <- e
+	})
+
+	l := lockdown.Lockdown{
+		ChatID:        1,
+		Since:         time.Now(),
+		Until:         time.Now().Add(time.Minute),
+		Reason:        "spike",
+		Restriction:   lockdown.RestrictionReadOnly,
+		NewUserWindow: time.Minute,
+	}
+
+	require.True(t, r.Start(l))
+	require.True(t, r.Lift(1))
+	require.True(t, r.Start(l))
+
+	for _, active := range []bool{true, false, true} {
+		require.Equal(t, active, (<-events).Active)
+	}
+}
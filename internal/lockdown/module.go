@@ -0,0 +1,14 @@
+package lockdown
+
+import (
+	"github.com/go-core-fx/logger"
+	"go.uber.org/fx"
+)
+
+func Module() fx.Option {
+	return fx.Module(
+		"lockdown",
+		logger.WithNamedLogger("lockdown"),
+		fx.Provide(NewRegistry),
+	)
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newBearerAuth returns a middleware rejecting requests without the matching bearer token.
+func newBearerAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		value, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(value), []byte(token)) != 1 {
+			return fiber.ErrUnauthorized
+		}
+
+		return c.Next()
+	}
+}
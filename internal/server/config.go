@@ -0,0 +1,5 @@
+package server
+
+type Config struct {
+	APIToken string // bearer token required by the moderation API, the API is disabled when empty
+}
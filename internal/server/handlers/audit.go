@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/capcom6/censor-tg-bot/internal/audit"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+
+	exportFormatJSONL = "jsonl"
+	exportFormatCSV   = "csv"
+)
+
+//nolint:gochecknoglobals // CSV header
+var auditCSVHeader = []string{
+	"id", "time", "chat_id", "user_id", "message_id", "plugin", "reason",
+	"action", "dry_run", "error", "text", "metadata",
+}
+
+// AuditHandler searches and exports the moderation audit log.
+type AuditHandler struct {
+	audit  *audit.Log
+	logger *zap.Logger
+}
+
+func NewAuditHandler(audit *audit.Log, logger *zap.Logger) *AuditHandler {
+	return &AuditHandler{
+		audit:  audit,
+		logger: logger,
+	}
+}
+
+func (h *AuditHandler) Register(router fiber.Router) {
+	router.Get("", h.list)
+	router.Get("/export", h.export)
+}
+
+// list returns the latest entries matching the query, newest first.
+func (h *AuditHandler) list(c *fiber.Ctx) error {
+	filter, err := parseAuditQuery(c)
+	if err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit", auditDefaultLimit)
+	if limit <= 0 || limit > auditMaxLimit {
+		return fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(auditMaxLimit))
+	}
+	filter.Limit = limit
+
+	entries, err := h.audit.Find(filter)
+	if err != nil {
+		return err //nolint:wrapcheck // handled by the error handler
+	}
+
+	return c.JSON(AuditResponse{Entries: entries})
+}
+
+// export streams all entries matching the query, oldest first, as JSON Lines or CSV.
+func (h *AuditHandler) export(c *fiber.Ctx) error {
+	filter, err := parseAuditQuery(c)
+	if err != nil {
+		return err
+	}
+
+	format := c.Query("format", exportFormatJSONL)
+
+	var write func(w *bufio.Writer) error
+	switch format {
+	case exportFormatJSONL:
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+		write = func(w *bufio.Writer) error {
+			enc := json.NewEncoder(w)
+			return h.audit.Export(filter, func(e audit.Entry) error {
+				return enc.Encode(e) //nolint:wrapcheck // returned as is
+			})
+		}
+	case exportFormatCSV:
+		c.Set(fiber.HeaderContentType, "text/csv")
+		write = func(w *bufio.Writer) error {
+			return writeAuditCSV(w, filter, h.audit)
+		}
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "format must be jsonl or csv")
+	}
+
+	c.Attachment("audit." + format)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if writeErr := write(w); writeErr != nil {
+			// The status is already sent, the export is truncated
+			h.logger.Error("error exporting audit log", zap.Error(writeErr))
+		}
+		_ = w.Flush()
+	})
+
+	return nil
+}
+
+func writeAuditCSV(w *bufio.Writer, filter audit.Filter, log *audit.Log) error {
+	csvw := csv.NewWriter(w)
+	if err := csvw.Write(auditCSVHeader); err != nil {
+		return err //nolint:wrapcheck // returned as is
+	}
+
+	if err := log.Export(filter, func(e audit.Entry) error {
+		metadata, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return err //nolint:wrapcheck // returned as is
+		}
+
+		return csvw.Write([]string{ //nolint:wrapcheck // returned as is
+			strconv.FormatUint(e.ID, 10),
+			e.Time.Format(timeFormat),
+			strconv.FormatInt(e.ChatID, 10),
+			strconv.FormatInt(e.UserID, 10),
+			strconv.Itoa(e.MessageID),
+			csvText(e.Plugin),
+			csvText(e.Reason),
+			csvText(e.Action),
+			strconv.FormatBool(e.DryRun),
+			csvText(e.Error),
+			csvText(e.Text),
+			csvText(string(metadata)),
+		})
+	}); err != nil {
+		return err //nolint:wrapcheck // returned as is
+	}
+
+	csvw.Flush()
+	return csvw.Error() //nolint:wrapcheck // returned as is
+}
+
+// csvText escapes the text so that spreadsheet applications do not evaluate it as a formula:
+// texts starting with =, +, -, @, tab or carriage return are prefixed with a single quote.
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+
+	return text
+}
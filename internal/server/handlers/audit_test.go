@@ -0,0 +1,62 @@
+package handlers_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/capcom6/censor-tg-bot/internal/audit"
+	"github.com/capcom6/censor-tg-bot/internal/server/handlers"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAuditHandler(t *testing.T) {
+	log, err := audit.New(audit.Config{Path: filepath.Join(t.TempDir(), "audit.db"), Retention: 0})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = log.Close() })
+
+	require.NoError(t, log.Record(audit.Entry{ChatID: 1, UserID: 10, Plugin: "keyword", Text: "spam, \"quoted\""}))
+	require.NoError(t, log.Record(audit.Entry{ChatID: 2, UserID: 20, Plugin: "llm", Text: "scam"}))
+	require.NoError(t, log.Record(audit.Entry{ChatID: 3, UserID: 30, Plugin: "regex", Text: "=HYPERLINK(\"x\")"}))
+
+	app := fiber.New()
+	handlers.NewAuditHandler(log, zap.NewNop()).Register(app.Group("/audit"))
+
+	get := func(target string) *http.Response {
+		resp, testErr := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		require.NoError(t, testErr)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+
+		return resp
+	}
+
+	resp := get("/audit?plugin=llm")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var page handlers.AuditResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.Len(t, page.Entries, 1)
+	require.Equal(t, int64(20), page.Entries[0].UserID)
+
+	resp = get("/audit/export?format=csv&chat_id=1")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, "spam, \"quoted\"", records[1][10])
+
+	// Texts are not evaluated as formulas by spreadsheet applications, numbers are kept as is
+	resp = get("/audit/export?format=csv&chat_id=3")
+	records, err = csv.NewReader(resp.Body).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, "'=HYPERLINK(\"x\")", records[1][10])
+
+	require.Equal(t, http.StatusBadRequest, get("/audit?from=yesterday").StatusCode)
+	require.Equal(t, http.StatusBadRequest, get("/audit/export?format=xml").StatusCode)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/audit"
+	"github.com/capcom6/censor-tg-bot/internal/censor"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+)
+
+// MessageRequest mirrors plugin.Message.
+type MessageRequest struct {
+	Text                string `json:"text"`
+	Caption             string `json:"caption"`
+	UserID              int64  `json:"user_id"`
+	ChatID              int64  `json:"chat_id"`
+	MessageID           int    `json:"message_id"`
+	IsEdit              bool   `json:"is_edit"`
+	ForwardedFromUserID *int64 `json:"forwarded_from_user_id"`
+	ForwardedFromChatID *int64 `json:"forwarded_from_chat_id"`
+
+	Entities        []Entity `json:"entities"`
+	CaptionEntities []Entity `json:"caption_entities"`
+
+	Sender       Sender   `json:"sender"`
+	SenderChatID *int64   `json:"sender_chat_id"`
+	ViaBot       *Bot     `json:"via_bot"`
+	ReplyTo      *Reply   `json:"reply_to"`
+	Media        *Media   `json:"media"`
+	Buttons      []Button `json:"buttons"`
+}
+
+// Sender mirrors plugin.Sender.
+type Sender struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Username  string `json:"username"`
+	IsBot     bool   `json:"is_bot"`
+}
+
+// Bot mirrors plugin.Bot.
+type Bot struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// Reply mirrors plugin.Reply.
+type Reply struct {
+	MessageID    int    `json:"message_id"`
+	UserID       int64  `json:"user_id"`
+	SenderChatID *int64 `json:"sender_chat_id"`
+	Text         string `json:"text"`
+}
+
+// Media mirrors plugin.Media.
+type Media struct {
+	Kind         plugin.MediaKind `json:"kind"`
+	FileID       string           `json:"file_id"`
+	FileUniqueID string           `json:"file_unique_id"`
+	FileName     string           `json:"file_name"`
+	MimeType     string           `json:"mime_type"`
+	FileSize     int64            `json:"file_size"`
+	Width        int              `json:"width"`
+	Height       int              `json:"height"`
+	Duration     int              `json:"duration"`
+	IsAnimated   bool             `json:"is_animated"`
+}
+
+// Button mirrors plugin.Button.
+type Button struct {
+	Text         string `json:"text"`
+	URL          string `json:"url"`
+	CallbackData string `json:"callback_data"`
+}
+
+// Entity mirrors plugin.Entity, offsets and lengths are in UTF-16 code units as in the Telegram Bot API.
+type Entity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	URL    string `json:"url"`
+}
+
+func (e Entity) toPlugin() plugin.Entity {
+	return plugin.Entity{
+		Type:   e.Type,
+		Offset: e.Offset,
+		Length: e.Length,
+		URL:    e.URL,
+	}
+}
+
+func (r MessageRequest) Validate() error {
+	if r.Text == "" && r.Caption == "" && r.Media == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "text, caption or media is required")
+	}
+
+	if r.Media != nil && r.Media.Kind == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "media kind is required")
+	}
+
+	return nil
+}
+
+func (r MessageRequest) toMessage() plugin.Message {
+	return plugin.Message{
+		Text:                r.Text,
+		Caption:             r.Caption,
+		UserID:              r.UserID,
+		ChatID:              r.ChatID,
+		MessageID:           r.MessageID,
+		IsEdit:              r.IsEdit,
+		ForwardedFromUserID: r.ForwardedFromUserID,
+		ForwardedFromChatID: r.ForwardedFromChatID,
+		Entities:            lo.Map(r.Entities, func(e Entity, _ int) plugin.Entity { return e.toPlugin() }),
+		CaptionEntities:     lo.Map(r.CaptionEntities, func(e Entity, _ int) plugin.Entity { return e.toPlugin() }),
+		Sender:              plugin.Sender(r.Sender),
+		SenderChatID:        r.SenderChatID,
+		ViaBot:              (*plugin.Bot)(r.ViaBot),
+		ReplyTo:             (*plugin.Reply)(r.ReplyTo),
+		Media:               (*plugin.Media)(r.Media),
+		Buttons:             lo.Map(r.Buttons, func(b Button, _ int) plugin.Button { return plugin.Button(b) }),
+
+		Source: plugin.SourceAPI,
+	}
+}
+
+// PluginResult is the decision of a single plugin.
+type PluginResult struct {
+	Plugin   string         `json:"plugin"`
+	Action   plugin.Action  `json:"action"`
+	Reason   string         `json:"reason"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// TraceEntry is the outcome of a single plugin during the evaluation.
+type TraceEntry struct {
+	Plugin     string         `json:"plugin"`
+	Action     plugin.Action  `json:"action,omitempty"`
+	Reason     string         `json:"reason,omitempty"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	DurationMs float64        `json:"duration_ms"`
+	Error      string         `json:"error,omitempty"`
+	Shadow     bool           `json:"shadow,omitempty"`
+}
+
+// MessageResponse is the final decision with the results of all evaluated plugins.
+type MessageResponse struct {
+	Plugin         string                `json:"plugin"`
+	Action         plugin.Action         `json:"action"`
+	Reason         string                `json:"reason"`
+	Metadata       map[string]any        `json:"metadata,omitempty"`
+	Recommendation plugin.Recommendation `json:"recommendation,omitempty"`
+	Severity       plugin.Severity       `json:"severity,omitempty"`
+	Plugins        []TraceEntry          `json:"plugins"`
+	Shadow         []PluginResult        `json:"shadow,omitempty"`
+}
+
+func newPluginResult(result plugin.Result) PluginResult {
+	return PluginResult{
+		Plugin:   result.Plugin,
+		Action:   result.Action,
+		Reason:   result.Reason,
+		Metadata: result.Metadata,
+	}
+}
+
+func newTraceEntry(entry censor.TraceEntry) TraceEntry {
+	return TraceEntry{
+		Plugin:     entry.Plugin,
+		Action:     entry.Action,
+		Reason:     entry.Reason,
+		Metadata:   entry.Metadata,
+		DurationMs: float64(entry.Duration) / float64(time.Millisecond),
+		Error:      entry.Error,
+		Shadow:     entry.Shadow,
+	}
+}
+
+func newMessageResponse(result plugin.Result) MessageResponse {
+	shadow, _ := result.Metadata[censor.MetadataKeyShadow].([]plugin.Result)
+
+	// The trace and shadow results are returned as separate fields
+	metadata := lo.OmitByKeys(result.Metadata, []string{censor.MetadataKeyTrace, censor.MetadataKeyShadow})
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+
+	return MessageResponse{
+		Plugin:         result.Plugin,
+		Action:         result.Action,
+		Reason:         result.Reason,
+		Metadata:       metadata,
+		Recommendation: result.Recommendation(),
+		Severity:       result.Severity(),
+		Plugins:        lo.Map(censor.Trace(result), func(e censor.TraceEntry, _ int) TraceEntry { return newTraceEntry(e) }),
+		Shadow:         lo.Map(shadow, func(r plugin.Result, _ int) PluginResult { return newPluginResult(r) }),
+	}
+}
+
+// timeFormat is the format of times in queries and exports.
+const timeFormat = time.RFC3339
+
+// AuditResponse is a page of audit log entries.
+type AuditResponse struct {
+	Entries []audit.Entry `json:"entries"`
+}
+
+// parseAuditQuery returns the filter of the chat_id, user_id, plugin, from and to query parameters.
+func parseAuditQuery(c *fiber.Ctx) (audit.Filter, error) {
+	filter := audit.Filter{
+		ChatID: nil,
+		UserID: nil,
+		Plugin: c.Query("plugin"),
+		From:   time.Time{},
+		To:     time.Time{},
+		Limit:  0,
+	}
+
+	for name, target := range map[string]**int64{"chat_id": &filter.ChatID, "user_id": &filter.UserID} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return audit.Filter{}, fiber.NewError(fiber.StatusBadRequest, "invalid "+name)
+		}
+		*target = &id
+	}
+
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(timeFormat, value)
+		if err != nil {
+			return audit.Filter{}, fiber.NewError(fiber.StatusBadRequest, "invalid "+name+", RFC 3339 time expected")
+		}
+		*target = t
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// MessagesHandler evaluates messages of other services with the censor plugins.
+type MessagesHandler struct {
+	censor *censor.Service
+	logger *zap.Logger
+}
+
+func NewMessagesHandler(censor *censor.Service, logger *zap.Logger) *MessagesHandler {
+	return &MessagesHandler{
+		censor: censor,
+		logger: logger,
+	}
+}
+
+func (h *MessagesHandler) Register(router fiber.Router) {
+	router.Post("", h.post)
+}
+
+// post evaluates the message and returns the decision with the trace of all evaluated plugins.
+func (h *MessagesHandler) post(c *fiber.Ctx) error {
+	var req MessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err))
+	}
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	result := h.censor.Evaluate(c.UserContext(), req.toMessage())
+
+	h.logger.Debug("message evaluated",
+		zap.Int64("chat_id", req.ChatID),
+		zap.Int64("user_id", req.UserID),
+		zap.String("action", string(result.Action)),
+		zap.String("plugin", result.Plugin),
+	)
+
+	return c.JSON(newMessageResponse(result))
+}
@@ -0,0 +1,99 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/capcom6/censor-tg-bot/internal/censor"
+	"github.com/capcom6/censor-tg-bot/internal/censor/plugin"
+	"github.com/capcom6/censor-tg-bot/internal/server/handlers"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type keywordPlugin struct{}
+
+func (keywordPlugin) Name() string { return "keyword" }
+
+func (keywordPlugin) Evaluate(_ context.Context, msg plugin.Message) (plugin.Result, error) {
+	if strings.Contains(msg.Text, "spam") {
+		return plugin.Result{Action: plugin.ActionBlock, Reason: "spam", Metadata: nil, Plugin: "keyword"}, nil
+	}
+	return plugin.Result{Action: plugin.ActionSkip, Reason: "", Metadata: nil, Plugin: "keyword"}, nil
+}
+
+func (keywordPlugin) Priority() int { return 0 }
+
+func (keywordPlugin) Cleanup(_ context.Context) {}
+
+func newApp(t *testing.T) *fiber.App {
+	t.Helper()
+
+	svc, err := censor.New(
+		[]plugin.Metadata{{
+			Name:    "keyword",
+			Factory: func(map[string]any) (plugin.Plugin, error) { return keywordPlugin{}, nil },
+		}},
+		censor.Config{
+			Strategy:       censor.StrategySequential,
+			Timeout:        time.Second,
+			EnabledOnly:    true,
+			ScoreThreshold: 1,
+			Plugins: map[string]censor.PluginConfig{
+				"keyword": {Enabled: true, Priority: 1, Weight: 1},
+			},
+			ErrorAction: plugin.ActionBlock,
+			SkipAction:  plugin.ActionAllow,
+		},
+		censor.NewMetrics(),
+		zap.NewNop(),
+	)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	handlers.NewMessagesHandler(svc, zap.NewNop()).Register(app.Group("/messages"))
+
+	return app
+}
+
+func TestMessagesHandler(t *testing.T) {
+	app := newApp(t)
+
+	post := func(body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+
+		return resp
+	}
+
+	resp := post(`{"text":"buy spam now","chat_id":-100,"user_id":1}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result handlers.MessageResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(t, plugin.ActionBlock, result.Action)
+	require.Equal(t, "keyword", result.Plugin)
+	require.Len(t, result.Plugins, 1)
+	require.Equal(t, "spam", result.Plugins[0].Reason)
+	require.Empty(t, result.Plugins[0].Error)
+	require.NotContains(t, result.Metadata, censor.MetadataKeyTrace)
+
+	resp = post(`{"chat_id":-100}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = post(`{"chat_id":-100,"media":{"kind":"photo","file_unique_id":"AQADxyz"}}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = post(`{"chat_id":-100,"media":{"file_unique_id":"AQADxyz"}}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
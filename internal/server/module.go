@@ -1,8 +1,11 @@
 package server
 
 import (
+	"github.com/capcom6/censor-tg-bot/internal/audit"
+	"github.com/capcom6/censor-tg-bot/internal/server/handlers"
 	"github.com/go-core-fx/fiberfx"
 	"github.com/go-core-fx/logger"
+	"github.com/gofiber/fiber/v2"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
@@ -19,15 +22,31 @@ func Module() fx.Option {
 			return opts
 		}),
 
-		// fx.Provide(
-		// 	handlers.NewMessagesHandler,
-		// 	fx.Private,
-		// ),
+		fx.Provide(
+			handlers.NewMessagesHandler,
+			handlers.NewAuditHandler,
+			fx.Private,
+		),
 
-		// fx.Invoke(func(app *fiber.App, messages *handlers.MessagesHandler) {
-		// 	api := app.Group("/api/v1")
+		fx.Invoke(func(
+			config Config,
+			app *fiber.App,
+			messages *handlers.MessagesHandler,
+			auditHandler *handlers.AuditHandler,
+			auditLog *audit.Log,
+			log *zap.Logger,
+		) {
+			if config.APIToken == "" {
+				log.Info("moderation API disabled, no token configured")
+				return
+			}
 
-		// 	messages.Register(api.Group("/messages"))
-		// }),
+			api := app.Group("/api/v1", newBearerAuth(config.APIToken))
+
+			messages.Register(api.Group("/messages"))
+			if auditLog.Enabled() {
+				auditHandler.Register(api.Group("/audit"))
+			}
+		}),
 	)
 }
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	boltFileMode    = 0o600
+	boltDirMode     = 0o750
+	boltOpenTimeout = 5 * time.Second
+)
+
+//nolint:gochecknoglobals // bucket names
+var (
+	boltCountersBucket = []byte("counters")
+	boltHistoryBucket  = []byte("history")
+	boltStateBucket    = []byte("state")
+)
+
+// boltStorage persists counters, history and state in an embedded bbolt database file.
+type boltStorage struct {
+	ttl time.Duration
+
+	db *bolt.DB
+}
+
+func newBolt(path string, ttl time.Duration) (*boltStorage, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: bolt database path is required", ErrInitFailed)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), boltDirMode); err != nil {
+		return nil, fmt.Errorf("%w: failed to create directory: %w", ErrInitFailed, err)
+	}
+
+	db, err := bolt.Open(path, boltFileMode, &bolt.Options{Timeout: boltOpenTimeout}) //nolint:exhaustruct // defaults
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to open database: %w", ErrInitFailed, err)
+	}
+
+	if updErr := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltCountersBucket, boltHistoryBucket, boltStateBucket} {
+			if _, bErr := tx.CreateBucketIfNotExists(bucket); bErr != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", bucket, bErr)
+			}
+		}
+		return nil
+	}); updErr != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%w: %w", ErrInitFailed, updErr)
+	}
+
+	return &boltStorage{
+		ttl: ttl,
+		db:  db,
+	}, nil
+}
+
+func (s *boltStorage) GetOrSet(key string) (int, error) {
+	var count int
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+
+		var history []time.Time
+		if err := s.read(tx, boltHistoryBucket, key, &history); err != nil {
+			return err
+		}
+		if err := s.write(tx, boltHistoryBucket, key, appendHistory(history, now)); err != nil {
+			return err
+		}
+
+		i := item{Count: 0, Since: now}
+		if err := s.read(tx, boltCountersBucket, key, &i); err != nil {
+			return err
+		}
+		count = i.inc(s.ttl)
+
+		return s.write(tx, boltCountersBucket, key, i)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return count, nil
+}
+
+func (s *boltStorage) Get(key string) (int, error) {
+	var i item
+
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		return s.read(tx, boltCountersBucket, key, &i)
+	}); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	if i.Count == 0 || i.expired(s.ttl) {
+		return 0, nil
+	}
+
+	return i.Count, nil
+}
+
+func (s *boltStorage) Delete(key string) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltCountersBucket).Delete([]byte(key))
+	}); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+func (s *boltStorage) History(key string) ([]time.Time, error) {
+	var history []time.Time
+
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		return s.read(tx, boltHistoryBucket, key, &history)
+	}); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return history, nil
+}
+
+func (s *boltStorage) Cleanup() error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := s.prune(tx, boltCountersBucket, func(data []byte) (bool, error) {
+			var i item
+			err := json.Unmarshal(data, &i)
+			return i.expired(s.ttl), err
+		}); err != nil {
+			return err
+		}
+
+		if err := s.prune(tx, boltHistoryBucket, func(data []byte) (bool, error) {
+			var history []time.Time
+			err := json.Unmarshal(data, &history)
+			return historyExpired(history, s.ttl), err
+		}); err != nil {
+			return err
+		}
+
+		return s.prune(tx, boltStateBucket, func(data []byte) (bool, error) {
+			var e entry
+			err := json.Unmarshal(data, &e)
+			return e.expired(), err
+		})
+	}); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+func (s *boltStorage) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return nil
+}
+
+func (s *boltStorage) Increment(_ context.Context, key string, window time.Duration) (int, error) {
+	var count int
+
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		var e entry
+		found := tx.Bucket(boltStateBucket).Get([]byte(key)) != nil
+		if err := s.read(tx, boltStateBucket, key, &e); err != nil {
+			return err
+		}
+
+		e, count = incrementEntry(e, found, window)
+
+		return s.write(tx, boltStateBucket, key, e)
+	}); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return count, nil
+}
+
+func (s *boltStorage) Load(_ context.Context, key string) ([]byte, error) {
+	var (
+		e     entry
+		found bool
+	)
+
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(boltStateBucket).Get([]byte(key)) != nil
+		return s.read(tx, boltStateBucket, key, &e)
+	}); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	if !found || e.expired() {
+		return nil, ErrNotFound
+	}
+
+	return e.Value, nil
+}
+
+func (s *boltStorage) Store(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return s.write(tx, boltStateBucket, key, newEntry(value, ttl))
+	}); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+func (s *boltStorage) Update(
+	_ context.Context,
+	key string,
+	ttl time.Duration,
+	fn func(value []byte) []byte,
+) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		var e entry
+		if err := s.read(tx, boltStateBucket, key, &e); err != nil {
+			return err
+		}
+		if e.expired() {
+			e.Value = nil
+		}
+
+		return s.write(tx, boltStateBucket, key, newEntry(fn(e.Value), ttl))
+	}); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+func (s *boltStorage) Remove(_ context.Context, key string) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltStateBucket).Delete([]byte(key))
+	}); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+// prune deletes the keys of the bucket whose values are expired, values which can not be decoded are kept.
+func (s *boltStorage) prune(tx *bolt.Tx, bucket []byte, expired func(data []byte) (bool, error)) error {
+	b := tx.Bucket(bucket)
+
+	// Keys are collected first, as deleting while iterating with a cursor skips keys
+	var keys [][]byte
+	if err := b.ForEach(func(k, v []byte) error {
+		if ok, err := expired(v); err == nil && ok {
+			keys = append(keys, slices.Clone(k))
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to scan %s: %w", bucket, err)
+	}
+
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return fmt.Errorf("failed to delete %s/%s: %w", bucket, k, err)
+		}
+	}
+
+	return nil
+}
+
+// read decodes the value of the key into v, leaving it untouched if the key is missing.
+func (s *boltStorage) read(tx *bolt.Tx, bucket []byte, key string, v any) error {
+	data := tx.Bucket(bucket).Get([]byte(key))
+	if data == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s/%s: %w", bucket, key, err)
+	}
+
+	return nil
+}
+
+func (s *boltStorage) write(tx *bolt.Tx, bucket []byte, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s/%s: %w", bucket, key, err)
+	}
+
+	if putErr := tx.Bucket(bucket).Put([]byte(key), data); putErr != nil {
+		return fmt.Errorf("failed to write %s/%s: %w", bucket, key, putErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// MessageCounter counts the messages of users which were not blocked in the state, so the count
+// survives restarts and configuration reloads and is never reset. Counting stops at the limit,
+// as plugins only treat users with fewer messages differently.
+type MessageCounter struct {
+	state  State
+	prefix string
+}
+
+// NewMessageCounter returns a counter keeping the counts under the prefix, usually the name of the plugin.
+func NewMessageCounter(state State, prefix string) *MessageCounter {
+	return &MessageCounter{
+		state:  state,
+		prefix: prefix,
+	}
+}
+
+// Count returns the number of counted messages of the key.
+func (c *MessageCounter) Count(ctx context.Context, key string) (int, error) {
+	data, err := c.state.Load(ctx, c.key(key))
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load counter: %w", err)
+	}
+
+	count, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid counter %q: %w", ErrStorageFailed, data, err)
+	}
+
+	return count, nil
+}
+
+// Increment counts a message of the key unless the limit is already reached.
+func (c *MessageCounter) Increment(ctx context.Context, key string, limit int) error {
+	count, err := c.Count(ctx, key)
+	if err != nil || count >= limit {
+		return err
+	}
+
+	if _, incErr := c.state.Increment(ctx, c.key(key), 0); incErr != nil {
+		return fmt.Errorf("failed to increment counter: %w", incErr)
+	}
+
+	return nil
+}
+
+func (c *MessageCounter) key(key string) string {
+	return c.prefix + ":messages:" + key
+}
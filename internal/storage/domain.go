@@ -1,35 +1,79 @@
 package storage
 
 import (
-	"fmt"
+	"strconv"
 	"time"
 )
 
+// historyLimit is the number of the latest violations kept per key.
+const historyLimit = 100
+
 type item struct {
-	count int
-	ttl   time.Duration
-	since time.Time
+	Count int       `json:"count"`
+	Since time.Time `json:"since"`
+}
+
+func (i *item) expired(ttl time.Duration) bool {
+	return time.Since(i.Since) > ttl
+}
+
+func (i *item) inc(ttl time.Duration) int {
+	if i.expired(ttl) {
+		i.Count = 0
+	}
+
+	i.Since = time.Now()
+	i.Count++
+
+	return i.Count
+}
+
+// appendHistory appends the time to the history, keeping at most historyLimit latest entries.
+func appendHistory(history []time.Time, t time.Time) []time.Time {
+	history = append(history, t)
+	if len(history) > historyLimit {
+		history = history[len(history)-historyLimit:]
+	}
+
+	return history
+}
+
+// historyExpired returns whether the latest time of the history is older than the TTL.
+func historyExpired(history []time.Time, ttl time.Duration) bool {
+	return len(history) == 0 || time.Since(history[len(history)-1]) > ttl
 }
 
-func (i *item) inc() int {
-	if time.Since(i.since) > i.ttl {
-		i.count = 0
+// entry is a value of the key-value store of a process-local backend.
+type entry struct {
+	Value     []byte    `json:"value"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // zero if the entry never expires
+}
+
+func newEntry(value []byte, ttl time.Duration) entry {
+	e := entry{Value: value, ExpiresAt: time.Time{}}
+	if ttl > 0 {
+		e.ExpiresAt = time.Now().Add(ttl)
 	}
 
-	i.since = time.Now()
-	i.count++
+	return e
+}
 
-	return i.count
+func (e entry) expired() bool {
+	return !e.ExpiresAt.IsZero() && time.Now().After(e.ExpiresAt)
 }
 
-func newItem(ttl time.Duration) (*item, error) {
-	if ttl == 0 {
-		return nil, fmt.Errorf("%w: ttl must be greater than 0", ErrInvalidTTL)
+// incrementEntry increments the counter stored in the entry, starting a new one expiring after the window
+// if the entry is missing or expired, and returns the updated entry and the new value.
+func incrementEntry(e entry, found bool, window time.Duration) (entry, int) {
+	count := 0
+	if found && !e.expired() {
+		count, _ = strconv.Atoi(string(e.Value))
+	} else {
+		e = newEntry(nil, window)
 	}
 
-	return &item{
-		count: 1,
-		ttl:   ttl,
-		since: time.Now(),
-	}, nil
+	count++
+	e.Value = []byte(strconv.Itoa(count))
+
+	return e, count
 }
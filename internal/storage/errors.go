@@ -3,6 +3,8 @@ package storage
 import "errors"
 
 var (
-	ErrInitFailed = errors.New("failed to init storage")
-	ErrInvalidTTL = errors.New("invalid ttl")
+	ErrInitFailed    = errors.New("failed to init storage")
+	ErrInvalidTTL    = errors.New("invalid ttl")
+	ErrStorageFailed = errors.New("storage operation failed")
+	ErrNotFound      = errors.New("not found")
 )
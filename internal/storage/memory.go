@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// memoryStorage keeps counters and state in memory, they are lost on restart.
+type memoryStorage struct {
+	ttl time.Duration
+
+	items   map[string]*item
+	history map[string][]time.Time
+	entries map[string]entry
+	mux     *sync.Mutex
+}
+
+func newMemory(ttl time.Duration) *memoryStorage {
+	return &memoryStorage{
+		ttl:     ttl,
+		items:   make(map[string]*item),
+		history: make(map[string][]time.Time),
+		entries: make(map[string]entry),
+		mux:     &sync.Mutex{},
+	}
+}
+
+func (s *memoryStorage) GetOrSet(key string) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.history[key] = appendHistory(s.history[key], time.Now())
+
+	i, ok := s.items[key]
+	if !ok {
+		i = &item{Count: 0, Since: time.Now()}
+		s.items[key] = i
+	}
+
+	return i.inc(s.ttl), nil
+}
+
+func (s *memoryStorage) Get(key string) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	i, ok := s.items[key]
+	if !ok || i.expired(s.ttl) {
+		return 0, nil
+	}
+
+	return i.Count, nil
+}
+
+func (s *memoryStorage) Delete(key string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.items, key)
+
+	return nil
+}
+
+func (s *memoryStorage) History(key string) ([]time.Time, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return slices.Clone(s.history[key]), nil
+}
+
+func (s *memoryStorage) Cleanup() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for key, i := range s.items {
+		if i.expired(s.ttl) {
+			delete(s.items, key)
+		}
+	}
+	for key, history := range s.history {
+		if historyExpired(history, s.ttl) {
+			delete(s.history, key)
+		}
+	}
+	for key, e := range s.entries {
+		if e.expired() {
+			delete(s.entries, key)
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStorage) Close() error {
+	return nil
+}
+
+func (s *memoryStorage) Increment(_ context.Context, key string, window time.Duration) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e, found := s.entries[key]
+	e, count := incrementEntry(e, found, window)
+	s.entries[key] = e
+
+	return count, nil
+}
+
+func (s *memoryStorage) Load(_ context.Context, key string) ([]byte, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || e.expired() {
+		return nil, ErrNotFound
+	}
+
+	return slices.Clone(e.Value), nil
+}
+
+func (s *memoryStorage) Store(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.entries[key] = newEntry(slices.Clone(value), ttl)
+
+	return nil
+}
+
+func (s *memoryStorage) Update(
+	_ context.Context,
+	key string,
+	ttl time.Duration,
+	fn func(value []byte) []byte,
+) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var value []byte
+	if e, ok := s.entries[key]; ok && !e.expired() {
+		value = slices.Clone(e.Value)
+	}
+
+	s.entries[key] = newEntry(slices.Clone(fn(value)), ttl)
+
+	return nil
+}
+
+func (s *memoryStorage) Remove(_ context.Context, key string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.entries, key)
+
+	return nil
+}
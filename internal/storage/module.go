@@ -1,10 +1,16 @@
 package storage
 
 import (
+	"context"
+	"time"
+
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
+// cleanupInterval is how often expired keys are removed.
+const cleanupInterval = 10 * time.Minute
+
 func Module() fx.Option {
 	return fx.Module(
 		"storage",
@@ -12,5 +18,40 @@ func Module() fx.Option {
 			return logger.Named("storage")
 		}),
 		fx.Provide(New),
+		fx.Provide(NewKV),
+		fx.Provide(NewState),
+		fx.Invoke(func(lc fx.Lifecycle, storage Storage, logger *zap.Logger) {
+			ctx, cancel := context.WithCancel(context.Background())
+			waitCh := make(chan struct{})
+			lc.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					go func() {
+						defer close(waitCh)
+
+						ticker := time.NewTicker(cleanupInterval)
+						defer ticker.Stop()
+						for {
+							select {
+							case <-ticker.C:
+								if err := storage.Cleanup(); err != nil {
+									logger.Error("failed to clean up storage", zap.Error(err))
+								}
+							case <-ctx.Done():
+								return
+							}
+						}
+					}()
+					return nil
+				},
+				OnStop: func(ctx context.Context) error {
+					cancel()
+					select {
+					case <-waitCh:
+					case <-ctx.Done():
+					}
+					return storage.Close()
+				},
+			})
+		}),
 	)
 }
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKeyPrefix = "censor:"
+	redisTimeout   = 5 * time.Second
+
+	// redisUpdateAttempts is the number of attempts to update a key modified concurrently,
+	// each failed attempt means that another client has updated the key.
+	redisUpdateAttempts = 100
+)
+
+// redisStorage keeps counters in Redis, sharing them between bot replicas.
+// It also implements KV for stateful plugins.
+type redisStorage struct {
+	ttl time.Duration
+
+	client *redis.Client
+}
+
+func newRedis(u *url.URL, ttl time.Duration) (*redisStorage, error) {
+	// ttl is our own parameter, unknown to the client
+	clientURL := *u
+	query := clientURL.Query()
+	query.Del("ttl")
+	clientURL.RawQuery = query.Encode()
+
+	options, err := redis.ParseURL(clientURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse redis url: %w", ErrInitFailed, err)
+	}
+
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if pingErr := client.Ping(ctx).Err(); pingErr != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("%w: failed to connect to redis: %w", ErrInitFailed, pingErr)
+	}
+
+	return &redisStorage{
+		ttl:    ttl,
+		client: client,
+	}, nil
+}
+
+func (s *redisStorage) GetOrSet(key string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	var incr *redis.IntCmd
+	if _, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, s.counterKey(key))
+		pipe.PExpire(ctx, s.counterKey(key), s.ttl)
+		pipe.RPush(ctx, s.historyKey(key), time.Now().UnixNano())
+		pipe.LTrim(ctx, s.historyKey(key), -historyLimit, -1)
+		pipe.PExpire(ctx, s.historyKey(key), s.ttl)
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return int(incr.Val()), nil
+}
+
+func (s *redisStorage) Get(key string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	count, err := s.client.Get(ctx, s.counterKey(key)).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return count, nil
+}
+
+func (s *redisStorage) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := s.client.Del(ctx, s.counterKey(key)).Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+func (s *redisStorage) History(key string) ([]time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	values, err := s.client.LRange(ctx, s.historyKey(key), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	history := make([]time.Time, 0, len(values))
+	for _, v := range values {
+		nanos, parseErr := strconv.ParseInt(v, 10, 64)
+		if parseErr != nil {
+			return nil, fmt.Errorf("%w: invalid history entry %q: %w", ErrStorageFailed, v, parseErr)
+		}
+		history = append(history, time.Unix(0, nanos))
+	}
+
+	return history, nil
+}
+
+// Cleanup is a no-op, keys expire in Redis.
+func (s *redisStorage) Cleanup() error {
+	return nil
+}
+
+func (s *redisStorage) Close() error {
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("failed to close redis client: %w", err)
+	}
+
+	return nil
+}
+
+func (s *redisStorage) Increment(ctx context.Context, key string, window time.Duration) (int, error) {
+	var incr *redis.IntCmd
+	if _, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		// The window starts with the first increment, SET NX is used instead of PEXPIRE NX
+		// which is not supported before Redis 7
+		if window > 0 {
+			pipe.SetNX(ctx, redisKeyPrefix+key, 0, window)
+		}
+		incr = pipe.Incr(ctx, redisKeyPrefix+key)
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return int(incr.Val()), nil
+}
+
+func (s *redisStorage) Load(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return value, nil
+}
+
+func (s *redisStorage) Store(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.client.Set(ctx, redisKeyPrefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+// Update watches the key and retries if it is modified by another client before the new value is set.
+func (s *redisStorage) Update(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fn func(value []byte) []byte,
+) error {
+	key = redisKeyPrefix + key
+
+	update := func(tx *redis.Tx) error {
+		value, err := tx.Get(ctx, key).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err //nolint:wrapcheck // wrapped below
+		}
+
+		updated := fn(value)
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, updated, ttl)
+			return nil
+		})
+		return err //nolint:wrapcheck // wrapped below
+	}
+
+	for range redisUpdateAttempts {
+		err := s.client.Watch(ctx, update, key)
+		if errors.Is(err, redis.TxFailedErr) && ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: too many concurrent updates of %s", ErrStorageFailed, key)
+}
+
+func (s *redisStorage) Remove(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, redisKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrStorageFailed, err)
+	}
+
+	return nil
+}
+
+func (s *redisStorage) counterKey(key string) string {
+	return redisKeyPrefix + "violations:" + key
+}
+
+func (s *redisStorage) historyKey(key string) string {
+	return redisKeyPrefix + "history:" + key
+}
@@ -1,56 +1,107 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"net/url"
-	"sync"
 	"time"
 )
 
-type Storage struct {
-	ttl time.Duration
+// Storage keeps violation counters and history by key.
+type Storage interface {
+	// GetOrSet increments the counter of the key and returns the new value.
+	// The counter is reset when it has not been incremented for the TTL.
+	GetOrSet(key string) (int, error)
+	// Get returns the current counter of the key, zero if it is missing or expired.
+	Get(key string) (int, error)
+	// Delete resets the counter of the key, the history is kept.
+	Delete(key string) error
+	// History returns the times of the latest increments of the key, oldest first.
+	History(key string) ([]time.Time, error)
+	// Cleanup removes expired counters and state entries, and the history of keys
+	// not incremented for the TTL, from backends which do not expire keys themselves.
+	Cleanup() error
+	// Close releases the resources of the storage.
+	Close() error
+}
 
-	items map[string]*item
-	mux   *sync.Mutex
+// KV is a key-value store shared between bot replicas, used by stateful plugins.
+// Keys are namespaced by the store, plugins should prefix them with their names.
+type KV interface {
+	// Increment increments the counter of the key and returns the new value.
+	// The counter expires after the window since its first increment, never if zero.
+	Increment(ctx context.Context, key string, window time.Duration) (int, error)
+	// Load returns the value of the key, ErrNotFound if it is missing or expired.
+	Load(ctx context.Context, key string) ([]byte, error)
+	// Store sets the value of the key, expiring after the TTL, never if zero.
+	Store(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Update atomically replaces the value of the key, nil if it is missing or expired, with the one
+	// returned by fn, expiring after the TTL, never if zero. fn may be called several times.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(value []byte) []byte) error
+	// Remove removes the key, missing keys are ignored.
+	Remove(ctx context.Context, key string) error
 }
 
-func (s *Storage) GetOrSet(key string) (int, error) {
-	s.mux.Lock()
-	defer s.mux.Unlock()
+// State is the key-value store of the configured backend, used for bot state which must survive
+// restarts: it is kept in memory, in the database file or in Redis shared between replicas.
+// Window and TTL of zero mean that keys never expire.
+type State interface {
+	KV
+}
 
-	i, ok := s.items[key]
-	if ok {
-		return i.inc(), nil
+func New(config Config) (Storage, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse url: %w", ErrInitFailed, err)
 	}
 
-	i, err := newItem(s.ttl)
+	ttl, err := parseTTL(u)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	s.items[key] = i
-
-	return 1, nil
+	switch u.Scheme {
+	case "memory":
+		return newMemory(ttl), nil
+	case "bolt":
+		return newBolt(u.Host+u.Path, ttl)
+	case "redis", "rediss":
+		return newRedis(u, ttl)
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme: %s", ErrInitFailed, u.Scheme)
+	}
 }
 
-func New(config Config) (*Storage, error) {
-	u, err := url.Parse(config.URL)
+func parseTTL(u *url.URL) (time.Duration, error) {
+	ttl, err := time.ParseDuration(u.Query().Get("ttl"))
 	if err != nil {
-		return nil, fmt.Errorf("%w: failed to parse url: %w", ErrInitFailed, err)
+		return 0, fmt.Errorf("%w: error parsing ttl: %w", ErrInitFailed, err)
 	}
 
-	if u.Scheme != "memory" {
-		return nil, fmt.Errorf("%w: unsupported scheme: %s", ErrInitFailed, u.Scheme)
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%w: %w: ttl must be greater than 0", ErrInitFailed, ErrInvalidTTL)
 	}
 
-	ttl, err := time.ParseDuration(u.Query().Get("ttl"))
-	if err != nil {
-		return nil, fmt.Errorf("%w: error parsing ttl: %w", ErrInitFailed, err)
+	return ttl, nil
+}
+
+// NewKV returns the shared store of the storage, nil if the storage is process-local.
+func NewKV(storage Storage) KV {
+	kv, ok := storage.(*redisStorage)
+	if !ok {
+		return nil
+	}
+
+	return kv
+}
+
+// NewState returns the key-value store of the storage backend.
+func NewState(storage Storage) State {
+	state, ok := storage.(State)
+	if !ok {
+		// Storages of other packages, e.g. test doubles, keep the state in memory
+		return newMemory(0)
 	}
 
-	return &Storage{
-		ttl:   ttl,
-		items: make(map[string]*item),
-		mux:   &sync.Mutex{},
-	}, nil
+	return state
 }
@@ -1,10 +1,14 @@
 package storage_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/capcom6/censor-tg-bot/internal/storage"
 )
 
@@ -156,3 +160,339 @@ func BenchmarkStorage_GetOrSet(b *testing.B) {
 		}
 	})
 }
+
+func TestStorage_GetDelete(t *testing.T) {
+	s, initErr := storage.New(storage.Config{URL: "memory://storage?ttl=1h"})
+	if initErr != nil {
+		t.Fatalf("Failed to create storage: %v", initErr)
+	}
+
+	if count, err := s.Get("key"); err != nil || count != 0 {
+		t.Errorf("Expected count 0 for missing key, got %d (%v)", count, err)
+	}
+
+	_, _ = s.GetOrSet("key")
+	_, _ = s.GetOrSet("key")
+	if count, err := s.Get("key"); err != nil || count != 2 {
+		t.Errorf("Expected count 2, got %d (%v)", count, err)
+	}
+
+	if err := s.Delete("key"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if count, err := s.Get("key"); err != nil || count != 0 {
+		t.Errorf("Expected count 0 after delete, got %d (%v)", count, err)
+	}
+}
+
+func TestStorage_Bolt(t *testing.T) {
+	url := "bolt://" + filepath.Join(t.TempDir(), "data", "storage.db") + "?ttl=1h"
+
+	s, err := storage.New(storage.Config{URL: url})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		count, incErr := s.GetOrSet("key")
+		if incErr != nil || count != i {
+			t.Fatalf("Expected count %d, got %d (%v)", i, count, incErr)
+		}
+	}
+	if closeErr := s.Close(); closeErr != nil {
+		t.Fatalf("Failed to close storage: %v", closeErr)
+	}
+
+	// Counters and history survive reopening
+	s, err = storage.New(storage.Config{URL: url})
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer s.Close()
+
+	if count, getErr := s.Get("key"); getErr != nil || count != 3 {
+		t.Errorf("Expected count 3 after reopening, got %d (%v)", count, getErr)
+	}
+	if count, incErr := s.GetOrSet("key"); incErr != nil || count != 4 {
+		t.Errorf("Expected count 4 after reopening, got %d (%v)", count, incErr)
+	}
+
+	if delErr := s.Delete("key"); delErr != nil {
+		t.Errorf("Unexpected error: %v", delErr)
+	}
+	if count, getErr := s.Get("key"); getErr != nil || count != 0 {
+		t.Errorf("Expected count 0 after delete, got %d (%v)", count, getErr)
+	}
+
+	history, err := s.History("key")
+	if err != nil || len(history) != 4 {
+		t.Errorf("Expected 4 history entries, got %d (%v)", len(history), err)
+	}
+}
+
+func TestStorage_BoltExpiration(t *testing.T) {
+	s, err := storage.New(storage.Config{
+		URL: "bolt://" + filepath.Join(t.TempDir(), "storage.db") + "?ttl=1ms",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer s.Close()
+
+	_, _ = s.GetOrSet("expiring_key")
+	time.Sleep(50 * time.Millisecond)
+
+	if count, getErr := s.Get("expiring_key"); getErr != nil || count != 0 {
+		t.Errorf("Expected count 0 for expired key, got %d (%v)", count, getErr)
+	}
+	if count, incErr := s.GetOrSet("expiring_key"); incErr != nil || count != 1 {
+		t.Errorf("Expected count 1 for expired key, got %d (%v)", count, incErr)
+	}
+}
+
+func TestStorage_Redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	s, err := storage.New(storage.Config{URL: "redis://" + mr.Addr() + "/0?ttl=1h"})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer s.Close()
+
+	// Counters are shared with other replicas connected to the same server
+	replica, err := storage.New(storage.Config{URL: "redis://" + mr.Addr() + "/0?ttl=1h"})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer replica.Close()
+
+	if count, incErr := s.GetOrSet("key"); incErr != nil || count != 1 {
+		t.Errorf("Expected count 1, got %d (%v)", count, incErr)
+	}
+	if count, incErr := replica.GetOrSet("key"); incErr != nil || count != 2 {
+		t.Errorf("Expected count 2 on replica, got %d (%v)", count, incErr)
+	}
+
+	if delErr := s.Delete("key"); delErr != nil {
+		t.Errorf("Unexpected error: %v", delErr)
+	}
+	if count, getErr := replica.Get("key"); getErr != nil || count != 0 {
+		t.Errorf("Expected count 0 after delete, got %d (%v)", count, getErr)
+	}
+
+	history, err := replica.History("key")
+	if err != nil || len(history) != 2 {
+		t.Errorf("Expected 2 history entries, got %d (%v)", len(history), err)
+	}
+
+	// Counters and history expire after the TTL since the last increment
+	_, _ = s.GetOrSet("expiring_key")
+	mr.FastForward(2 * time.Hour)
+	if count, getErr := s.Get("expiring_key"); getErr != nil || count != 0 {
+		t.Errorf("Expected count 0 for expired key, got %d (%v)", count, getErr)
+	}
+	if history, histErr := s.History("expiring_key"); histErr != nil || len(history) != 0 {
+		t.Errorf("Expected no history for expired key, got %d (%v)", len(history), histErr)
+	}
+}
+
+func TestKV_Redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	s, err := storage.New(storage.Config{URL: "redis://" + mr.Addr() + "?ttl=1h"})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer s.Close()
+
+	kv := storage.NewKV(s)
+	if kv == nil {
+		t.Fatal("Expected redis storage to provide KV")
+	}
+	ctx := context.Background()
+
+	// The window starts with the first increment and is not extended
+	for i := 1; i <= 2; i++ {
+		if count, incErr := kv.Increment(ctx, "counter", time.Minute); incErr != nil || count != i {
+			t.Errorf("Expected count %d, got %d (%v)", i, count, incErr)
+		}
+		mr.FastForward(40 * time.Second)
+	}
+	if count, incErr := kv.Increment(ctx, "counter", time.Minute); incErr != nil || count != 1 {
+		t.Errorf("Expected count 1 after window, got %d (%v)", count, incErr)
+	}
+
+	if _, loadErr := kv.Load(ctx, "value"); !errors.Is(loadErr, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", loadErr)
+	}
+	if storeErr := kv.Store(ctx, "value", []byte("data"), time.Minute); storeErr != nil {
+		t.Errorf("Unexpected error: %v", storeErr)
+	}
+	if value, loadErr := kv.Load(ctx, "value"); loadErr != nil || string(value) != "data" {
+		t.Errorf("Expected stored value, got %q (%v)", value, loadErr)
+	}
+}
+
+func TestNewKV_Memory(t *testing.T) {
+	s, err := storage.New(storage.Config{URL: "memory://storage?ttl=1h"})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if kv := storage.NewKV(s); kv != nil {
+		t.Errorf("Expected no KV for memory storage, got %T", kv)
+	}
+}
+
+func TestState(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	urls := map[string]string{
+		"memory": "memory://storage?ttl=1h",
+		"bolt":   "bolt://" + filepath.Join(t.TempDir(), "storage.db") + "?ttl=1h",
+		"redis":  "redis://" + mr.Addr() + "?ttl=1h",
+	}
+
+	for name, url := range urls {
+		t.Run(name, func(t *testing.T) {
+			s, err := storage.New(storage.Config{URL: url})
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			defer s.Close()
+
+			state := storage.NewState(s)
+			ctx := context.Background()
+
+			// Counters without a window never expire
+			for i := 1; i <= 3; i++ {
+				if count, incErr := state.Increment(ctx, "counter", 0); incErr != nil || count != i {
+					t.Errorf("Expected count %d, got %d (%v)", i, count, incErr)
+				}
+			}
+
+			if _, loadErr := state.Load(ctx, "value"); !errors.Is(loadErr, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound, got %v", loadErr)
+			}
+			if storeErr := state.Store(ctx, "value", []byte("data"), 0); storeErr != nil {
+				t.Errorf("Unexpected error: %v", storeErr)
+			}
+			if value, loadErr := state.Load(ctx, "value"); loadErr != nil || string(value) != "data" {
+				t.Errorf("Expected stored value, got %q (%v)", value, loadErr)
+			}
+
+			// Updates see the current value, nil if missing
+			for _, want := range []string{"", "a"} {
+				if updErr := state.Update(ctx, "updated", 0, func(value []byte) []byte {
+					if string(value) != want {
+						t.Errorf("Expected value %q, got %q", want, value)
+					}
+					return append(value, 'a')
+				}); updErr != nil {
+					t.Errorf("Unexpected error: %v", updErr)
+				}
+			}
+			if value, loadErr := state.Load(ctx, "updated"); loadErr != nil || string(value) != "aa" {
+				t.Errorf("Expected updated value, got %q (%v)", value, loadErr)
+			}
+
+			if removeErr := state.Remove(ctx, "value"); removeErr != nil {
+				t.Errorf("Unexpected error: %v", removeErr)
+			}
+			if _, loadErr := state.Load(ctx, "value"); !errors.Is(loadErr, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound after removal, got %v", loadErr)
+			}
+		})
+	}
+}
+
+func TestState_Expiration(t *testing.T) {
+	s, err := storage.New(storage.Config{URL: "bolt://" + filepath.Join(t.TempDir(), "storage.db") + "?ttl=1h"})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer s.Close()
+
+	state := storage.NewState(s)
+	ctx := context.Background()
+
+	_ = state.Store(ctx, "value", []byte("data"), 10*time.Millisecond)
+	_, _ = state.Increment(ctx, "counter", 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	if _, loadErr := state.Load(ctx, "value"); !errors.Is(loadErr, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for expired value, got %v", loadErr)
+	}
+	if count, incErr := state.Increment(ctx, "counter", time.Minute); incErr != nil || count != 1 {
+		t.Errorf("Expected count 1 for expired counter, got %d (%v)", count, incErr)
+	}
+}
+
+func TestStorage_Cleanup(t *testing.T) {
+	urls := map[string]string{
+		"memory": "memory://storage?ttl=100ms",
+		"bolt":   "bolt://" + filepath.Join(t.TempDir(), "storage.db") + "?ttl=100ms",
+	}
+
+	for name, url := range urls {
+		t.Run(name, func(t *testing.T) {
+			s, err := storage.New(storage.Config{URL: url})
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			defer s.Close()
+
+			state := storage.NewState(s)
+			ctx := context.Background()
+
+			_, _ = s.GetOrSet("expiring_key")
+			_ = state.Store(ctx, "expiring_value", []byte("data"), 10*time.Millisecond)
+			_ = state.Store(ctx, "value", []byte("data"), 0)
+			time.Sleep(200 * time.Millisecond)
+			_, _ = s.GetOrSet("key")
+
+			if cleanupErr := s.Cleanup(); cleanupErr != nil {
+				t.Fatalf("Failed to clean up storage: %v", cleanupErr)
+			}
+
+			if history, histErr := s.History("expiring_key"); histErr != nil || len(history) != 0 {
+				t.Errorf("Expected no history for expired key, got %d (%v)", len(history), histErr)
+			}
+			if history, histErr := s.History("key"); histErr != nil || len(history) != 1 {
+				t.Errorf("Expected 1 history entry, got %d (%v)", len(history), histErr)
+			}
+			if _, loadErr := state.Load(ctx, "value"); loadErr != nil {
+				t.Errorf("Expected value without expiration to be kept, got %v", loadErr)
+			}
+		})
+	}
+}
+
+func TestMessageCounter(t *testing.T) {
+	s, err := storage.New(storage.Config{URL: "memory://storage?ttl=1h"})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	state := storage.NewState(s)
+	counter := storage.NewMessageCounter(state, "test")
+
+	for range 3 {
+		if incErr := counter.Increment(ctx, "1", 2); incErr != nil {
+			t.Fatalf("Unexpected error: %v", incErr)
+		}
+	}
+
+	// Counting stops at the limit, other keys and prefixes are counted separately
+	for key, want := range map[string]int{"1": 2, "2": 0} {
+		if count, countErr := counter.Count(ctx, key); countErr != nil || count != want {
+			t.Errorf("Expected count %d of key %q, got %d (%v)", want, key, count, countErr)
+		}
+	}
+
+	if count, countErr := storage.NewMessageCounter(state, "other").Count(ctx, "1"); countErr != nil || count != 0 {
+		t.Errorf("Expected count 0 with other prefix, got %d (%v)", count, countErr)
+	}
+}
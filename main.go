@@ -3,9 +3,12 @@ package main
 import (
 	"context"
 
+	"github.com/capcom6/censor-tg-bot/internal/audit"
 	"github.com/capcom6/censor-tg-bot/internal/bot"
+	"github.com/capcom6/censor-tg-bot/internal/captcha"
 	"github.com/capcom6/censor-tg-bot/internal/censor"
 	"github.com/capcom6/censor-tg-bot/internal/config"
+	"github.com/capcom6/censor-tg-bot/internal/lockdown"
 	"github.com/capcom6/censor-tg-bot/internal/server"
 	"github.com/capcom6/censor-tg-bot/internal/storage"
 	"github.com/capcom6/censor-tg-bot/pkg/tgbotapifx"
@@ -43,6 +46,9 @@ func main() {
 		config.Module(),
 		censor.Module(),
 		storage.Module(),
+		audit.Module(),
+		captcha.Module(),
+		lockdown.Module(),
 		bot.Module(),
 		server.Module(),
 		module(),
@@ -8,4 +8,39 @@ type Config struct {
 	Debug           bool
 	ProxyURL        string
 	Timeout         time.Duration
+
+	Workers   int // number of updates handled concurrently, updates of the same chat are handled in order
+	QueueSize int // number of updates waiting per worker before receiving is paused
+
+	// AllowedUpdates are the update types delivered by Telegram, e.g. "chat_member",
+	// all types except chat_member and a few others are delivered when empty
+	AllowedUpdates []string
+
+	Webhook WebhookConfig
+	Retry   RetryConfig
+	Quota   QuotaConfig
+}
+
+// RetryConfig controls retries of failed Telegram API requests, they are made within Timeout.
+type RetryConfig struct {
+	MaxRetries int           // retries of a request, 0 disables retrying
+	MaxDelay   time.Duration // longest wait before a retry, requests asked to wait longer fail
+}
+
+// QuotaConfig limits the rate of Telegram API requests to stay below the limits of Telegram.
+type QuotaConfig struct {
+	Global  int // requests per second to all chats, 0 disables the quota
+	PerChat int // messages sent per second to a single chat, 0 disables the quota
+}
+
+// WebhookConfig enables webhook update delivery instead of long polling when URL is set.
+type WebhookConfig struct {
+	URL          string // public URL of the webhook, its path is served by the HTTP server
+	Secret       string // secret token sent by Telegram in the X-Telegram-Bot-Api-Secret-Token header
+	DeleteOnStop bool   // delete the webhook on stop, disable when several replicas share the bot
+}
+
+// Enabled reports whether updates are delivered via webhook.
+func (c WebhookConfig) Enabled() bool {
+	return c.URL != ""
 }
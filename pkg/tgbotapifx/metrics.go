@@ -0,0 +1,71 @@
+package tgbotapifx
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type (
+	MetricLabelStatus string
+	MetricLabelReason string
+	MetricLabelResult string
+)
+
+const (
+	metricsNamespace        = "telegram"
+	metricsSubsystemUpdates = "updates"
+	metricsSubsystemAPI     = "api"
+
+	MetricLabelStatusSuccess MetricLabelStatus = "success"
+	MetricLabelStatusFailed  MetricLabelStatus = "failed"
+
+	MetricLabelReasonRateLimited MetricLabelReason = "rate_limited"
+	MetricLabelReasonServerError MetricLabelReason = "server_error"
+	MetricLabelReasonNetwork     MetricLabelReason = "network"
+
+	MetricLabelResultRetried MetricLabelResult = "retried"
+	MetricLabelResultGaveUp  MetricLabelResult = "gave_up"
+)
+
+type Metrics struct {
+	queueLength  *prometheus.GaugeVec
+	updatesTotal *prometheus.CounterVec
+	retriesTotal *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		queueLength: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystemUpdates,
+			Name:      "queue_length",
+			Help:      "Number of updates waiting in the queue of a worker",
+		}, []string{"worker"}),
+		updatesTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystemUpdates,
+			Name:      "handled_total",
+			Help:      "Total number of updates handled by workers",
+		}, []string{"status"}),
+		retriesTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystemAPI,
+			Name:      "retries_total",
+			Help:      "Total number of failed Telegram API requests by retry reason and result",
+		}, []string{"method", "reason", "result"}),
+	}
+}
+
+func (m *Metrics) SetQueueLength(worker int, length int) {
+	m.queueLength.WithLabelValues(strconv.Itoa(worker)).Set(float64(length))
+}
+
+func (m *Metrics) AddHandledUpdates(status MetricLabelStatus, count int) {
+	m.updatesTotal.WithLabelValues(string(status)).Add(float64(count))
+}
+
+func (m *Metrics) IncAPIRetry(method string, reason MetricLabelReason, result MetricLabelResult) {
+	m.retriesTotal.WithLabelValues(method, string(reason), string(result)).Inc()
+}
@@ -2,29 +2,65 @@ package tgbotapifx
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-core-fx/logger"
+	"github.com/gofiber/fiber/v2"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
+type webhookParams struct {
+	fx.In
+
+	App *fiber.App `optional:"true"`
+}
+
 func Module() fx.Option {
 	return fx.Module(
 		"tgbotapifx",
 		logger.WithNamedLogger("tgbotapifx"),
+		fx.Provide(NewMetrics, fx.Private),
 		fx.Provide(New),
-		fx.Invoke(func(lc fx.Lifecycle, bot *Bot, logger *zap.Logger) {
+		fx.Invoke(func(config Config, bot *Bot, params webhookParams) error {
+			if !config.Webhook.Enabled() {
+				return nil
+			}
+
+			if params.App == nil {
+				return fmt.Errorf("%w: webhook mode requires an HTTP server", ErrInvalidConfig)
+			}
+
+			path, err := webhookPath(config.Webhook)
+			if err != nil {
+				return err
+			}
+			params.App.Post(path, bot.WebhookHandler)
+
+			return nil
+		}),
+		fx.Invoke(func(lc fx.Lifecycle, config Config, bot *Bot, logger *zap.Logger) {
 			ctx, cancel := context.WithCancel(context.Background())
 			waitCh := make(chan struct{})
 
 			lc.Append(fx.Hook{
 				OnStart: func(_ context.Context) error {
+					if config.Webhook.Enabled() {
+						if err := bot.SetWebhook(); err != nil {
+							return err
+						}
+						logger.Info("webhook set", zap.String("url", config.Webhook.URL))
+					} else if err := bot.DeleteWebhook(); err != nil {
+						// Long polling is not available while a webhook is set
+						logger.Warn("failed to delete webhook", zap.Error(err))
+					}
+
 					go func() {
 						bot.Run(ctx)
 						close(waitCh)
 					}()
 
-					logger.Info("bot started")
+					logger.Info("bot started", zap.Bool("webhook", config.Webhook.Enabled()))
 					return nil
 				},
 				OnStop: func(ctx context.Context) error {
@@ -35,6 +71,13 @@ func Module() fx.Option {
 						logger.Warn("bot stop timed out")
 						return ctx.Err()
 					}
+
+					if config.Webhook.Enabled() && config.Webhook.DeleteOnStop {
+						if err := bot.DeleteWebhook(); err != nil {
+							logger.Warn("failed to delete webhook", zap.Error(err))
+						}
+					}
+
 					logger.Info("bot stopped")
 					return nil
 				},
@@ -0,0 +1,284 @@
+package tgbotapifx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+
+	// chatLimitersSweepInterval is how often limiters of idle chats are removed.
+	chatLimitersSweepInterval = time.Minute
+)
+
+// retryTransport applies send quotas to Telegram API requests and retries requests
+// failed with 429 Too Many Requests, 5xx or a network error.
+type retryTransport struct {
+	next http.RoundTripper
+
+	config RetryConfig
+	quota  QuotaConfig
+
+	global    *rate.Limiter
+	chats     map[string]*rate.Limiter
+	chatsMu   sync.Mutex
+	lastSweep time.Time
+
+	metrics *Metrics
+	logger  *zap.Logger
+}
+
+func newRetryTransport(
+	next http.RoundTripper,
+	config RetryConfig,
+	quota QuotaConfig,
+	metrics *Metrics,
+	logger *zap.Logger,
+) *retryTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	var global *rate.Limiter
+	if quota.Global > 0 {
+		global = rate.NewLimiter(rate.Limit(quota.Global), quota.Global)
+	}
+
+	return &retryTransport{
+		next: next,
+
+		config: config,
+		quota:  quota,
+
+		global:    global,
+		chats:     make(map[string]*rate.Limiter),
+		chatsMu:   sync.Mutex{},
+		lastSweep: time.Now(),
+
+		metrics: metrics,
+		logger:  logger,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+
+	// Long polling has its own retry loop and is not limited by Telegram
+	if method == "getUpdates" {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := t.readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if waitErr := t.wait(req.Context(), method, body); waitErr != nil {
+		return nil, waitErr
+	}
+
+	// Streamed bodies cannot be sent again
+	replayable := body != nil || req.Body == nil
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if body != nil {
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, respErr := t.next.RoundTrip(attemptReq)
+
+		reason, delay := t.retryDelay(resp, respErr, attempt)
+		if reason == "" || attempt >= t.config.MaxRetries || !replayable {
+			if reason != "" {
+				t.metrics.IncAPIRetry(method, reason, MetricLabelResultGaveUp)
+			}
+			return resp, respErr
+		}
+
+		t.metrics.IncAPIRetry(method, reason, MetricLabelResultRetried)
+		t.logger.Warn("retrying telegram api request",
+			zap.String("method", method),
+			zap.String("reason", string(reason)),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+			zap.Error(respErr),
+		)
+
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+
+		if sleepErr := sleep(req.Context(), delay); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+}
+
+// readBody buffers the form-encoded request body, so that it can be sent again and its chat is known.
+// Multipart uploads are streamed and returned as nil, they are not retried.
+func (t *retryTransport) readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	return body, nil
+}
+
+// wait blocks until the global quota and, for messages sent to a chat, the quota of the chat allow sending.
+// Other requests, e.g. deletions and restrictions, are not limited per chat, as Telegram does not limit them,
+// so that a raid is cleaned up without delay.
+func (t *retryTransport) wait(ctx context.Context, method string, body []byte) error {
+	if t.global != nil {
+		if err := t.global.Wait(ctx); err != nil {
+			return fmt.Errorf("global send quota: %w", err)
+		}
+	}
+
+	if !isSendMethod(method) {
+		return nil
+	}
+
+	if limiter := t.chatLimiter(requestChatID(body)); limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("chat send quota: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (t *retryTransport) chatLimiter(chatID string) *rate.Limiter {
+	if chatID == "" || t.quota.PerChat <= 0 {
+		return nil
+	}
+
+	t.chatsMu.Lock()
+	defer t.chatsMu.Unlock()
+
+	// Limiters with a full bucket behave as new ones, so idle chats are forgotten
+	if time.Since(t.lastSweep) > chatLimitersSweepInterval {
+		for id, l := range t.chats {
+			if l.Tokens() >= float64(l.Burst()) {
+				delete(t.chats, id)
+			}
+		}
+		t.lastSweep = time.Now()
+	}
+
+	limiter, ok := t.chats[chatID]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(t.quota.PerChat), t.quota.PerChat)
+		t.chats[chatID] = limiter
+	}
+
+	return limiter
+}
+
+// retryDelay returns the reason to retry the request and the delay before the retry,
+// or an empty reason if the request must not be retried.
+func (t *retryTransport) retryDelay(resp *http.Response, err error, attempt int) (MetricLabelReason, time.Duration) {
+	backoff := min(retryBaseDelay<<attempt, t.config.MaxDelay)
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return "", 0
+		}
+		return MetricLabelReasonNetwork, backoff
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		retryAfter, ok := responseRetryAfter(resp)
+		if !ok {
+			return MetricLabelReasonRateLimited, backoff
+		}
+		if retryAfter > t.config.MaxDelay {
+			return "", 0
+		}
+		return MetricLabelReasonRateLimited, retryAfter
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return MetricLabelReasonServerError, backoff
+	}
+
+	return "", 0
+}
+
+// responseRetryAfter reads retry_after of a 429 response, keeping the body readable by the caller.
+func responseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 0, false
+	}
+
+	var apiResp tgbotapi.APIResponse
+	if jsonErr := json.Unmarshal(body, &apiResp); jsonErr != nil || apiResp.Parameters == nil {
+		return 0, false
+	}
+
+	if apiResp.Parameters.RetryAfter <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(apiResp.Parameters.RetryAfter) * time.Second, true
+}
+
+// isSendMethod reports whether the API method sends a message to a chat.
+func isSendMethod(method string) bool {
+	switch method {
+	case "copyMessage", "copyMessages", "forwardMessage", "forwardMessages":
+		return true
+	}
+
+	return strings.HasPrefix(method, "send")
+}
+
+// requestChatID returns the chat_id field of a form-encoded request body.
+func requestChatID(body []byte) string {
+	if body == nil {
+		return ""
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return ""
+	}
+
+	return values.Get("chat_id")
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck // caller's context error
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,319 @@
+package tgbotapifx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// apiServer is a fake Telegram API answering requests with the queued responses, then with 200 OK.
+type apiServer struct {
+	*httptest.Server
+
+	responses []func(w http.ResponseWriter)
+	bodies    []string
+	mu        sync.Mutex
+}
+
+func newAPIServer(t *testing.T, responses ...func(w http.ResponseWriter)) *apiServer {
+	t.Helper()
+
+	s := &apiServer{Server: nil, responses: responses, bodies: nil, mu: sync.Mutex{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(body))
+		var respond func(w http.ResponseWriter)
+		if len(s.responses) > 0 {
+			respond, s.responses = s.responses[0], s.responses[1:]
+		}
+		s.mu.Unlock()
+
+		if respond == nil {
+			_, _ = io.WriteString(w, `{"ok":true,"result":true}`)
+			return
+		}
+		respond(w)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *apiServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bodies
+}
+
+func status(code int, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(code)
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+func newTestTransport(next http.RoundTripper, quota QuotaConfig) *retryTransport {
+	return newRetryTransport(
+		next,
+		RetryConfig{MaxRetries: 2, MaxDelay: 2 * time.Second},
+		quota,
+		testMetrics(),
+		zap.NewNop(),
+	)
+}
+
+// post sends the form-encoded request to the API method through the transport.
+func post(t *testing.T, transport http.RoundTripper, baseURL, method string, form url.Values) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		baseURL+"/botTOKEN/"+method,
+		strings.NewReader(form.Encode()),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp
+}
+
+func TestRetryTransport_RateLimited(t *testing.T) {
+	server := newAPIServer(t, status(
+		http.StatusTooManyRequests,
+		`{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":1}}`,
+	))
+	transport := newTestTransport(http.DefaultTransport, QuotaConfig{Global: 0, PerChat: 0})
+
+	// The request is sent again with the same body after retry_after
+	start := time.Now()
+	form := url.Values{"chat_id": {"-100"}, "text": {"hello"}}
+	resp := post(t, transport, server.URL, "sendMessage", form)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+	require.Equal(t, []string{form.Encode(), form.Encode()}, server.requests())
+}
+
+func TestRetryTransport_RetryAfterTooLong(t *testing.T) {
+	body := `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":60}}`
+	server := newAPIServer(t, status(http.StatusTooManyRequests, body))
+	transport := newTestTransport(http.DefaultTransport, QuotaConfig{Global: 0, PerChat: 0})
+
+	// Requests asked to wait longer than the maximum delay fail at once, the response stays readable
+	resp := post(t, transport, server.URL, "sendMessage", url.Values{"chat_id": {"-100"}})
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Len(t, server.requests(), 1)
+
+	read, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.JSONEq(t, body, string(read))
+}
+
+func TestRetryTransport_ServerError(t *testing.T) {
+	server := newAPIServer(
+		t,
+		status(http.StatusBadGateway, "bad gateway"),
+		status(http.StatusInternalServerError, "internal error"),
+		status(http.StatusServiceUnavailable, "unavailable"),
+	)
+	transport := newTestTransport(http.DefaultTransport, QuotaConfig{Global: 0, PerChat: 0})
+
+	// The request is given up after the configured retries
+	resp := post(t, transport, server.URL, "deleteMessage", url.Values{"chat_id": {"-100"}, "message_id": {"1"}})
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Len(t, server.requests(), 3)
+
+	// Client errors are not retried
+	server = newAPIServer(t, status(http.StatusBadRequest, `{"ok":false,"error_code":400}`))
+	resp = post(t, transport, server.URL, "deleteMessage", url.Values{"chat_id": {"-100"}, "message_id": {"1"}})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Len(t, server.requests(), 1)
+}
+
+// failingTransport fails the first requests with a network error.
+type failingTransport struct {
+	failures int
+	calls    int
+}
+
+func (t *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls++
+	if t.calls <= t.failures {
+		return nil, errors.New("connection reset by peer")
+	}
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRetryTransport_NetworkError(t *testing.T) {
+	server := newAPIServer(t)
+	next := &failingTransport{failures: 1, calls: 0}
+	transport := newTestTransport(next, QuotaConfig{Global: 0, PerChat: 0})
+
+	form := url.Values{"chat_id": {"-100"}, "user_id": {"1"}}
+	resp := post(t, transport, server.URL, "banChatMember", form)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 2, next.calls)
+	require.Equal(t, []string{form.Encode()}, server.requests())
+}
+
+func TestRetryTransport_NotRetried(t *testing.T) {
+	server := newAPIServer(
+		t,
+		status(http.StatusInternalServerError, "internal error"),
+		status(http.StatusInternalServerError, "internal error"),
+	)
+	transport := newTestTransport(http.DefaultTransport, QuotaConfig{Global: 0, PerChat: 0})
+
+	// Streamed bodies, e.g. multipart uploads, cannot be sent again
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		server.URL+"/botTOKEN/sendDocument",
+		io.MultiReader(strings.NewReader("--boundary")),
+	)
+	require.NoError(t, err)
+	require.Nil(t, req.GetBody)
+
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Equal(t, []string{"--boundary"}, server.requests())
+
+	// Long polling is passed through
+	resp = post(t, transport, server.URL, "getUpdates", url.Values{"timeout": {"60"}})
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Len(t, server.requests(), 2)
+}
+
+func TestRetryTransport_RetryDelay(t *testing.T) {
+	transport := newTestTransport(http.DefaultTransport, QuotaConfig{Global: 0, PerChat: 0})
+
+	response := func(code int, body string) *http.Response {
+		//nolint:exhaustruct // status and body only
+		return &http.Response{StatusCode: code, Body: io.NopCloser(strings.NewReader(body))}
+	}
+
+	tests := []struct {
+		name    string
+		resp    *http.Response
+		err     error
+		attempt int
+		reason  MetricLabelReason
+		delay   time.Duration
+	}{
+		{"success", response(http.StatusOK, ""), nil, 0, "", 0},
+		{"client error", response(http.StatusForbidden, ""), nil, 0, "", 0},
+		{"server error", response(http.StatusBadGateway, ""), nil, 0, MetricLabelReasonServerError, retryBaseDelay},
+		{
+			"server error backoff",
+			response(http.StatusBadGateway, ""),
+			nil,
+			1,
+			MetricLabelReasonServerError,
+			2 * retryBaseDelay,
+		},
+		{
+			"backoff is capped",
+			response(http.StatusBadGateway, ""),
+			nil,
+			10,
+			MetricLabelReasonServerError,
+			2 * time.Second,
+		},
+		{"network error", nil, errors.New("connection refused"), 0, MetricLabelReasonNetwork, retryBaseDelay},
+		{"canceled", nil, context.Canceled, 0, "", 0},
+		{"timeout", nil, context.DeadlineExceeded, 0, "", 0},
+		{
+			"rate limited",
+			response(http.StatusTooManyRequests, `{"ok":false,"parameters":{"retry_after":2}}`),
+			nil,
+			0,
+			MetricLabelReasonRateLimited,
+			2 * time.Second,
+		},
+		{
+			"rate limited without retry_after",
+			response(http.StatusTooManyRequests, "slow down"),
+			nil,
+			0,
+			MetricLabelReasonRateLimited,
+			retryBaseDelay,
+		},
+		{
+			"rate limited too long",
+			response(http.StatusTooManyRequests, `{"ok":false,"parameters":{"retry_after":3}}`),
+			nil,
+			0,
+			"",
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, delay := transport.retryDelay(tt.resp, tt.err, tt.attempt)
+			require.Equal(t, tt.reason, reason)
+			require.Equal(t, tt.delay, delay)
+		})
+	}
+}
+
+func TestResponseRetryAfter(t *testing.T) {
+	//nolint:exhaustruct // body only
+	resp := &http.Response{
+		Body: io.NopCloser(strings.NewReader(`{"ok":false,"parameters":{"retry_after":5}}`)),
+	}
+
+	retryAfter, ok := responseRetryAfter(resp)
+	require.True(t, ok)
+	require.Equal(t, 5*time.Second, retryAfter)
+
+	// The body is still readable by the caller
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"ok":false,"parameters":{"retry_after":5}}`, string(body))
+
+	for _, body := range []string{"", "not json", `{"ok":false}`, `{"ok":false,"parameters":{"retry_after":0}}`} {
+		//nolint:exhaustruct // body only
+		_, ok = responseRetryAfter(&http.Response{Body: io.NopCloser(strings.NewReader(body))})
+		require.False(t, ok, body)
+	}
+}
+
+func TestRetryTransport_ChatQuota(t *testing.T) {
+	server := newAPIServer(t)
+	transport := newTestTransport(http.DefaultTransport, QuotaConfig{Global: 0, PerChat: 1})
+
+	// Deletions and restrictions of a raid are not limited per chat
+	start := time.Now()
+	for i := range 10 {
+		post(t, transport, server.URL, "deleteMessage", url.Values{"chat_id": {"-100"}, "message_id": {strconv.Itoa(i)}})
+		post(t, transport, server.URL, "restrictChatMember", url.Values{"chat_id": {"-100"}, "user_id": {"1"}})
+	}
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// Messages sent to the chat are
+	post(t, transport, server.URL, "sendMessage", url.Values{"chat_id": {"-100"}, "text": {"1"}})
+	post(t, transport, server.URL, "copyMessage", url.Values{"chat_id": {"-100"}, "message_id": {"1"}})
+	require.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -19,18 +21,32 @@ type Bot struct {
 
 	config Config
 
-	handler Handler
+	handler   Handler
+	commands  map[string]Handler
+	callbacks map[string]Handler
 
-	logger *zap.Logger
+	webhookUpdates chan tgbotapi.Update
+	webhookClosed  bool         // no more updates are accepted, webhookUpdates is closed
+	webhookMu      sync.RWMutex // held for reading while an update is queued
+
+	metrics *Metrics
+	logger  *zap.Logger
 }
 
-func New(config Config, logger *zap.Logger) (*Bot, error) {
+func New(config Config, metrics *Metrics, logger *zap.Logger) (*Bot, error) {
 	var api *tgbotapi.BotAPI
 
+	if config.Webhook.Enabled() {
+		if _, err := webhookPath(config.Webhook); err != nil {
+			return nil, err
+		}
+	}
+
 	client, err := newClient(config.ProxyURL, config.Timeout)
 	if err != nil {
 		return nil, err
 	}
+	client.Transport = newRetryTransport(client.Transport, config.Retry, config.Quota, metrics, logger)
 
 	api, err = tgbotapi.NewBotAPIWithClient(config.Token, tgbotapi.APIEndpoint, client)
 	if err != nil {
@@ -40,9 +56,17 @@ func New(config Config, logger *zap.Logger) (*Bot, error) {
 	api.Debug = config.Debug
 
 	return &Bot{
-		BotAPI:  api,
-		config:  config,
-		handler: nil,
+		BotAPI:    api,
+		config:    config,
+		handler:   nil,
+		commands:  make(map[string]Handler),
+		callbacks: make(map[string]Handler),
+
+		webhookUpdates: make(chan tgbotapi.Update, webhookQueueSize),
+		webhookClosed:  false,
+		webhookMu:      sync.RWMutex{},
+
+		metrics: metrics,
 		logger:  logger,
 	}, nil
 }
@@ -83,29 +107,88 @@ func (b *Bot) SetDefaultHandler(handler Handler) {
 	b.handler = handler
 }
 
+// AddCommandHandler sets the handler of a command, e.g. "ban" for /ban.
+// Commands without a handler or addressed to other bots are passed to the default handler.
+func (b *Bot) AddCommandHandler(command string, handler Handler) {
+	b.commands[command] = handler
+}
+
+// AddCallbackHandler sets the handler of callback queries with data prefixed by "<prefix>:".
+// Callback queries without a handler are passed to the default handler.
+func (b *Bot) AddCallbackHandler(prefix string, handler Handler) {
+	b.callbacks[prefix] = handler
+}
+
+// Run handles updates received via webhook or long polling until the context is canceled.
+// Updates are handled concurrently by the configured number of workers, preserving the order within a chat.
+// On cancellation receiving stops and the updates already received are handled before Run returns,
+// as Telegram does not deliver them again.
 func (b *Bot) Run(ctx context.Context) {
-	updates := b.GetUpdatesChan(tgbotapi.UpdateConfig{
-		Timeout: int(b.config.LongPollTimeout.Seconds()),
-	})
-	defer b.StopReceivingUpdates()
+	var updates tgbotapi.UpdatesChannel
+	if b.config.Webhook.Enabled() {
+		updates = b.webhookUpdates
+	} else {
+		updates = b.GetUpdatesChan(tgbotapi.UpdateConfig{
+			Offset:         0,
+			Limit:          0,
+			Timeout:        int(b.config.LongPollTimeout.Seconds()),
+			AllowedUpdates: b.config.AllowedUpdates,
+		})
+	}
+
+	// Handlers are not canceled on stop, so that the received updates are handled completely
+	handleCtx := context.WithoutCancel(ctx)
+	pool := newWorkerPool(b.config.Workers, b.config.QueueSize, b.handleUpdate, b.metrics, b.logger)
+	pool.start(handleCtx)
+	defer pool.stop()
 
 	for {
 		select {
 		case <-ctx.Done():
+			b.drain(handleCtx, updates, pool)
 			return
 		case update, ok := <-updates:
 			if !ok {
 				b.logger.Warn("updates channel closed")
 				return
 			}
-			if err := b.handleUpdate(ctx, update); err != nil {
-				b.logger.Error("error handling update", zap.Error(err))
+			// A received update is queued even if the context is canceled meanwhile, it is not delivered again
+			pool.dispatch(handleCtx, update)
+		}
+	}
+}
+
+// drain stops receiving updates and queues the ones already received to the workers.
+func (b *Bot) drain(ctx context.Context, updates tgbotapi.UpdatesChannel, pool *workerPool) {
+	if !b.config.Webhook.Enabled() {
+		b.StopReceivingUpdates()
+
+		for {
+			select {
+			case update := <-updates:
+				pool.dispatch(ctx, update)
+			default:
+				return
 			}
 		}
 	}
+
+	// Requests waiting for a place in the queue are served while the webhook is being closed
+	go b.closeWebhook()
+	for update := range updates {
+		pool.dispatch(ctx, update)
+	}
 }
 
 func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) error {
+	if handler, ok := b.commandHandler(update.Message); ok {
+		return handler(ctx, b, update)
+	}
+
+	if handler, ok := b.callbackHandler(update.CallbackQuery); ok {
+		return handler(ctx, b, update)
+	}
+
 	if b.handler == nil {
 		b.logger.Warn("no handler set")
 		return nil
@@ -113,3 +196,27 @@ func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) error {
 
 	return b.handler(ctx, b, update)
 }
+
+func (b *Bot) commandHandler(message *tgbotapi.Message) (Handler, bool) {
+	if message == nil || !message.IsCommand() {
+		return nil, false
+	}
+
+	// Commands in groups may be addressed to a specific bot, e.g. /ban@my_bot
+	if _, to, found := strings.Cut(message.CommandWithAt(), "@"); found && !strings.EqualFold(to, b.Self.UserName) {
+		return nil, false
+	}
+
+	handler, ok := b.commands[message.Command()]
+	return handler, ok
+}
+
+func (b *Bot) callbackHandler(query *tgbotapi.CallbackQuery) (Handler, bool) {
+	if query == nil {
+		return nil, false
+	}
+
+	prefix, _, _ := strings.Cut(query.Data, ":")
+	handler, ok := b.callbacks[prefix]
+	return handler, ok
+}
@@ -0,0 +1,136 @@
+package tgbotapifx
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token" //nolint:gosec // header name
+	webhookQueueSize    = 100
+	webhookQueueTimeout = 5 * time.Second
+)
+
+// webhookSecretPattern is the format of secret tokens accepted by Telegram.
+var webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Validate checks the webhook URL and the secret token, Telegram rejects secrets
+// longer than 256 characters or containing characters other than A-Z, a-z, 0-9, _ and -.
+func (c WebhookConfig) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse webhook URL: %w", ErrInvalidConfig, err)
+	}
+
+	if u.Scheme != "https" {
+		return fmt.Errorf("%w: webhook URL must use https", ErrInvalidConfig)
+	}
+
+	if c.Secret == "" {
+		return fmt.Errorf("%w: webhook secret is required", ErrInvalidConfig)
+	}
+
+	if !webhookSecretPattern.MatchString(c.Secret) {
+		return fmt.Errorf(
+			"%w: webhook secret must be 1-256 characters of A-Z, a-z, 0-9, _ and -",
+			ErrInvalidConfig,
+		)
+	}
+
+	return nil
+}
+
+// webhookPath returns the path of the webhook URL served by the HTTP server.
+func webhookPath(config WebhookConfig) (string, error) {
+	if err := config.Validate(); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to parse webhook URL: %w", ErrInvalidConfig, err)
+	}
+
+	if u.Path == "" {
+		return "/", nil
+	}
+
+	return u.Path, nil
+}
+
+// WebhookHandler verifies the secret token and queues the update for Run.
+func (b *Bot) WebhookHandler(c *fiber.Ctx) error {
+	secret := c.Get(webhookSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(b.config.Webhook.Secret)) != 1 {
+		b.logger.Warn("webhook request with invalid secret token", zap.String("ip", c.IP()))
+		return fiber.ErrUnauthorized
+	}
+
+	var update tgbotapi.Update
+	if err := json.Unmarshal(c.Body(), &update); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid update")
+	}
+
+	b.webhookMu.RLock()
+	defer b.webhookMu.RUnlock()
+
+	// Updates are not accepted on shutdown, Telegram retries the delivery to another replica or after restart
+	if b.webhookClosed {
+		return fiber.ErrServiceUnavailable
+	}
+
+	select {
+	case b.webhookUpdates <- update:
+		return c.SendStatus(fiber.StatusOK)
+	case <-time.After(webhookQueueTimeout):
+		// Telegram retries the delivery later
+		b.logger.Warn("webhook update queue is full", zap.Int("update_id", update.UpdateID))
+		return fiber.ErrServiceUnavailable
+	}
+}
+
+// closeWebhook stops accepting updates and closes the queue once no request is queuing an update.
+func (b *Bot) closeWebhook() {
+	b.webhookMu.Lock()
+	defer b.webhookMu.Unlock()
+
+	if !b.webhookClosed {
+		b.webhookClosed = true
+		close(b.webhookUpdates)
+	}
+}
+
+// SetWebhook registers the webhook with Telegram.
+func (b *Bot) SetWebhook() error {
+	params := tgbotapi.Params{}
+	params["url"] = b.config.Webhook.URL
+	params["secret_token"] = b.config.Webhook.Secret
+	if len(b.config.AllowedUpdates) > 0 {
+		if err := params.AddInterface("allowed_updates", b.config.AllowedUpdates); err != nil {
+			return fmt.Errorf("failed to set allowed updates: %w", err)
+		}
+	}
+
+	if _, err := b.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("failed to set webhook: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteWebhook removes the webhook, pending updates are kept.
+func (b *Bot) DeleteWebhook() error {
+	if _, err := b.Request(tgbotapi.DeleteWebhookConfig{DropPendingUpdates: false}); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	return nil
+}
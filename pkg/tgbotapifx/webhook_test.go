@@ -0,0 +1,123 @@
+package tgbotapifx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// metrics are registered in the global Prometheus registry, so they can be created only once.
+var testMetrics = sync.OnceValue(NewMetrics) //nolint:gochecknoglobals // see above
+
+const testWebhookSecret = "secret-token"
+
+// newWebhookBot returns a bot receiving updates via webhook, without a connection to Telegram.
+func newWebhookBot(handler Handler) *Bot {
+	//nolint:exhaustruct // only the fields used by the webhook
+	return &Bot{
+		config: Config{
+			Workers:   2,
+			QueueSize: 10,
+			Webhook: WebhookConfig{
+				URL:          "https://bot.example.com/webhook",
+				Secret:       testWebhookSecret,
+				DeleteOnStop: false,
+			},
+		},
+		handler:   handler,
+		commands:  map[string]Handler{},
+		callbacks: map[string]Handler{},
+
+		webhookUpdates: make(chan tgbotapi.Update, webhookQueueSize),
+		webhookClosed:  false,
+		webhookMu:      sync.RWMutex{},
+
+		metrics: testMetrics(),
+		logger:  zap.NewNop(),
+	}
+}
+
+func postUpdate(t *testing.T, app *fiber.App, secret, body string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if secret != "" {
+		req.Header.Set(webhookSecretHeader, secret)
+	}
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp.StatusCode
+}
+
+func TestBot_WebhookHandler(t *testing.T) {
+	bot := newWebhookBot(nil)
+	app := fiber.New()
+	app.Post("/webhook", bot.WebhookHandler)
+
+	update := `{"update_id":1,"message":{"message_id":1,"chat":{"id":-100},"text":"hello"}}`
+
+	require.Equal(t, http.StatusUnauthorized, postUpdate(t, app, "", update))
+	require.Equal(t, http.StatusUnauthorized, postUpdate(t, app, "wrong-token", update))
+	require.Empty(t, bot.webhookUpdates)
+
+	require.Equal(t, http.StatusBadRequest, postUpdate(t, app, testWebhookSecret, "not json"))
+	require.Empty(t, bot.webhookUpdates)
+
+	require.Equal(t, http.StatusOK, postUpdate(t, app, testWebhookSecret, update))
+	require.Len(t, bot.webhookUpdates, 1)
+	queued := <-bot.webhookUpdates
+	require.Equal(t, 1, queued.UpdateID)
+	require.Equal(t, int64(-100), queued.Message.Chat.ID)
+
+	// After the webhook is closed, Telegram is asked to deliver the updates again
+	bot.closeWebhook()
+	require.Equal(t, http.StatusServiceUnavailable, postUpdate(t, app, testWebhookSecret, update))
+
+	// Closing twice is safe
+	bot.closeWebhook()
+}
+
+func TestBot_RunDrainsWebhook(t *testing.T) {
+	var (
+		handled []int
+		mu      sync.Mutex
+	)
+	bot := newWebhookBot(func(_ context.Context, _ *Bot, update tgbotapi.Update) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		handled = append(handled, update.UpdateID)
+		return nil
+	})
+	app := fiber.New()
+	app.Post("/webhook", bot.WebhookHandler)
+
+	for _, id := range []string{"1", "2", "3"} {
+		update := `{"update_id":` + id + `,"message":{"message_id":` + id + `,"chat":{"id":-100},"text":"hi"}}`
+		require.Equal(t, http.StatusOK, postUpdate(t, app, testWebhookSecret, update))
+	}
+
+	// The updates accepted before the shutdown are handled before Run returns
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bot.Run(ctx)
+
+	mu.Lock()
+	require.Equal(t, []int{1, 2, 3}, handled)
+	mu.Unlock()
+
+	update := `{"update_id":4,"message":{"message_id":4,"chat":{"id":-100},"text":"hi"}}`
+	require.Equal(t, http.StatusServiceUnavailable, postUpdate(t, app, testWebhookSecret, update))
+}
@@ -0,0 +1,119 @@
+package tgbotapifx
+
+import (
+	"context"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// workerPool handles updates concurrently, updates of the same chat are always handled
+// by the same worker and therefore in the order they were received.
+type workerPool struct {
+	queues []chan tgbotapi.Update
+	wg     sync.WaitGroup
+
+	handle  func(ctx context.Context, update tgbotapi.Update) error
+	metrics *Metrics
+	logger  *zap.Logger
+}
+
+func newWorkerPool(
+	workers, queueSize int,
+	handle func(ctx context.Context, update tgbotapi.Update) error,
+	metrics *Metrics,
+	logger *zap.Logger,
+) *workerPool {
+	queues := make([]chan tgbotapi.Update, max(workers, 1))
+	for i := range queues {
+		queues[i] = make(chan tgbotapi.Update, max(queueSize, 1))
+	}
+
+	return &workerPool{
+		queues: queues,
+		wg:     sync.WaitGroup{},
+
+		handle:  handle,
+		metrics: metrics,
+		logger:  logger,
+	}
+}
+
+// start runs the workers until stop is called, updates are handled with the context.
+func (p *workerPool) start(ctx context.Context) {
+	for i := range p.queues {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.work(ctx, i)
+		}()
+	}
+}
+
+// stop closes the queues and blocks until the workers have handled all queued updates.
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// dispatch queues the update to the worker of its chat, blocking while the queue is full.
+// Returns false if the context is canceled before the update is queued.
+func (p *workerPool) dispatch(ctx context.Context, update tgbotapi.Update) bool {
+	worker := int(uint64(updateChatID(update)) % uint64(len(p.queues))) //nolint:gosec // only used for sharding
+	queue := p.queues[worker]
+
+	select {
+	case <-ctx.Done():
+		return false
+	case queue <- update:
+		p.metrics.SetQueueLength(worker, len(queue))
+		return true
+	}
+}
+
+func (p *workerPool) work(ctx context.Context, worker int) {
+	queue := p.queues[worker]
+
+	for update := range queue {
+		p.metrics.SetQueueLength(worker, len(queue))
+
+		if err := p.handle(ctx, update); err != nil {
+			p.logger.Error("error handling update", zap.Int("worker", worker), zap.Error(err))
+			p.metrics.AddHandledUpdates(MetricLabelStatusFailed, 1)
+			continue
+		}
+		p.metrics.AddHandledUpdates(MetricLabelStatusSuccess, 1)
+	}
+}
+
+// updateChatID returns the chat the update belongs to, the sender for updates without a chat
+// (e.g. inline queries) or 0 if neither is known.
+func updateChatID(update tgbotapi.Update) int64 {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID
+	case update.EditedMessage != nil:
+		return update.EditedMessage.Chat.ID
+	case update.ChannelPost != nil:
+		return update.ChannelPost.Chat.ID
+	case update.EditedChannelPost != nil:
+		return update.EditedChannelPost.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.MyChatMember != nil:
+		return update.MyChatMember.Chat.ID
+	case update.ChatMember != nil:
+		return update.ChatMember.Chat.ID
+	case update.ChatJoinRequest != nil:
+		return update.ChatJoinRequest.Chat.ID
+	}
+
+	if user := update.SentFrom(); user != nil {
+		return user.ID
+	}
+
+	return 0
+}
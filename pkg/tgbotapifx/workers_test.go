@@ -0,0 +1,108 @@
+package tgbotapifx
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func chatUpdate(id int, chatID int64) tgbotapi.Update {
+	//nolint:exhaustruct // message of the chat only
+	return tgbotapi.Update{
+		UpdateID: id,
+		Message:  &tgbotapi.Message{MessageID: id, Chat: &tgbotapi.Chat{ID: chatID}},
+	}
+}
+
+func TestWorkerPool_Ordering(t *testing.T) {
+	const (
+		blockedChat = int64(0) // handled by the first worker
+		otherChat   = int64(1) // handled by the second worker
+	)
+
+	release := make(chan struct{})
+	var (
+		handled = map[int64][]int{}
+		mu      sync.Mutex
+	)
+	pool := newWorkerPool(2, 10, func(_ context.Context, update tgbotapi.Update) error {
+		chatID := update.Message.Chat.ID
+		if chatID == blockedChat {
+			<-release
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		handled[chatID] = append(handled[chatID], update.UpdateID)
+		return nil
+	}, testMetrics(), zap.NewNop())
+	pool.start(context.Background())
+
+	ctx := context.Background()
+	for id := range 5 {
+		require.True(t, pool.dispatch(ctx, chatUpdate(id, blockedChat)))
+		require.True(t, pool.dispatch(ctx, chatUpdate(id, otherChat)))
+	}
+
+	// The other chat proceeds while the handler of the first one is blocked
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled[otherChat]) == 5
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	require.Equal(t, []int{0, 1, 2, 3, 4}, handled[otherChat])
+	require.Empty(t, handled[blockedChat])
+	mu.Unlock()
+
+	// Stopping drains the queues, the updates of the first chat are handled in order
+	close(release)
+	pool.stop()
+
+	require.Equal(t, []int{0, 1, 2, 3, 4}, handled[blockedChat])
+}
+
+func TestWorkerPool_DispatchCanceled(t *testing.T) {
+	block := make(chan struct{})
+	pool := newWorkerPool(1, 1, func(_ context.Context, _ tgbotapi.Update) error {
+		<-block
+		return nil
+	}, testMetrics(), zap.NewNop())
+	pool.start(context.Background())
+
+	ctx := context.Background()
+	require.True(t, pool.dispatch(ctx, chatUpdate(1, 1))) // handled, the worker is blocked
+	require.Eventually(t, func() bool { return len(pool.queues[0]) == 0 }, time.Second, 10*time.Millisecond)
+	require.True(t, pool.dispatch(ctx, chatUpdate(2, 1))) // fills the queue
+
+	// Dispatching to a full queue gives up when the context is canceled
+	canceled, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	require.False(t, pool.dispatch(canceled, chatUpdate(3, 1)))
+
+	close(block)
+	pool.stop()
+}
+
+func TestUpdateChatID(t *testing.T) {
+	require.Equal(t, int64(-100), updateChatID(chatUpdate(1, -100)))
+
+	//nolint:exhaustruct // callback query only
+	callback := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		From:    &tgbotapi.User{ID: 7},
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -200}},
+	}}
+	require.Equal(t, int64(-200), updateChatID(callback))
+
+	//nolint:exhaustruct // inline query only
+	inline := tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{From: &tgbotapi.User{ID: 7}}}
+	require.Equal(t, int64(7), updateChatID(inline))
+
+	require.Zero(t, updateChatID(tgbotapi.Update{})) //nolint:exhaustruct // empty update
+}